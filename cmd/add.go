@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/twpayne/chezmoi/internal/chezmoi"
@@ -33,6 +32,7 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 
 	persistentFlags := addCmd.PersistentFlags()
+	persistentFlags.BoolVar(&config.add.options.Block, "block", false, "add managed blocks in files")
 	persistentFlags.BoolVarP(&config.add.options.Empty, "empty", "e", false, "add empty files")
 	persistentFlags.BoolVar(&config.add.options.Encrypt, "encrypt", false, "encrypt files")
 	persistentFlags.BoolVarP(&config.add.force, "force", "f", false, "overwrite source state, even if template would be lost")
@@ -58,7 +58,6 @@ func (c *Config) runAddCmd(cmd *cobra.Command, args []string) (err error) {
 	if err := c.ensureSourceDirectory(); err != nil {
 		return err
 	}
-	destDirPrefix := filepath.FromSlash(ts.DestDir + "/")
 	var quit int // quit is an int with a unique address
 	defer func() {
 		if r := recover(); r != nil {
@@ -79,7 +78,11 @@ func (c *Config) runAddCmd(cmd *cobra.Command, args []string) (err error) {
 				if err != nil {
 					return err
 				}
-				if ts.TargetIgnore.Match(strings.TrimPrefix(path, destDirPrefix)) {
+				root, targetName, err := rootTargetName(c.fs, ts, path)
+				if err != nil {
+					return err
+				}
+				if info.IsDir() && root.TargetIgnore.MatchDir(targetName) || !info.IsDir() && root.Ignore(targetName) {
 					cmd.Printf("warning: %s: skipping file ignored by .chezmoiignore\n", path)
 					return nil
 				}
@@ -88,7 +91,7 @@ func (c *Config) runAddCmd(cmd *cobra.Command, args []string) (err error) {
 					if err != nil && !os.IsNotExist(err) {
 						return err
 					}
-					if file, ok := entry.(*chezmoi.File); ok && file.Template {
+					if generatedByTemplate(entry) {
 						cmd.Printf("warning: %s: skipping file generated by template, use --force to force\n", path)
 						return nil
 					}
@@ -108,12 +111,19 @@ func (c *Config) runAddCmd(cmd *cobra.Command, args []string) (err error) {
 						c.add.prompt = false
 					}
 				}
-				return ts.Add(c.fs, c.add.options, path, info, c.Follow, c.mutator)
+				if err := ts.Add(c.fs, c.add.options, path, info, c.Follow, c.mutator); err != nil {
+					return err
+				}
+				return c.recordAddCommitOperation(ts, path)
 			}); err != nil {
 				return err
 			}
 		} else {
-			if ts.TargetIgnore.Match(strings.TrimPrefix(path, destDirPrefix)) {
+			root, targetName, err := rootTargetName(c.fs, ts, path)
+			if err != nil {
+				return err
+			}
+			if root.Ignore(targetName) {
 				cmd.Printf("warning: %s: skipping file ignored by .chezmoiignore\n", path)
 				continue
 			}
@@ -122,7 +132,7 @@ func (c *Config) runAddCmd(cmd *cobra.Command, args []string) (err error) {
 				if err != nil && !os.IsNotExist(err) {
 					return err
 				}
-				if file, ok := entry.(*chezmoi.File); ok && file.Template {
+				if generatedByTemplate(entry) {
 					cmd.Printf("warning: %s: skipping file generated by template, use --force to force\n", path)
 					continue
 				}
@@ -145,7 +155,52 @@ func (c *Config) runAddCmd(cmd *cobra.Command, args []string) (err error) {
 			if err := ts.Add(c.fs, c.add.options, path, nil, c.Follow, c.mutator); err != nil {
 				return err
 			}
+			if err := c.recordAddCommitOperation(ts, path); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
+
+// recordAddCommitOperation records that path was added, if it is now in the
+// source state.
+func (c *Config) recordAddCommitOperation(ts *chezmoi.TargetState, path string) error {
+	entry, err := ts.Get(c.fs, path)
+	switch {
+	case err == nil:
+		c.recordCommitOperation("add", entry)
+		return nil
+	case os.IsNotExist(err):
+		return nil
+	default:
+		return err
+	}
+}
+
+// rootTargetName returns the target state, ts or one of its roots, that
+// contains path, and the target name of path in it.
+func rootTargetName(fs vfs.Stater, ts *chezmoi.TargetState, path string) (*chezmoi.TargetState, string, error) {
+	root, err := ts.Root(fs, path)
+	if err != nil {
+		return nil, "", err
+	}
+	targetName, err := filepath.Rel(root.DestDir, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, targetName, nil
+}
+
+// generatedByTemplate returns true if entry is a file or block generated by a
+// template.
+func generatedByTemplate(entry chezmoi.Entry) bool {
+	switch entry := entry.(type) {
+	case *chezmoi.Block:
+		return entry.Template
+	case *chezmoi.File:
+		return entry.Template
+	default:
+		return false
+	}
+}
@@ -38,6 +38,66 @@ func TestAddAfterModification(t *testing.T) {
 	)
 }
 
+func TestAddWithChezmoiRoot(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": &vfst.Dir{Perm: 0755},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoiroot": "home\n",
+			"README.md":    "# README\n",
+			"home":         &vfst.Dir{Perm: 0755},
+		},
+		"/home/user/.bashrc": "# contents of .bashrc\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	c := newTestConfig(fs)
+	assert.NoError(t, c.runAddCmd(nil, []string{"/home/user/.bashrc"}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi/home/dot_bashrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_bashrc",
+			vfst.TestDoesNotExist,
+		),
+	)
+	ts, err := c.getTargetState(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "/home/user/.local/share/chezmoi/home", ts.SourceDir)
+	assert.Len(t, ts.Entries, 1)
+	assert.Contains(t, ts.Entries, ".bashrc")
+}
+
+func TestAddBlock(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user":                      &vfst.Dir{Perm: 0755},
+		"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+		"/home/user/.bashrc": "# local contents of .bashrc\n" +
+			"# BEGIN chezmoi .bashrc\n" +
+			"# managed contents of .bashrc\n" +
+			"# END chezmoi .bashrc\n",
+		"/home/user/.profile": "# contents of .profile\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	c := newTestConfig(fs)
+	c.add.options.Block = true
+	assert.NoError(t, c.runAddCmd(nil, []string{"/home/user/.bashrc"}))
+	assert.Error(t, c.runAddCmd(nil, []string{"/home/user/.profile"}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi/block_dot_bashrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# managed contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_bashrc",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/block_dot_profile",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
 func TestAddCommand(t *testing.T) {
 	for _, tc := range []struct {
 		name   string
@@ -121,6 +181,23 @@ func TestAddCommand(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "add_readonly_file",
+			args: []string{"/home/user/.bashrc"},
+			root: map[string]interface{}{
+				"/home/user/.bashrc": &vfst.File{
+					Perm:     0444,
+					Contents: []byte("foo"),
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/readonly_dot_bashrc",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0644),
+					vfst.TestContentsString("foo"),
+				),
+			},
+		},
 		{
 			name: "add_autotemplate",
 			args: []string{"/home/user/.gitconfig"},
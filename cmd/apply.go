@@ -1,7 +1,14 @@
 package cmd
 
 import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
 	"github.com/spf13/cobra"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 )
 
 var applyCmd = &cobra.Command{
@@ -13,9 +20,29 @@ var applyCmd = &cobra.Command{
 	RunE:    config.runApplyCmd,
 }
 
+type applyCmdConfig struct {
+	force        bool
+	keepGoing    bool
+	reportFormat string
+}
+
+// An applyReportEntry is a single failed target in the JSON report of apply
+// --keep-going.
+type applyReportEntry struct {
+	TargetPath string   `json:"targetPath"`
+	SourcePath string   `json:"sourcePath,omitempty"`
+	Error      string   `json:"error"`
+	Skipped    []string `json:"skipped,omitempty"`
+}
+
 func init() {
 	rootCmd.AddCommand(applyCmd)
 
+	persistentFlags := applyCmd.PersistentFlags()
+	persistentFlags.BoolVarP(&config.apply.force, "force", "f", false, "remove without prompting")
+	persistentFlags.BoolVarP(&config.apply.keepGoing, "keep-going", "k", false, "keep going as far as possible after an error")
+	persistentFlags.StringVar(&config.apply.reportFormat, "report-format", "text", "format of the --keep-going report (text or JSON)")
+
 	markRemainingZshCompPositionalArgumentsAsFiles(applyCmd, 1)
 }
 
@@ -28,3 +55,88 @@ func (c *Config) runApplyCmd(cmd *cobra.Command, args []string) error {
 
 	return c.applyArgs(args, persistentState)
 }
+
+// confirmRemove prints targetPaths and prompts the user to confirm removing all
+// of them.
+func (c *Config) confirmRemove(targetPaths []string) (bool, error) {
+	for _, targetPath := range targetPaths {
+		fmt.Fprintf(c.Stdout, "remove %s\n", targetPath)
+	}
+	choice, err := c.prompt(fmt.Sprintf("Remove %d target(s)", len(targetPaths)), "yn")
+	if err != nil {
+		return false, err
+	}
+	return choice == 'y', nil
+}
+
+// getRemove returns whether targets should be removed and whether removals
+// should only be reported, according to c.Remove.
+func (c *Config) getRemove() (remove, report bool) {
+	if c.Remove == "prompt" {
+		return true, true
+	}
+	remove, _ = strconv.ParseBool(c.Remove)
+	return remove, false
+}
+
+// reportRemove prints that targetPaths would be removed, without removing them.
+func (c *Config) reportRemove(targetPaths []string) (bool, error) {
+	for _, targetPath := range targetPaths {
+		fmt.Fprintf(c.Stdout, "would remove %s\n", targetPath)
+	}
+	return false, nil
+}
+
+// reportApplyErrors writes a report of applyErrors to c.Stdout in the
+// configured format and returns an error summarizing them.
+func (c *Config) reportApplyErrors(ts *chezmoi.TargetState, applyErrors chezmoi.ApplyErrors) error {
+	switch strings.ToLower(c.apply.reportFormat) {
+	case "json":
+		report := make([]applyReportEntry, 0, len(applyErrors))
+		for _, applyError := range applyErrors {
+			destDir := applyErrorDestDir(ts, applyError)
+			entry := applyReportEntry{
+				TargetPath: filepath.Join(destDir, applyError.TargetName),
+				Error:      applyError.Err.Error(),
+			}
+			if applyError.SourceName != "" {
+				sourceDir := applyError.SourceDir
+				if sourceDir == "" {
+					sourceDir = ts.EntrySourceDir(applyError.TargetName)
+				}
+				entry.SourcePath = filepath.Join(sourceDir, applyError.SourceName)
+			}
+			for _, targetName := range applyError.Skipped {
+				entry.Skipped = append(entry.Skipped, filepath.Join(destDir, targetName))
+			}
+			report = append(report, entry)
+		}
+		if err := formatMap["json"](c.Stdout, report); err != nil {
+			return err
+		}
+	case "text":
+		for _, applyError := range applyErrors {
+			destDir := applyErrorDestDir(ts, applyError)
+			fmt.Fprintf(c.Stdout, "%s: %v\n", filepath.Join(destDir, applyError.TargetName), applyError.Err)
+			if len(applyError.Skipped) != 0 {
+				skippedPaths := make([]string, 0, len(applyError.Skipped))
+				for _, targetName := range applyError.Skipped {
+					skippedPaths = append(skippedPaths, filepath.Join(destDir, targetName))
+				}
+				fmt.Fprintf(c.Stdout, "  skipped %d target(s): %s\n", len(skippedPaths), strings.Join(skippedPaths, ", "))
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unknown report format", c.apply.reportFormat)
+	}
+	return fmt.Errorf("%d target(s) failed to apply", len(applyErrors))
+}
+
+// applyErrorDestDir returns the destination directory of applyError, which
+// defaults to ts's.
+func applyErrorDestDir(ts *chezmoi.TargetState, applyError *chezmoi.ApplyError) string {
+	if applyError.DestDir != "" {
+		return applyError.DestDir
+	}
+	return ts.DestDir
+}
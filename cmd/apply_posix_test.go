@@ -5,7 +5,10 @@ package cmd
 import (
 	"path/filepath"
 	"strings"
+	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/twpayne/go-vfs/vfst"
 )
 
@@ -82,3 +85,78 @@ func getRunOnceFiles() map[string]interface{} {
 		"/home/user/.local/share/chezmoi/run_once_foo.tmpl": "#!/bin/sh\necho bar >> {{ .TempFile }}\n",
 	}
 }
+
+func TestApplyReadOnly(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		root map[string]interface{}
+	}{
+		{
+			name: "create",
+			root: make(map[string]interface{}),
+		},
+		{
+			name: "replace_writable",
+			root: map[string]interface{}{
+				"/home/user/.bashrc": &vfst.File{
+					Perm:     0644,
+					Contents: []byte("# old contents of .bashrc\n"),
+				},
+				"/home/user/bin/tool": &vfst.File{
+					Perm:     0755,
+					Contents: []byte("#!/bin/sh\n"),
+				},
+			},
+		},
+		{
+			name: "replace_read_only",
+			root: map[string]interface{}{
+				"/home/user/.bashrc": &vfst.File{
+					Perm:     0444,
+					Contents: []byte("# old contents of .bashrc\n"),
+				},
+				"/home/user/bin/tool": &vfst.File{
+					Perm:     0555,
+					Contents: []byte("#!/bin/sh\n"),
+				},
+			},
+		},
+		{
+			name: "change_permissions",
+			root: map[string]interface{}{
+				"/home/user/.bashrc": &vfst.File{
+					Perm:     0644,
+					Contents: []byte("# contents of .bashrc\n"),
+				},
+				"/home/user/bin/tool": &vfst.File{
+					Perm:     0755,
+					Contents: []byte("#!/bin/sh\nexit 0\n"),
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.root["/home/user/.local/share/chezmoi"] = map[string]interface{}{
+				"readonly_dot_bashrc":          "# contents of .bashrc\n",
+				"bin/readonly_executable_tool": "#!/bin/sh\nexit 0\n",
+			}
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			c := newTestConfig(fs)
+			assert.NoError(t, c.runApplyCmd(nil, nil))
+			vfst.RunTests(t, fs, "",
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0444),
+					vfst.TestContentsString("# contents of .bashrc\n"),
+				),
+				vfst.TestPath("/home/user/bin/tool",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0555),
+					vfst.TestContentsString("#!/bin/sh\nexit 0\n"),
+				),
+			)
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 	vfs "github.com/twpayne/go-vfs"
 	"github.com/twpayne/go-vfs/vfst"
 )
@@ -357,8 +363,11 @@ func TestApplyRemove(t *testing.T) {
 			defer cleanup()
 			c := newTestConfig(
 				fs,
+				withApplyCmdConfig(applyCmdConfig{
+					force: true,
+				}),
 				withData(tc.data),
-				withRemove(!tc.noRemove),
+				withRemove(strconv.FormatBool(!tc.noRemove)),
 			)
 			assert.NoError(t, c.runApplyCmd(nil, nil))
 			vfst.RunTests(t, fs, "", tc.tests)
@@ -366,6 +375,117 @@ func TestApplyRemove(t *testing.T) {
 	}
 }
 
+func TestApplyRemoveConfirm(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi/.chezmoiremove": "foo\nbar\n",
+		"/home/user/bar": "# contents of bar\n",
+		"/home/user/baz": "# contents of baz\n",
+		"/home/user/foo": "# contents of foo\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(
+		fs,
+		withBackup(backupConfig{
+			Enabled: true,
+			Dir:     "/home/user/.local/state/chezmoi/backups",
+		}),
+		withRemove("true"),
+		withStdin(strings.NewReader("n\ny\n")),
+		withStdout(stdout),
+	)
+	c.mutator, err = c.backupMutator(c.mutator)
+	require.NoError(t, err)
+
+	require.NoError(t, c.runApplyCmd(nil, nil))
+	assert.Equal(t, "remove /home/user/foo\nremove /home/user/bar\n", stdout.String())
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/bar",
+			vfst.TestContentsString("# contents of bar\n"),
+		),
+		vfst.TestPath("/home/user/foo",
+			vfst.TestContentsString("# contents of foo\n"),
+		),
+	)
+
+	require.NoError(t, c.runApplyCmd(nil, nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/bar",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/baz",
+			vfst.TestContentsString("# contents of baz\n"),
+		),
+		vfst.TestPath("/home/user/foo",
+			vfst.TestDoesNotExist,
+		),
+	)
+	backups, err := c.getBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath(filepath.Join(c.Backup.Dir, backups[0], "bar"),
+			vfst.TestContentsString("# contents of bar\n"),
+		),
+		vfst.TestPath(filepath.Join(c.Backup.Dir, backups[0], "foo"),
+			vfst.TestContentsString("# contents of foo\n"),
+		),
+	)
+}
+
+func TestApplyRemoveReport(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi/.chezmoiremove": "foo\n",
+		"/home/user/foo": "# contents of foo\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(
+		fs,
+		withRemove("prompt"),
+		withStdout(stdout),
+	)
+	require.NoError(t, c.runApplyCmd(nil, nil))
+	assert.Equal(t, "would remove /home/user/foo\n", stdout.String())
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/foo",
+			vfst.TestContentsString("# contents of foo\n"),
+		),
+	)
+}
+
+func TestApplyRemoveMax(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi/.chezmoiremove": "*",
+		"/home/user/bar": "# contents of bar\n",
+		"/home/user/foo": "# contents of foo\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(
+		fs,
+		withApplyCmdConfig(applyCmdConfig{
+			force: true,
+		}),
+		withMaxRemove(1),
+		withRemove("true"),
+	)
+	assert.Error(t, c.runApplyCmd(nil, nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/bar",
+			vfst.TestContentsString("# contents of bar\n"),
+		),
+		vfst.TestPath("/home/user/foo",
+			vfst.TestContentsString("# contents of foo\n"),
+		),
+	)
+}
+
 func TestApplyScript(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "chezmoi")
 	require.NoError(t, err)
@@ -485,3 +605,176 @@ func TestApplyRemoveEmptySymlink(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyKeepGoing(t *testing.T) {
+	expectedError := "/home/user/.local/share/chezmoi/dot_bad.tmpl:1:4: executing \"/home/user/.local/share/chezmoi/dot_bad.tmpl\" at <.missing>: map has no entry for key \"missing\"\n" +
+		"> 1 | {{ .missing }}\n" +
+		"    |    ^\n" +
+		"data keys: chezmoi"
+	for _, tc := range []struct {
+		name         string
+		reportFormat string
+		check        func(*testing.T, []byte)
+	}{
+		{
+			name:         "text",
+			reportFormat: "text",
+			check: func(t *testing.T, output []byte) {
+				assert.Equal(t, "/home/user/.bad: "+expectedError+"\n", string(output))
+			},
+		},
+		{
+			name:         "json",
+			reportFormat: "json",
+			check: func(t *testing.T, output []byte) {
+				var report []applyReportEntry
+				require.NoError(t, json.Unmarshal(output, &report))
+				assert.Equal(t, []applyReportEntry{
+					{
+						TargetPath: "/home/user/.bad",
+						SourcePath: "/home/user/.local/share/chezmoi/dot_bad.tmpl",
+						Error:      expectedError,
+					},
+				}, report)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"dot_bad.tmpl": "{{ .missing }}",
+					"dot_good":     "good",
+				},
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			stdout := &bytes.Buffer{}
+			c := newTestConfig(fs,
+				withApplyCmdConfig(applyCmdConfig{
+					keepGoing:    true,
+					reportFormat: tc.reportFormat,
+				}),
+				withStdout(stdout),
+			)
+			assert.EqualError(t, c.runApplyCmd(nil, nil), "1 target(s) failed to apply")
+			tc.check(t, stdout.Bytes())
+			vfst.RunTests(t, fs, "",
+				vfst.TestPath("/home/user/.bad",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.good",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("good"),
+				),
+			)
+		})
+	}
+}
+
+func TestApplySymlinkMode(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".vimrc": "# old contents of .vimrc\n",
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_config/foo":     "# contents of .config/foo\n",
+				"dot_gitconfig.tmpl": "[user]\n\temail = {{ \"user@example.com\" }}\n",
+				"dot_vimrc":          "# contents of .vimrc\n",
+				"executable_dot_run": "#!/bin/sh\n",
+				"private_dot_netrc":  "# contents of .netrc\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	withSymlinkMode := func(c *Config) {
+		c.Mode = chezmoi.ModeSymlink
+	}
+	c := newTestConfig(fs, withSymlinkMode)
+	require.NoError(t, c.runApplyCmd(nil, nil))
+
+	rawSourcePath := func(sourceName string) string {
+		rawPath, err := fs.RawPath(filepath.Join("/home/user/.local/share/chezmoi", sourceName))
+		require.NoError(t, err)
+		return rawPath
+	}
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.config",
+			vfst.TestIsDir,
+		),
+		vfst.TestPath("/home/user/.config/foo",
+			vfst.TestModeType(os.ModeSymlink),
+			vfst.TestSymlinkTarget(rawSourcePath("dot_config/foo")),
+		),
+		vfst.TestPath("/home/user/.gitconfig",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("[user]\n\temail = user@example.com\n"),
+		),
+		vfst.TestPath("/home/user/.netrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .netrc\n"),
+		),
+		vfst.TestPath("/home/user/.run",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0755),
+			vfst.TestContentsString("#!/bin/sh\n"),
+		),
+		vfst.TestPath("/home/user/.vimrc",
+			vfst.TestModeType(os.ModeSymlink),
+			vfst.TestSymlinkTarget(rawSourcePath("dot_vimrc")),
+		),
+	)
+
+	// Symlinks to the source directory are in sync.
+	mutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
+	c = newTestConfig(fs, withSymlinkMode, withMutator(mutator))
+	require.NoError(t, c.applyArgs(nil, nil))
+	assert.False(t, mutator.Mutated())
+
+	c = newTestConfig(fs, withSymlinkMode)
+	require.NoError(t, c.runAddCmd(nil, []string{"/home/user/.config/foo", "/home/user/.vimrc"}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_vimrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .vimrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/symlink_dot_vimrc",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_config/symlink_foo",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
+func TestReportApplyErrorsSkipped(t *testing.T) {
+	applyErrors := chezmoi.ApplyErrors{
+		{
+			TargetName: "dir",
+			Err:        errors.New("mkdir failed"),
+			Skipped:    []string{"dir/file"},
+		},
+		{
+			TargetName: "sub",
+			Err:        errors.New("mkdir failed"),
+			Skipped:    []string{"sub/file"},
+			DestDir:    "/srv/work",
+		},
+	}
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(nil,
+		withApplyCmdConfig(applyCmdConfig{
+			reportFormat: "text",
+		}),
+		withStdout(stdout),
+	)
+	ts := chezmoi.NewTargetState(chezmoi.WithDestDir("/home/user"))
+	assert.EqualError(t, c.reportApplyErrors(ts, applyErrors), "2 target(s) failed to apply")
+	assert.Equal(t, strings.Join([]string{
+		"/home/user/dir: mkdir failed",
+		"  skipped 1 target(s): /home/user/dir/file",
+		"/srv/work/sub: mkdir failed",
+		"  skipped 1 target(s): /srv/work/sub/file",
+		"",
+	}, "\n"), stdout.String())
+}
@@ -15,8 +15,9 @@ import (
 
 func TestArchiveCmd(t *testing.T) {
 	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
-		"/home/user/.local/share/chezmoi/dir/file":        "contents",
-		"/home/user/.local/share/chezmoi/symlink_symlink": "target",
+		"/home/user/.local/share/chezmoi/dir/file":           "contents",
+		"/home/user/.local/share/chezmoi/dir/readonly_other": "other",
+		"/home/user/.local/share/chezmoi/symlink_symlink":    "target",
 	})
 	require.NoError(t, err)
 	defer cleanup()
@@ -39,6 +40,11 @@ func TestArchiveCmd(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []byte("contents"), data)
 
+	h, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("dir", "other"), h.Name)
+	assert.Equal(t, int64(0444), h.Mode)
+
 	h, err = r.Next()
 	assert.NoError(t, err)
 	assert.Equal(t, "symlink", h.Name)
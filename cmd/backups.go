@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+var backupsCmd = &cobra.Command{
+	Use:     "backups",
+	Args:    cobra.NoArgs,
+	Short:   "Manage backups of overwritten and removed targets",
+	Long:    mustGetLongHelp("backups"),
+	Example: getExample("backups"),
+}
+
+var backupsListCmd = &cobra.Command{
+	Use:     "list [backup]",
+	Args:    cobra.MaximumNArgs(1),
+	Short:   "List backups, or the targets in a backup",
+	PreRunE: config.ensureNoError,
+	RunE:    config.runBackupsListCmd,
+}
+
+var backupsPruneCmd = &cobra.Command{
+	Use:     "prune",
+	Args:    cobra.NoArgs,
+	Short:   "Remove old backups",
+	PreRunE: config.ensureNoError,
+	RunE:    config.runBackupsPruneCmd,
+}
+
+var backupsRestoreCmd = &cobra.Command{
+	Use:     "restore backup [targets...]",
+	Args:    cobra.MinimumNArgs(1),
+	Short:   "Restore targets from a backup",
+	PreRunE: config.ensureNoError,
+	RunE:    config.runBackupsRestoreCmd,
+}
+
+type backupConfig struct {
+	Enabled bool
+	Dir     string
+}
+
+type backupsCmdConfig struct {
+	keep      int
+	olderThan time.Duration
+}
+
+func init() {
+	rootCmd.AddCommand(backupsCmd)
+	backupsCmd.AddCommand(backupsListCmd)
+	backupsCmd.AddCommand(backupsPruneCmd)
+	backupsCmd.AddCommand(backupsRestoreCmd)
+
+	persistentFlags := backupsPruneCmd.PersistentFlags()
+	persistentFlags.IntVar(&config.backups.keep, "keep", 0, "keep this many of the most recent backups")
+	persistentFlags.DurationVar(&config.backups.olderThan, "older-than", 0, "remove backups older than this")
+
+	markRemainingZshCompPositionalArgumentsAsFiles(backupsRestoreCmd, 2)
+}
+
+func (c *Config) runBackupsListCmd(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 {
+		backupDir, err := c.getBackupDir(args[0])
+		if err != nil {
+			return err
+		}
+		return vfs.Walk(c.fs, backupDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == backupDir || info.IsDir() {
+				return nil
+			}
+			fmt.Fprintln(c.Stdout, filepath.Join(c.DestDir, strings.TrimPrefix(path, backupDir+string(filepath.Separator))))
+			return nil
+		})
+	}
+
+	backups, err := c.getBackups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		fmt.Fprintln(c.Stdout, backup)
+	}
+	return nil
+}
+
+func (c *Config) runBackupsPruneCmd(cmd *cobra.Command, args []string) error {
+	if c.backups.keep == 0 && c.backups.olderThan == 0 {
+		return fmt.Errorf("at least one of --keep or --older-than must be specified")
+	}
+	backups, err := c.getBackups()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i, backup := range backups {
+		// backups is sorted oldest first.
+		remove := c.backups.keep != 0 && i < len(backups)-c.backups.keep
+		if c.backups.olderThan != 0 {
+			backupTime, _ := time.Parse(chezmoi.BackupTimeFormat, backup)
+			if now.Sub(backupTime) > c.backups.olderThan {
+				remove = true
+			}
+		}
+		if !remove {
+			continue
+		}
+		if err := c.mutator.RemoveAll(filepath.Join(c.Backup.Dir, backup)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) runBackupsRestoreCmd(cmd *cobra.Command, args []string) error {
+	backupDir, err := c.getBackupDir(args[0])
+	if err != nil {
+		return err
+	}
+	destDir, err := filepath.Abs(c.DestDir)
+	if err != nil {
+		return err
+	}
+
+	var relPaths []string
+	for _, arg := range args[1:] {
+		targetPath, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(targetPath, destDir+string(filepath.Separator)) {
+			return fmt.Errorf("%s: not in destination directory (%s)", arg, destDir)
+		}
+		relPaths = append(relPaths, strings.TrimPrefix(targetPath, destDir+string(filepath.Separator)))
+	}
+
+	return vfs.Walk(c.fs, backupDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == backupDir {
+			return nil
+		}
+		relPath := strings.TrimPrefix(path, backupDir+string(filepath.Separator))
+		if !backupRelPathSelected(relPath, relPaths) {
+			return nil
+		}
+		targetPath := filepath.Join(destDir, relPath)
+		switch {
+		case info.IsDir():
+			if _, err := c.fs.Stat(targetPath); err == nil {
+				return nil
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+			return c.mutator.Mkdir(targetPath, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			data, err := c.fs.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			currData, err := c.fs.ReadFile(targetPath)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return c.mutator.WriteFile(targetPath, data, info.Mode().Perm(), currData)
+		case info.Mode()&os.ModeType == os.ModeSymlink:
+			linkname, err := c.fs.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return c.mutator.WriteSymlink(linkname, targetPath)
+		default:
+			return nil
+		}
+	})
+}
+
+// backupMutator returns m wrapped in a BackupMutator, if backups are enabled.
+func (c *Config) backupMutator(m chezmoi.Mutator) (chezmoi.Mutator, error) {
+	if !c.Backup.Enabled || c.DryRun {
+		return m, nil
+	}
+	destDir, err := filepath.Abs(c.DestDir)
+	if err != nil {
+		return nil, err
+	}
+	backupsDir, err := filepath.Abs(c.Backup.Dir)
+	if err != nil {
+		return nil, err
+	}
+	// Exclude all backups, not just this one, so that pruning or restoring
+	// earlier backups does not copy them into this one.
+	exclude := []string{backupsDir}
+	for _, sourceDir := range append([]string{c.SourceDir}, c.SourceDirs...) {
+		sourceDir, err := filepath.Abs(sourceDir)
+		if err != nil {
+			return nil, err
+		}
+		exclude = append(exclude, sourceDir)
+	}
+	backupDir := filepath.Join(backupsDir, time.Now().UTC().Format(chezmoi.BackupTimeFormat))
+	return chezmoi.NewBackupMutator(m, c.fs, destDir, backupDir, exclude...), nil
+}
+
+// getBackupDir returns the directory containing backup.
+func (c *Config) getBackupDir(backup string) (string, error) {
+	if _, err := time.Parse(chezmoi.BackupTimeFormat, backup); err != nil {
+		return "", fmt.Errorf("%s: invalid backup", backup)
+	}
+	backupDir := filepath.Join(c.Backup.Dir, backup)
+	if info, err := c.fs.Stat(backupDir); os.IsNotExist(err) {
+		return "", fmt.Errorf("%s: backup not found", backup)
+	} else if err != nil {
+		return "", err
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("%s: not a directory", backupDir)
+	}
+	return backupDir, nil
+}
+
+// getBackups returns the names of all backups, oldest first.
+func (c *Config) getBackups() ([]string, error) {
+	infos, err := c.fs.ReadDir(c.Backup.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(chezmoi.BackupTimeFormat, info.Name()); err != nil {
+			continue
+		}
+		backups = append(backups, info.Name())
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// backupRelPathSelected returns true if relPath should be restored given the
+// selected relPaths. If relPaths is empty then all paths are selected.
+// Directories containing selected paths are also selected so that they can be
+// recreated.
+func backupRelPathSelected(relPath string, relPaths []string) bool {
+	if len(relPaths) == 0 {
+		return true
+	}
+	for _, selected := range relPaths {
+		switch {
+		case relPath == selected:
+			return true
+		case strings.HasPrefix(relPath, selected+string(filepath.Separator)):
+			return true
+		case strings.HasPrefix(selected, relPath+string(filepath.Separator)):
+			return true
+		}
+	}
+	return false
+}
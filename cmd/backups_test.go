@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestBackups(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc":  "# old contents of .bashrc\n",
+			".dir/old": "# contents of .dir/old\n",
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_bashrc":        "# contents of .bashrc\n",
+				"exact_dot_dir/new": "# contents of .dir/new\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(fs,
+		withBackup(backupConfig{
+			Enabled: true,
+			Dir:     "/home/user/.local/state/chezmoi/backups",
+		}),
+		withStdout(stdout),
+	)
+	c.mutator, err = c.backupMutator(c.mutator)
+	require.NoError(t, err)
+	require.NoError(t, c.runApplyCmd(nil, nil))
+
+	stdout.Reset()
+	require.NoError(t, c.runBackupsListCmd(nil, nil))
+	backups := strings.Fields(stdout.String())
+	require.Len(t, backups, 1)
+	backup := backups[0]
+
+	stdout.Reset()
+	require.NoError(t, c.runBackupsListCmd(nil, []string{backup}))
+	assert.Equal(t, "/home/user/.bashrc\n/home/user/.dir/old\n", stdout.String())
+
+	require.NoError(t, c.runBackupsRestoreCmd(nil, []string{backup, "/home/user/.dir/old"}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.dir/old",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .dir/old\n"),
+		),
+	)
+
+	require.NoError(t, c.runBackupsRestoreCmd(nil, []string{backup}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# old contents of .bashrc\n"),
+		),
+	)
+
+	c.backups = backupsCmdConfig{
+		keep: 1,
+	}
+	require.NoError(t, c.runBackupsPruneCmd(nil, nil))
+	c.backups = backupsCmdConfig{
+		olderThan: time.Nanosecond,
+	}
+	require.NoError(t, c.runBackupsPruneCmd(nil, nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/state/chezmoi/backups/"+backup,
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
+func TestBackupsPruneDoesNotBackUpBackups(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": "# old contents of .bashrc\n",
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_bashrc": "# contents of .bashrc\n",
+			},
+			".local/state/chezmoi/backups/20200102T030405Z": map[string]interface{}{
+				".bashrc": "# older contents of .bashrc\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(fs,
+		withBackup(backupConfig{
+			Enabled: true,
+			Dir:     "/home/user/.local/state/chezmoi/backups",
+		}),
+		withStdout(stdout),
+	)
+	c.mutator, err = c.backupMutator(c.mutator)
+	require.NoError(t, err)
+	require.NoError(t, c.runApplyCmd(nil, nil))
+
+	stdout.Reset()
+	require.NoError(t, c.runBackupsListCmd(nil, nil))
+	backups := strings.Fields(stdout.String())
+	require.Len(t, backups, 2)
+	assert.Equal(t, "20200102T030405Z", backups[0])
+	backup := backups[1]
+
+	c.backups = backupsCmdConfig{
+		keep: 1,
+	}
+	require.NoError(t, c.runBackupsPruneCmd(nil, nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/state/chezmoi/backups/20200102T030405Z",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/state/chezmoi/backups/"+backup+"/.local",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/state/chezmoi/backups/"+backup+"/.bashrc",
+			vfst.TestContentsString("# old contents of .bashrc\n"),
+		),
+	)
+}
@@ -34,6 +34,14 @@ func (c *Config) runCatCmd(cmd *cobra.Command, args []string) error {
 	}
 	for i, entry := range entries {
 		switch entry := entry.(type) {
+		case *chezmoi.Block:
+			contents, err := entry.Contents()
+			if err != nil {
+				return err
+			}
+			if _, err := c.Stdout.Write(contents); err != nil {
+				return err
+			}
 		case *chezmoi.File:
 			contents, err := entry.Contents()
 			if err != nil {
@@ -42,6 +50,14 @@ func (c *Config) runCatCmd(cmd *cobra.Command, args []string) error {
 			if _, err := c.Stdout.Write(contents); err != nil {
 				return err
 			}
+		case *chezmoi.Merge:
+			contents, err := entry.Contents()
+			if err != nil {
+				return err
+			}
+			if _, err := c.Stdout.Write(contents); err != nil {
+				return err
+			}
 		case *chezmoi.Symlink:
 			linkname, err := entry.Linkname()
 			if err != nil {
@@ -49,7 +65,7 @@ func (c *Config) runCatCmd(cmd *cobra.Command, args []string) error {
 			}
 			fmt.Println(linkname)
 		default:
-			return fmt.Errorf("%s: not a file or symlink", args[i])
+			return fmt.Errorf("%s: not a file, block, merge, or symlink", args[i])
 		}
 	}
 	return nil
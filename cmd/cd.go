@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 	"github.com/twpayne/go-shell"
 )
 
@@ -32,5 +33,9 @@ func (c *Config) runCDCmd(cmd *cobra.Command, args []string) error {
 	if shellCommand == "" {
 		shellCommand, _ = shell.CurrentUserShell()
 	}
-	return c.run(c.SourceDir, shellCommand)
+	sourceRootDir, err := chezmoi.SourceRootDir(c.fs, c.SourceDir)
+	if err != nil {
+		return err
+	}
+	return c.run(sourceRootDir, shellCommand)
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -21,6 +22,10 @@ var chattrCmd = &cobra.Command{
 	RunE:    config.runChattrCmd,
 }
 
+type chattrCmdConfig struct {
+	manifest bool
+}
+
 type boolModifier int
 
 type attributeModifiers struct {
@@ -29,6 +34,7 @@ type attributeModifiers struct {
 	exact      boolModifier
 	executable boolModifier
 	private    boolModifier
+	readOnly   boolModifier
 	template   boolModifier
 }
 
@@ -41,12 +47,16 @@ func init() {
 		"exact",
 		"executable", "x",
 		"private", "p",
+		"readonly", "r",
 		"template", "t",
 	}
 	words := make([]string, 0, 4*len(attributes))
 	for _, attribute := range attributes {
 		words = append(words, attribute, "-"+attribute, "+"+attribute, "no"+attribute)
 	}
+	persistentFlags := chattrCmd.PersistentFlags()
+	persistentFlags.BoolVar(&config.chattr.manifest, "manifest", false, "change attributes in .chezmoiattributes instead of renaming")
+
 	panicOnError(chattrCmd.MarkZshCompPositionalArgumentWords(1, words...))
 	markRemainingZshCompPositionalArgumentsAsFiles(chattrCmd, 2)
 }
@@ -67,10 +77,18 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if c.chattr.manifest {
+		return c.chattrManifest(ts, entries, ams)
+	}
+
 	updates := make(map[string]func() error)
 	for _, entry := range entries {
-		dir, oldBase := filepath.Split(entry.SourceName())
-		oldpath := filepath.Join(ts.SourceDir, dir, oldBase)
+		sourcePath := ts.SourcePath(entry)
+		if err := ts.CopyToSourceDir(c.fs, entry, c.mutator); err != nil {
+			return err
+		}
+		oldpath := ts.SourcePath(entry)
+		dir, oldBase := filepath.Split(oldpath)
 		switch entry := entry.(type) {
 		case *chezmoi.Dir:
 			da := chezmoi.ParseDirAttributes(oldBase)
@@ -82,7 +100,7 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 			da.Perm = perm
 			newBase := da.SourceName()
 			if newBase != oldBase {
-				newpath := filepath.Join(ts.SourceDir, dir, newBase)
+				newpath := filepath.Join(dir, newBase)
 				updates[oldpath] = func() error {
 					return c.mutator.Rename(oldpath, newpath)
 				}
@@ -96,13 +114,16 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 			if private := ams.private.modify(entry.Private()); private {
 				mode &= 0700
 			}
+			if readOnly := ams.readOnly.modify(entry.ReadOnly()); readOnly {
+				mode &^= 0222
+			}
 			fa.Mode = mode
 			fa.Encrypted = ams.encrypt.modify(entry.Encrypted)
 			fa.Empty = ams.empty.modify(entry.Empty)
 			fa.Template = ams.template.modify(entry.Template)
-			newpath := filepath.Join(ts.SourceDir, dir, fa.SourceName())
+			newpath := filepath.Join(dir, fa.SourceName())
 			if fa.Encrypted != entry.Encrypted {
-				oldContents, err := c.fs.ReadFile(filepath.Join(c.SourceDir, entry.SourceName()))
+				oldContents, err := c.fs.ReadFile(sourcePath)
 				if err != nil {
 					return err
 				}
@@ -133,7 +154,7 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 			fa.Template = ams.template.modify(entry.Template)
 			newBase := fa.SourceName()
 			if newBase != oldBase {
-				newpath := filepath.Join(ts.SourceDir, dir, newBase)
+				newpath := filepath.Join(dir, newBase)
 				updates[oldpath] = func() error {
 					return c.mutator.Rename(oldpath, newpath)
 				}
@@ -158,6 +179,100 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// chattrManifest changes the attributes of entries by editing the attribute
+// manifests of the source directories that they are in.
+func (c *Config) chattrManifest(ts *chezmoi.TargetState, entries []chezmoi.Entry, ams *attributeModifiers) error {
+	manifests := make(map[string]*chezmoi.AttributeManifest)
+	oldData := make(map[string][]byte)
+	for _, entry := range entries {
+		if err := ts.CopyToSourceDir(c.fs, entry, c.mutator); err != nil {
+			return err
+		}
+		sourceDir := ts.EntryRoot(entry).SourceDir
+		manifest, ok := manifests[sourceDir]
+		if !ok {
+			var err error
+			manifest, err = chezmoi.ReadAttributeManifest(c.fs, sourceDir)
+			if err != nil {
+				return err
+			}
+			manifests[sourceDir] = manifest
+			oldData[sourceDir] = manifest.Bytes()
+		}
+		set := func(attribute string, bm boolModifier, value bool) error {
+			if newValue := bm.modify(value); newValue != value {
+				return manifest.Set(entry.SourceName(), attribute, newValue)
+			}
+			return nil
+		}
+		switch entry := entry.(type) {
+		case *chezmoi.Dir:
+			if err := set(chezmoi.AttributeExact, ams.exact, entry.Exact); err != nil {
+				return err
+			}
+			if err := set(chezmoi.AttributePrivate, ams.private, entry.Private()); err != nil {
+				return err
+			}
+		case *chezmoi.File:
+			for _, attribute := range []struct {
+				name  string
+				bm    boolModifier
+				value bool
+			}{
+				{chezmoi.AttributeEmpty, ams.empty, entry.Empty},
+				{chezmoi.AttributeEncrypted, ams.encrypt, entry.Encrypted},
+				{chezmoi.AttributeExecutable, ams.executable, entry.Executable()},
+				{chezmoi.AttributePrivate, ams.private, entry.Private()},
+				{chezmoi.AttributeReadOnly, ams.readOnly, entry.ReadOnly()},
+				{chezmoi.AttributeTemplate, ams.template, entry.Template},
+			} {
+				if err := set(attribute.name, attribute.bm, attribute.value); err != nil {
+					return err
+				}
+			}
+			if encrypted := ams.encrypt.modify(entry.Encrypted); encrypted != entry.Encrypted {
+				sourcePath := ts.SourcePath(entry)
+				oldContents, err := c.fs.ReadFile(sourcePath)
+				if err != nil {
+					return err
+				}
+				var newContents []byte
+				if encrypted {
+					newContents, err = ts.GPG.Encrypt(entry.TargetName(), oldContents)
+				} else {
+					newContents, err = ts.GPG.Decrypt(entry.TargetName(), oldContents)
+				}
+				if err != nil {
+					return err
+				}
+				if err := c.mutator.WriteFile(sourcePath, newContents, 0644, oldContents); err != nil {
+					return err
+				}
+			}
+		case *chezmoi.Symlink:
+			if err := set(chezmoi.AttributeTemplate, ams.template, entry.Template); err != nil {
+				return err
+			}
+		}
+	}
+
+	sourceDirs := make([]string, 0, len(manifests))
+	for sourceDir := range manifests {
+		sourceDirs = append(sourceDirs, sourceDir)
+	}
+	sort.Strings(sourceDirs)
+	for _, sourceDir := range sourceDirs {
+		newData := manifests[sourceDir].Bytes()
+		if bytes.Equal(newData, oldData[sourceDir]) {
+			continue
+		}
+		if err := c.mutator.WriteFile(chezmoi.AttributeManifestPath(sourceDir), newData, 0666&^os.FileMode(c.Umask), oldData[sourceDir]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 	ams := &attributeModifiers{}
 	for _, attributeModifier := range strings.Split(s, ",") {
@@ -192,6 +307,8 @@ func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 			ams.executable = modifier
 		case "private", "p":
 			ams.private = modifier
+		case "readonly", "r":
+			ams.readOnly = modifier
 		case "template", "t":
 			ams.template = modifier
 		default:
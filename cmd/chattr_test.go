@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 	"github.com/twpayne/go-vfs/vfst"
 )
 
@@ -191,6 +193,42 @@ func TestChattrCommand(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "file_add_readonly",
+			args: []string{"+readonly", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"executable_foo": "# contents of ~/foo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/executable_foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/readonly_executable_foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of ~/foo\n"),
+				),
+			},
+		},
+		{
+			name: "file_remove_readonly",
+			args: []string{"-r", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"private_readonly_foo": "# contents of ~/foo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/private_readonly_foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/private_foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of ~/foo\n"),
+				),
+			},
+		},
 		{
 			name: "file_add_template",
 			args: []string{"+template", "/home/user/foo"},
@@ -295,6 +333,59 @@ func TestChattrCommand(t *testing.T) {
 	}
 }
 
+func TestChattrCommandManifest(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoiattributes": "# attributes\nbin/* executable\n",
+			"bin/tool":           "#!/bin/sh\n",
+			"dir":                &vfst.Dir{Perm: 0755},
+			"dot_bashrc":         "# contents of .bashrc\n",
+			"dot_netrc":          "# contents of .netrc\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(fs, func(c *Config) {
+		c.chattr.manifest = true
+	})
+	require.NoError(t, c.runChattrCmd(nil, []string{"private,template", "/home/user/.netrc"}))
+	require.NoError(t, c.runChattrCmd(nil, []string{"-t", "/home/user/.netrc"}))
+	require.NoError(t, c.runChattrCmd(nil, []string{"exact", "/home/user/dir"}))
+	require.NoError(t, c.runChattrCmd(nil, []string{"-x", "/home/user/bin/tool"}))
+	require.NoError(t, c.runChattrCmd(nil, []string{"-x", "/home/user/.bashrc"}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi/.chezmoiattributes",
+			vfst.TestContentsString(strings.Join([]string{
+				"# attributes",
+				"bin/* executable",
+				"dot_netrc private -template",
+				"dir exact",
+				"bin/tool -executable",
+			}, "\n")+"\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_netrc",
+			vfst.TestContentsString("# contents of .netrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dir",
+			vfst.TestIsDir,
+		),
+	)
+
+	ts, err := c.getTargetState(nil)
+	require.NoError(t, err)
+	netrc, err := ts.Get(fs, "/home/user/.netrc")
+	require.NoError(t, err)
+	assert.True(t, netrc.(*chezmoi.File).Private())
+	assert.False(t, netrc.(*chezmoi.File).Template)
+	dir, err := ts.Get(fs, "/home/user/dir")
+	require.NoError(t, err)
+	assert.True(t, dir.(*chezmoi.Dir).Exact)
+	tool, err := ts.Get(fs, "/home/user/bin/tool")
+	require.NoError(t, err)
+	assert.False(t, tool.(*chezmoi.File).Executable())
+}
+
 func TestParseAttributeModifiers(t *testing.T) {
 	for _, tc := range []struct {
 		s       string
@@ -325,6 +416,14 @@ func TestParseAttributeModifiers(t *testing.T) {
 		{s: "+p", want: &attributeModifiers{private: 1}},
 		{s: "-p", want: &attributeModifiers{private: -1}},
 		{s: "nop", want: &attributeModifiers{private: -1}},
+		{s: "readonly", want: &attributeModifiers{readOnly: 1}},
+		{s: "+readonly", want: &attributeModifiers{readOnly: 1}},
+		{s: "-readonly", want: &attributeModifiers{readOnly: -1}},
+		{s: "noreadonly", want: &attributeModifiers{readOnly: -1}},
+		{s: "r", want: &attributeModifiers{readOnly: 1}},
+		{s: "+r", want: &attributeModifiers{readOnly: 1}},
+		{s: "-r", want: &attributeModifiers{readOnly: -1}},
+		{s: "nor", want: &attributeModifiers{readOnly: -1}},
 		{s: "template", want: &attributeModifiers{template: 1}},
 		{s: "+template", want: &attributeModifiers{template: 1}},
 		{s: "-template", want: &attributeModifiers{template: -1}},
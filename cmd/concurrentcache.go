@@ -0,0 +1,55 @@
+package cmd
+
+import "sync"
+
+// A concurrentCache is a cache that is safe for concurrent use. Concurrent
+// lookups of the same key are deduplicated so that the value for each key is
+// only computed once. The zero value is an empty cache ready to use.
+type concurrentCache struct {
+	mutex   sync.Mutex
+	entries map[interface{}]*concurrentCacheEntry
+}
+
+type concurrentCacheEntry struct {
+	done       chan struct{}
+	value      interface{}
+	panicValue interface{}
+}
+
+// get returns the value for key, calling f to compute it if it is not already
+// cached. Template functions report errors by panicking, so if f panics then
+// the panic is propagated to all callers waiting for key and nothing is
+// cached.
+func (cc *concurrentCache) get(key interface{}, f func() interface{}) interface{} {
+	cc.mutex.Lock()
+	if entry, ok := cc.entries[key]; ok {
+		cc.mutex.Unlock()
+		<-entry.done
+		if entry.panicValue != nil {
+			panic(entry.panicValue)
+		}
+		return entry.value
+	}
+	if cc.entries == nil {
+		cc.entries = make(map[interface{}]*concurrentCacheEntry)
+	}
+	entry := &concurrentCacheEntry{
+		done: make(chan struct{}),
+	}
+	cc.entries[key] = entry
+	cc.mutex.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			entry.panicValue = r
+			cc.mutex.Lock()
+			delete(cc.entries, key)
+			cc.mutex.Unlock()
+			close(entry.done)
+			panic(r)
+		}
+	}()
+	entry.value = f()
+	close(entry.done)
+	return entry.value
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentCache(t *testing.T) {
+	var cc concurrentCache
+	var calls int32
+	release := make(chan struct{})
+	wg := sync.WaitGroup{}
+	values := make([]interface{}, 8)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i] = cc.get("key", func() interface{} {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "value"
+			})
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, value := range values {
+		assert.Equal(t, "value", value)
+	}
+	assert.Equal(t, "value", cc.get("key", func() interface{} {
+		panic("not reached")
+	}))
+}
+
+func TestConcurrentCachePanic(t *testing.T) {
+	var cc concurrentCache
+	errLookup := errors.New("lookup failed")
+	assert.PanicsWithValue(t, errLookup, func() {
+		cc.get("key", func() interface{} {
+			panic(errLookup)
+		})
+	})
+	// Failed lookups are not cached.
+	assert.Equal(t, "value", cc.get("key", func() interface{} {
+		return "value"
+	}))
+}
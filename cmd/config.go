@@ -22,23 +22,46 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/twpayne/chezmoi/internal/chezmoi"
+	"github.com/twpayne/chezmoi/internal/git"
 	vfs "github.com/twpayne/go-vfs"
 	xdg "github.com/twpayne/go-xdg/v3"
 	bolt "go.etcd.io/bbolt"
 	yaml "gopkg.in/yaml.v2"
 )
 
-const commitMessageTemplateAsset = "assets/templates/COMMIT_MESSAGE.tmpl"
+const (
+	commitMessageTemplateAsset = "assets/templates/COMMIT_MESSAGE.tmpl"
+	commitMessageTemplateName  = ".chezmoi/commit-message.tmpl"
+)
 
 var whitespaceRegexp = regexp.MustCompile(`\s+`)
 
 type sourceVCSConfig struct {
+	Command               string
+	AutoCommit            bool
+	AutoPush              bool
+	CommitMessageTemplate string
+	Init                  interface{}
+	NotGit                bool
+	Pull                  interface{}
+	Sign                  bool
+	SigningKey            string
+}
+
+// A commitOperation is an operation performed on a target that is included in
+// auto-generated commit messages.
+type commitOperation struct {
+	Operation  string
+	TargetName string
+	SourceName string
+}
+
+// A commitMessageData is the data passed to the commit message template.
+type commitMessageData struct {
+	*git.Status
 	Command    string
-	AutoCommit bool
-	AutoPush   bool
-	Init       interface{}
-	NotGit     bool
-	Pull       interface{}
+	Hostname   string
+	Operations []commitOperation
 }
 
 type templateConfig struct {
@@ -52,11 +75,16 @@ type Config struct {
 	fs                vfs.FS
 	mutator           chezmoi.Mutator
 	SourceDir         string
+	SourceDirs        []string
 	DestDir           string
 	Umask             permValue
+	Concurrency       int
 	DryRun            bool
 	Follow            bool
-	Remove            bool
+	Mode              chezmoi.Mode
+	Remove            string
+	MaxRemove         int
+	Strict            bool
 	Verbose           bool
 	Color             string
 	Debug             bool
@@ -65,6 +93,11 @@ type Config struct {
 	SourceVCS         sourceVCSConfig
 	Template          templateConfig
 	Merge             mergeConfig
+	Hooks             map[string]hooksConfig
+	Roots             map[string]rootConfig
+	Escalation        escalationConfig
+	AuditLog          auditLogConfig
+	Backup            backupConfig
 	Bitwarden         bitwardenCmdConfig
 	CD                cdCmdConfig
 	Diff              diffCmdConfig
@@ -76,19 +109,30 @@ type Config struct {
 	Vault             vaultCmdConfig
 	Pass              passCmdConfig
 	Data              map[string]interface{}
+	auditLog          *chezmoi.AuditLog
+	auditLogFile      *os.File
 	colored           bool
+	hookMutator       *chezmoi.AnyMutator
+	commitOperations  []commitOperation
 	maxDiffDataSize   int
 	templateFuncs     template.FuncMap
+	timings           *chezmoi.Timings
 	add               addCmdConfig
+	apply             applyCmdConfig
+	backupFlag        string
+	backups           backupsCmdConfig
+	chattr            chattrCmdConfig
 	completion        completionCmdConfig
 	data              dataCmdConfig
 	dump              dumpCmdConfig
 	edit              editCmdConfig
 	executeTemplate   executeTemplateCmdConfig
+	history           historyCmdConfig
 	_import           importCmdConfig
 	init              initCmdConfig
 	keyring           keyringCmdConfig
 	managed           managedCmdConfig
+	profiling         profilingConfig
 	purge             purgeCmdConfig
 	remove            removeCmdConfig
 	update            updateCmdConfig
@@ -97,6 +141,10 @@ type Config struct {
 	Stdout            io.Writer
 	Stderr            io.Writer
 	bds               *xdg.BaseDirectorySpecification
+	fileStateBucket   []byte
+	blocksBucket      []byte
+	managedBucket     []byte
+	outputCacheBucket []byte
 	scriptStateBucket []byte
 }
 
@@ -133,8 +181,10 @@ var (
 // newConfig creates a new Config with the given options.
 func newConfig(options ...configOption) *Config {
 	c := &Config{
-		Umask: permValue(getUmask()),
-		Color: "auto",
+		Umask:       permValue(getUmask()),
+		Concurrency: runtime.NumCPU(),
+		Mode:        chezmoi.ModeFile,
+		Color:       "auto",
 		SourceVCS: sourceVCSConfig{
 			Command: "git",
 		},
@@ -150,8 +200,16 @@ func newConfig(options ...configOption) *Config {
 		GPG: chezmoi.GPG{
 			Command: "gpg",
 		},
+		Escalation: escalationConfig{
+			Command: "sudo",
+		},
+		MaxRemove:         100,
 		maxDiffDataSize:   1 * 1024 * 1024, // 1MB
 		templateFuncs:     sprig.TxtFuncMap(),
+		fileStateBucket:   []byte("fileState"),
+		blocksBucket:      []byte("managedBlocks"),
+		managedBucket:     []byte("managedTargets"),
+		outputCacheBucket: []byte("templateOutput"),
 		scriptStateBucket: []byte("script"),
 		Stdin:             os.Stdin,
 		Stdout:            os.Stdout,
@@ -175,37 +233,85 @@ func (c *Config) addTemplateFunc(key string, value interface{}) {
 
 func (c *Config) applyArgs(args []string, persistentState chezmoi.PersistentState) error {
 	fs := vfs.NewReadOnlyFS(c.fs)
-	ts, err := c.getTargetState(nil)
+	var targetStateOptions []chezmoi.TargetStateOption
+	if !c.Strict {
+		targetStateOptions = append(targetStateOptions, chezmoi.WithTemplateOutputCache(persistentState, c.outputCacheBucket))
+	}
+	ts, err := c.getTargetState(nil, targetStateOptions...)
 	if err != nil {
 		return err
 	}
+	remove, reportRemove := c.getRemove()
 	applyOptions := &chezmoi.ApplyOptions{
-		DestDir:           ts.DestDir,
-		DryRun:            c.DryRun,
-		Ignore:            ts.TargetIgnore.Match,
-		PersistentState:   persistentState,
-		Remove:            c.Remove,
-		ScriptStateBucket: c.scriptStateBucket,
-		Stdout:            c.Stdout,
-		Umask:             ts.Umask,
-		Verbose:           c.Verbose,
+		AuditLog:             c.auditLog,
+		DestDir:              ts.DestDir,
+		DryRun:               c.DryRun,
+		FileStateBucket:      c.fileStateBucket,
+		Ignore:               ts.Ignore,
+		KeepGoing:            c.apply.keepGoing,
+		ManagedBlocksBucket:  c.blocksBucket,
+		ManagedTargetsBucket: c.managedBucket,
+		MaxRemove:            c.MaxRemove,
+		Mode:                 c.Mode,
+		PersistentState:      persistentState,
+		Remove:               remove,
+		ScriptStateBucket:    c.scriptStateBucket,
+		SourceDir:            ts.EntrySourceDir,
+		Stdout:               c.Stdout,
+		Strict:               c.Strict,
+		Timings:              c.timings,
+		Umask:                ts.Umask,
+		Verbose:              c.Verbose,
 	}
-	if len(args) == 0 {
-		return ts.Apply(fs, c.mutator, c.Follow, applyOptions)
+	switch {
+	case reportRemove:
+		applyOptions.ConfirmRemove = c.reportRemove
+	case remove && !c.DryRun && !c.apply.force:
+		applyOptions.ConfirmRemove = c.confirmRemove
 	}
-	entries, err := c.getEntries(ts, args)
-	if err != nil {
-		return err
+	mutator := c.mutator
+	var triggerMutator *chezmoi.AnyMutator
+	if len(ts.Triggers) != 0 {
+		triggerMutator = chezmoi.NewAnyMutator(mutator)
+		mutator = triggerMutator
 	}
-	for _, entry := range entries {
-		if err := entry.Apply(fs, c.mutator, c.Follow, applyOptions); err != nil {
+	if len(args) == 0 {
+		if err := ts.Apply(fs, mutator, c.Follow, applyOptions); err != nil && len(applyOptions.Errors) == 0 {
 			return err
 		}
+	} else {
+		entries, err := c.getEntries(ts, args)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := ts.ApplyEntry(fs, mutator, c.Follow, applyOptions, entry); err != nil {
+				if !applyOptions.KeepGoing {
+					return err
+				}
+				root := ts.EntryRoot(entry)
+				applyOptions.Errors = append(applyOptions.Errors, &chezmoi.ApplyError{
+					TargetName: entry.TargetName(),
+					SourceName: entry.SourceName(),
+					Err:        err,
+					DestDir:    root.DestDir,
+					SourceDir:  root.EntrySourceDir(entry.TargetName()),
+				})
+			}
+		}
+	}
+	if triggerMutator != nil {
+		if err := c.runTriggers(ts, triggerMutator.MutatedPaths()); err != nil {
+			return err
+		}
+	}
+	if len(applyOptions.Errors) != 0 {
+		return c.reportApplyErrors(ts, applyOptions.Errors)
 	}
 	return nil
 }
 
-func (c *Config) autoCommit(vcs VCS) error {
+func (c *Config) autoCommit(vcs VCS, command string) error {
 	addArgs := vcs.AddArgs(".")
 	if addArgs == nil {
 		return fmt.Errorf("%s: autocommit not supported", c.SourceVCS.Command)
@@ -221,7 +327,15 @@ func (c *Config) autoCommit(vcs VCS) error {
 	if err != nil {
 		return err
 	}
-	commitMessageText, err := getAsset(commitMessageTemplateAsset)
+	gitStatus, ok := status.(*git.Status)
+	if !ok || gitStatus == nil {
+		gitStatus = &git.Status{}
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	commitMessageText, err := c.getCommitMessageTemplate()
 	if err != nil {
 		return err
 	}
@@ -230,10 +344,18 @@ func (c *Config) autoCommit(vcs VCS) error {
 		return err
 	}
 	b := &bytes.Buffer{}
-	if err := commitMessageTmpl.Execute(b, status); err != nil {
+	if err := commitMessageTmpl.Execute(b, &commitMessageData{
+		Status:     gitStatus,
+		Command:    command,
+		Hostname:   hostname,
+		Operations: c.commitOperations,
+	}); err != nil {
 		return err
 	}
-	commitArgs := vcs.CommitArgs(b.String())
+	commitArgs := vcs.CommitArgs(b.String(), &vcsCommitOptions{
+		Sign:       c.SourceVCS.Sign,
+		SigningKey: c.SourceVCS.SigningKey,
+	})
 	return c.run(c.SourceDir, c.SourceVCS.Command, commitArgs...)
 }
 
@@ -246,7 +368,8 @@ func (c *Config) autoCommitAndAutoPush(cmd *cobra.Command, args []string) error
 		return nil
 	}
 	if c.SourceVCS.AutoCommit || c.SourceVCS.AutoPush {
-		if err := c.autoCommit(vcs); err != nil {
+		command := chezmoi.ShellQuoteArgs(append([]string{cmd.CommandPath()}, args...))
+		if err := c.autoCommit(vcs, command); err != nil {
 			return err
 		}
 	}
@@ -300,6 +423,24 @@ func (c *Config) ensureSourceDirectory() error {
 	}
 }
 
+// getCommitMessageTemplate returns the commit message template. The template
+// in the config file takes precedence over the template in the source
+// directory, which takes precedence over the built-in template.
+func (c *Config) getCommitMessageTemplate() ([]byte, error) {
+	if c.SourceVCS.CommitMessageTemplate != "" {
+		return []byte(c.SourceVCS.CommitMessageTemplate), nil
+	}
+	data, err := c.fs.ReadFile(filepath.Join(c.SourceDir, commitMessageTemplateName))
+	switch {
+	case err == nil:
+		return data, nil
+	case os.IsNotExist(err):
+		return getAsset(commitMessageTemplateAsset)
+	default:
+		return nil, err
+	}
+}
+
 func (c *Config) getData() (map[string]interface{}, error) {
 	defaultData, err := c.getDefaultData()
 	if err != nil {
@@ -406,6 +547,24 @@ func (c *Config) getEntries(ts *chezmoi.TargetState, args []string) ([]chezmoi.E
 	return entries, nil
 }
 
+// getSourceDirEntries returns the entries for args, which must all come from
+// the selected source directory. Only the selected source directory is
+// modified and committed, so entries from other source directories are never
+// removed.
+func (c *Config) getSourceDirEntries(ts *chezmoi.TargetState, args []string) ([]chezmoi.Entry, error) {
+	entries, err := c.getEntries(ts, args)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		root := ts.EntryRoot(entry)
+		if sourceDir := root.EntrySourceDir(entry.TargetName()); sourceDir != root.SourceDir {
+			return nil, fmt.Errorf("%s: in source directory %s, not %s", ts.TargetPath(entry), sourceDir, root.SourceDir)
+		}
+	}
+	return entries, nil
+}
+
 func (c *Config) getPersistentState(options *bolt.Options) (chezmoi.PersistentState, error) {
 	persistentStateFile := c.getPersistentStateFile()
 	if c.DryRun {
@@ -430,7 +589,7 @@ func (c *Config) getPersistentStateFile() string {
 	return filepath.Join(filepath.Dir(getDefaultConfigFile(c.bds)), "chezmoistate.boltdb")
 }
 
-func (c *Config) getTargetState(populateOptions *chezmoi.PopulateOptions) (*chezmoi.TargetState, error) {
+func (c *Config) getTargetState(populateOptions *chezmoi.PopulateOptions, options ...chezmoi.TargetStateOption) (*chezmoi.TargetState, error) {
 	fs := vfs.NewReadOnlyFS(c.fs)
 
 	data, err := c.getData()
@@ -451,15 +610,57 @@ func (c *Config) getTargetState(populateOptions *chezmoi.PopulateOptions) (*chez
 		c.GPG.Recipient = c.GPGRecipient
 	}
 
-	ts := chezmoi.NewTargetState(
-		chezmoi.WithDestDir(destDir),
-		chezmoi.WithGPG(&c.GPG),
-		chezmoi.WithSourceDir(c.SourceDir),
-		chezmoi.WithTemplateData(data),
-		chezmoi.WithTemplateFuncs(c.templateFuncs),
-		chezmoi.WithTemplateOptions(c.Template.Options),
-		chezmoi.WithUmask(os.FileMode(c.Umask)),
-	)
+	sourceDir, err := chezmoi.SourceRootDir(fs, c.SourceDir)
+	if err != nil {
+		return nil, err
+	}
+	var sourceDirs []string
+	for _, sd := range c.SourceDirs {
+		sourceRootDir, err := chezmoi.SourceRootDir(fs, sd)
+		if err != nil {
+			return nil, err
+		}
+		sourceDirs = append(sourceDirs, sourceRootDir)
+	}
+
+	newTargetState := func(sourceDir string, sourceDirs []string, destDir string, umask os.FileMode, extraOptions ...chezmoi.TargetStateOption) *chezmoi.TargetState {
+		return chezmoi.NewTargetState(append(append([]chezmoi.TargetStateOption{
+			chezmoi.WithConcurrency(c.Concurrency),
+			chezmoi.WithDestDir(destDir),
+			chezmoi.WithGPG(&c.GPG),
+			chezmoi.WithSourceDir(sourceDir),
+			chezmoi.WithSourceDirs(sourceDirs),
+			chezmoi.WithTemplateData(data),
+			chezmoi.WithTemplateFuncs(c.templateFuncs),
+			chezmoi.WithTemplateOptions(c.Template.Options),
+			chezmoi.WithTimings(c.timings),
+			chezmoi.WithUmask(umask),
+		}, options...), extraOptions...)...)
+	}
+
+	// Each root other than the home root is populated from the top-level
+	// source directory with its name.
+	roots := make(map[string]*chezmoi.TargetState)
+	for name, root := range c.Roots {
+		if name == homeRootName {
+			continue
+		}
+		rootDestDir, err := filepath.Abs(root.DestDir)
+		if err != nil {
+			return nil, err
+		}
+		umask := c.Umask
+		if root.Umask != nil {
+			umask = *root.Umask
+		}
+		var rootSourceDirs []string
+		for _, sd := range sourceDirs {
+			rootSourceDirs = append(rootSourceDirs, filepath.Join(sd, name))
+		}
+		roots[name] = newTargetState(filepath.Join(sourceDir, name), rootSourceDirs, rootDestDir, os.FileMode(umask))
+	}
+
+	ts := newTargetState(sourceDir, sourceDirs, destDir, os.FileMode(c.Umask), chezmoi.WithRoots(roots))
 	if err := ts.Populate(fs, populateOptions); err != nil {
 		return nil, err
 	}
@@ -491,7 +692,13 @@ func (c *Config) output(dir, name string, argv ...string) ([]byte, error) {
 
 //nolint:unparam
 func (c *Config) prompt(s, choices string) (byte, error) {
-	r := bufio.NewReader(c.Stdin)
+	// Keep the buffered reader so that input read ahead by one prompt is
+	// available to the next.
+	r, ok := c.Stdin.(*bufio.Reader)
+	if !ok {
+		r = bufio.NewReader(c.Stdin)
+		c.Stdin = r
+	}
 	for {
 		_, err := fmt.Printf("%s [%s]? ", s, strings.Join(strings.Split(choices, ""), ","))
 		if err != nil {
@@ -508,6 +715,16 @@ func (c *Config) prompt(s, choices string) (byte, error) {
 	}
 }
 
+// recordCommitOperation records that operation was performed on entry for
+// inclusion in auto-generated commit messages.
+func (c *Config) recordCommitOperation(operation string, entry chezmoi.Entry) {
+	c.commitOperations = append(c.commitOperations, commitOperation{
+		Operation:  operation,
+		TargetName: entry.TargetName(),
+		SourceName: entry.SourceName(),
+	})
+}
+
 // run runs name argv... in dir.
 func (c *Config) run(dir, name string, argv ...string) error {
 	cmd := exec.Command(name, argv...)
@@ -529,6 +746,30 @@ func (c *Config) runEditor(argv ...string) error {
 	return c.run("", editorName, append(editorArgs, argv...)...)
 }
 
+// selectSourceDir selects the source directory that is modified when
+// sourceDirs is set. This is the source directory given explicitly, which
+// must be one of sourceDirs, or the last of sourceDirs.
+func (c *Config) selectSourceDir(cmd *cobra.Command) error {
+	if len(c.SourceDirs) == 0 {
+		return nil
+	}
+	for i, sourceDir := range c.SourceDirs {
+		c.SourceDirs[i] = filepath.Clean(sourceDir)
+	}
+	sourceDir := filepath.Clean(c.SourceDir)
+	for _, sd := range c.SourceDirs {
+		if sd == sourceDir {
+			c.SourceDir = sourceDir
+			return nil
+		}
+	}
+	if cmd.Flags().Changed("source") || viper.IsSet("sourceDir") {
+		return fmt.Errorf("%s: not in sourceDirs", c.SourceDir)
+	}
+	c.SourceDir = c.SourceDirs[len(c.SourceDirs)-1]
+	return nil
+}
+
 func (c *Config) validateData() error {
 	return validateKeys(config.Data, identifierRegexp)
 }
@@ -555,6 +796,15 @@ func getDefaultConfigFile(bds *xdg.BaseDirectorySpecification) string {
 	return filepath.Join(bds.ConfigHome, "chezmoi", "chezmoi.toml")
 }
 
+// getDefaultStateDir returns the default directory for chezmoi's state, using
+// $XDG_STATE_HOME, which is not yet supported by go-xdg.
+func getDefaultStateDir(homeDir string) string {
+	if stateHome := os.Getenv("XDG_STATE_HOME"); stateHome != "" {
+		return filepath.Join(stateHome, "chezmoi")
+	}
+	return filepath.Join(homeDir, ".local", "state", "chezmoi")
+}
+
 func getDefaultSourceDir(bds *xdg.BaseDirectorySpecification) string {
 	// Check for XDG Base Directory Specification data directories first.
 	for _, dataDir := range bds.DataDirs {
@@ -579,6 +829,14 @@ func panicOnError(err error) {
 	}
 }
 
+// An exitCodeError is returned by commands that exit with a non-zero exit code
+// without printing an error, so that they exit through Execute.
+type exitCodeError int
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
 func printErrorAndExit(err error) {
 	fmt.Printf("chezmoi: %v\n", err)
 	os.Exit(1)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 	"text/template"
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/twpayne/chezmoi/internal/chezmoi"
 	vfs "github.com/twpayne/go-vfs"
+	"github.com/twpayne/go-vfs/vfst"
 	xdg "github.com/twpayne/go-xdg/v3"
 )
 
@@ -68,6 +70,108 @@ func TestAutoCommitCommitMessage(t *testing.T) {
 	}
 }
 
+func TestCommitMessageTemplate(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name            string
+		root            interface{}
+		sourceVCSConfig sourceVCSConfig
+		expectedArgv    []string
+	}{
+		{
+			name:         "builtin",
+			root:         map[string]interface{}{},
+			expectedArgv: []string{"git", "commit", "--message", "Add main.go\n"},
+		},
+		{
+			name: "source_dir",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoi/commit-message.tmpl": "{{ range .Operations }}{{ .Operation }} {{ .TargetName }} ({{ .SourceName }}){{ end }} by {{ .Command }} on {{ .Hostname }}",
+			},
+			expectedArgv: []string{"git", "commit", "--message", "add .bashrc (dot_bashrc) by chezmoi add on " + hostname},
+		},
+		{
+			name: "config",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoi/commit-message.tmpl": "source dir",
+			},
+			sourceVCSConfig: sourceVCSConfig{
+				CommitMessageTemplate: "{{ len .Ordinary }} file(s) changed",
+			},
+			expectedArgv: []string{"git", "commit", "--message", "1 file(s) changed"},
+		},
+		{
+			name: "signed",
+			root: map[string]interface{}{},
+			sourceVCSConfig: sourceVCSConfig{
+				Sign:       true,
+				SigningKey: "0123456789ABCDEF",
+			},
+			expectedArgv: []string{"git", "commit", "--message", "Add main.go\n", "--gpg-sign=0123456789ABCDEF"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+
+			var commitArgv []string
+			mutator := &cmdMutator{
+				Mutator: chezmoi.NullMutator{},
+				cmdFunc: func(argv []string) ([]byte, error) {
+					switch argv[1] {
+					case "status":
+						return []byte("1 A. N... 000000 100644 100644 0000000000000000000000000000000000000000 cea5c3500651a923bacd80f960dd20f04f71d509 main.go\n"), nil
+					case "commit":
+						commitArgv = argv
+					}
+					return nil, nil
+				},
+			}
+			c := newTestConfig(fs, withMutator(mutator))
+			c.SourceVCS = tc.sourceVCSConfig
+			c.SourceVCS.Command = "git"
+			c.commitOperations = append(c.commitOperations, commitOperation{
+				Operation:  "add",
+				TargetName: ".bashrc",
+				SourceName: "dot_bashrc",
+			})
+
+			require.NoError(t, c.autoCommit(gitVCS{}, "chezmoi add"))
+			assert.Equal(t, tc.expectedArgv, commitArgv)
+		})
+	}
+}
+
+func TestGitVCSCommitArgs(t *testing.T) {
+	for _, tc := range []struct {
+		options      *vcsCommitOptions
+		expectedArgs []string
+	}{
+		{
+			options:      nil,
+			expectedArgs: []string{"commit", "--message", "message"},
+		},
+		{
+			options: &vcsCommitOptions{
+				Sign: true,
+			},
+			expectedArgs: []string{"commit", "--message", "message", "--gpg-sign"},
+		},
+		{
+			options: &vcsCommitOptions{
+				Sign:       true,
+				SigningKey: "0123456789ABCDEF",
+			},
+			expectedArgs: []string{"commit", "--message", "message", "--gpg-sign=0123456789ABCDEF"},
+		},
+	} {
+		assert.Equal(t, tc.expectedArgs, gitVCS{}.CommitArgs("message", tc.options))
+	}
+}
+
 func TestUpperSnakeCaseToCamelCase(t *testing.T) {
 	for s, want := range map[string]string{
 		"BUG_REPORT_URL":   "bugReportURL",
@@ -149,6 +253,24 @@ func withAddCmdConfig(add addCmdConfig) configOption {
 	}
 }
 
+func withApplyCmdConfig(apply applyCmdConfig) configOption {
+	return func(c *Config) {
+		c.apply = apply
+	}
+}
+
+func withAuditLog(auditLog auditLogConfig) configOption {
+	return func(c *Config) {
+		c.AuditLog = auditLog
+	}
+}
+
+func withBackup(backup backupConfig) configOption {
+	return func(c *Config) {
+		c.Backup = backup
+	}
+}
+
 func withData(data map[string]interface{}) configOption {
 	return func(c *Config) {
 		c.Data = data
@@ -179,13 +301,41 @@ func withGenericSecretCmdConfig(genericSecretCmdConfig genericSecretCmdConfig) c
 	}
 }
 
+func withHooks(hooks map[string]hooksConfig) configOption {
+	return func(c *Config) {
+		c.Hooks = hooks
+	}
+}
+
 func withMutator(mutator chezmoi.Mutator) configOption {
 	return func(c *Config) {
 		c.mutator = mutator
 	}
 }
 
-func withRemove(remove bool) configOption {
+// A cmdMutator wraps a chezmoi.Mutator and passes the arguments of commands to
+// a function instead of running them.
+type cmdMutator struct {
+	chezmoi.Mutator
+	cmdFunc func(argv []string) ([]byte, error)
+}
+
+func (m *cmdMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.cmdFunc(cmd.Args)
+}
+
+func (m *cmdMutator) RunCmd(cmd *exec.Cmd) error {
+	_, err := m.cmdFunc(cmd.Args)
+	return err
+}
+
+func withMaxRemove(maxRemove int) configOption {
+	return func(c *Config) {
+		c.MaxRemove = maxRemove
+	}
+}
+
+func withRemove(remove string) configOption {
 	return func(c *Config) {
 		c.Remove = remove
 	}
@@ -197,6 +347,12 @@ func withRemoveCmdConfig(remove removeCmdConfig) configOption {
 	}
 }
 
+func withRoots(roots map[string]rootConfig) configOption {
+	return func(c *Config) {
+		c.Roots = roots
+	}
+}
+
 func withStdin(stdin io.Reader) configOption {
 	return func(c *Config) {
 		c.Stdin = stdin
@@ -601,6 +601,14 @@ func init() {
 		"accidentally add a secret in plain text, that secret will be pushed to your\n" +
 		"public repo.\n" +
 		"\n" +
+		"The automatically-generated commit message can be customized by setting\n" +
+		"`sourceVCS.commitMessageTemplate` in your config file, or by creating a\n" +
+		"`.chezmoi/commit-message.tmpl` file in your source directory. See the\n" +
+		"[reference manual](https://github.com/twpayne/chezmoi/blob/master/docs/REFERENCE.md#chezmoicommit-messagetmpl)\n" +
+		"for the data available to the template. To sign automatic commits, set\n" +
+		"`sourceVCS.sign` to `true` and, optionally, `sourceVCS.signingKey` to the key to\n" +
+		"sign with.\n" +
+		"\n" +
 		"## Use templates to manage files that vary from machine to machine\n" +
 		"\n" +
 		"The primary goal of chezmoi is to manage configuration files across multiple\n" +
@@ -1498,30 +1506,44 @@ func init() {
 		"<!--- toc --->\n" +
 		"* [Concepts](#concepts)\n" +
 		"* [Global command line flags](#global-command-line-flags)\n" +
+		"  * [`--backup`[=*directory*]](#--backupdirectory)\n" +
 		"  * [`--color` *value*](#--color-value)\n" +
 		"  * [`-c`, `--config` *filename*](#-c---config-filename)\n" +
+		"  * [`--cpu-profile` *filename*](#--cpu-profile-filename)\n" +
 		"  * [`--debug`](#--debug)\n" +
 		"  * [`-D`, `--destination` *directory*](#-d---destination-directory)\n" +
 		"  * [`-f`, `--follow`](#-f---follow)\n" +
 		"  * [`-n`, `--dry-run`](#-n---dry-run)\n" +
 		"  * [`-h`, `--help`](#-h---help)\n" +
-		"  * [`-r`. `--remove`](#-r---remove)\n" +
+		"  * [`--remove`[=*value*]](#--removevalue)\n" +
 		"  * [`-S`, `--source` *directory*](#-s---source-directory)\n" +
+		"  * [`--strict`](#--strict)\n" +
+		"  * [`--timings`](#--timings)\n" +
+		"  * [`--trace` *filename*](#--trace-filename)\n" +
 		"  * [`-v`, `--verbose`](#-v---verbose)\n" +
 		"  * [`--version`](#--version)\n" +
 		"* [Configuration file](#configuration-file)\n" +
 		"  * [Configuration variables](#configuration-variables)\n" +
+		"  * [Hooks](#hooks)\n" +
+		"  * [Source directories](#source-directories)\n" +
+		"  * [Roots](#roots)\n" +
+		"  * [Symlink mode](#symlink-mode)\n" +
 		"* [Source state attributes](#source-state-attributes)\n" +
 		"* [Special files and directories](#special-files-and-directories)\n" +
+		"  * [`.chezmoi/commit-message.tmpl`](#chezmoicommit-messagetmpl)\n" +
 		"  * [`.chezmoi.<format>.tmpl`](#chezmoiformattmpl)\n" +
+		"  * [`.chezmoiattributes`](#chezmoiattributes)\n" +
 		"  * [`.chezmoiignore`](#chezmoiignore)\n" +
 		"  * [`.chezmoiremove`](#chezmoiremove)\n" +
+		"  * [`.chezmoiroot`](#chezmoiroot)\n" +
 		"  * [`.chezmoitemplates`](#chezmoitemplates)\n" +
+		"  * [`.chezmoitriggers`](#chezmoitriggers)\n" +
 		"  * [`.chezmoiversion`](#chezmoiversion)\n" +
 		"* [Commands](#commands)\n" +
 		"  * [`add` *targets*](#add-targets)\n" +
 		"  * [`apply` [*targets*]](#apply-targets)\n" +
 		"  * [`archive`](#archive)\n" +
+		"  * [`backups` *subcommand*](#backups-subcommand)\n" +
 		"  * [`cat` targets](#cat-targets)\n" +
 		"  * [`cd`](#cd)\n" +
 		"  * [`chattr` *attributes* *targets*](#chattr-attributes-targets)\n" +
@@ -1538,8 +1560,10 @@ func init() {
 		"  * [`git` [*arguments*]](#git-arguments)\n" +
 		"  * [`help` *command*](#help-command)\n" +
 		"  * [`hg` [*arguments]](#hg-arguments)\n" +
+		"  * [`history` [*targets*]](#history-targets)\n" +
 		"  * [`init` [*repo*]](#init-repo)\n" +
 		"  * [`import` *filename*](#import-filename)\n" +
+		"  * [`lint`](#lint)\n" +
 		"  * [`manage` *targets*](#manage-targets)\n" +
 		"  * [`managed`](#managed)\n" +
 		"  * [`merge` *targets*](#merge-targets)\n" +
@@ -1602,6 +1626,15 @@ func init() {
 		"\n" +
 		"Command line flags override any values set in the configuration file.\n" +
 		"\n" +
+		"### `--backup`[=*directory*]\n" +
+		"\n" +
+		"Before overwriting or removing any target, copy it into a new subdirectory of\n" +
+		"*directory*, which defaults to `$XDG_STATE_HOME/chezmoi/backups` (or\n" +
+		"`~/.local/state/chezmoi/backups` if `$XDG_STATE_HOME` is not set). Only the\n" +
+		"version of each target that existed before chezmoi was run is kept. Backups can\n" +
+		"be listed, restored, and pruned with the `backups` command, and can be enabled\n" +
+		"permanently with the `backup.enabled` configuration variable.\n" +
+		"\n" +
 		"### `--color` *value*\n" +
 		"\n" +
 		"Colorize diffs, *value* can be `on`, `off`, or `auto`. The default value is\n" +
@@ -1611,9 +1644,13 @@ func init() {
 		"\n" +
 		"Read the configuration from *filename*.\n" +
 		"\n" +
+		"### `--cpu-profile` *filename*\n" +
+		"\n" +
+		"Write a CPU profile to *filename*, which can be analyzed with `go tool pprof`.\n" +
+		"\n" +
 		"### `--debug`\n" +
 		"\n" +
-		"Log information helpful for debugging.\n" +
+		"Log information helpful for debugging. `--debug` also implies `--timings`.\n" +
 		"\n" +
 		"### `-D`, `--destination` *directory*\n" +
 		"\n" +
@@ -1634,13 +1671,59 @@ func init() {
 		"\n" +
 		"Print help.\n" +
 		"\n" +
-		"### `-r`. `--remove`\n" +
+		"### `--remove`[=*value*]\n" +
 		"\n" +
-		"Also remove targets according to `.chezmoiremove`.\n" +
+		"Also remove targets according to `.chezmoiremove`, and targets that were managed\n" +
+		"by chezmoi the last time that `apply` was run but are no longer in the source\n" +
+		"state. *value* can be `true`, `false`, or `prompt`, and can also be set with\n" +
+		"`remove` in the configuration file. If *value* is `prompt` then chezmoi only\n" +
+		"prints the targets that it would remove, and removes nothing. Targets to be\n" +
+		"removed are also listed by `diff` and by `--dry-run --verbose`. When applying,\n" +
+		"chezmoi lists all the targets to be removed and asks for confirmation once\n" +
+		"before removing any of them, unless `apply --force` is given. chezmoi refuses to remove more than `maxRemove` targets at\n" +
+		"once. Ignored targets, and directories that still contain other files, are\n" +
+		"never removed.\n" +
 		"\n" +
 		"### `-S`, `--source` *directory*\n" +
 		"\n" +
-		"Use *directory* as the source directory.\n" +
+		"Use *directory* as the source directory. If `sourceDirs` is set then\n" +
+		"*directory* must be one of them, and chezmoi modifies *directory*. See [source\n" +
+		"directories](#source-directories).\n" +
+		"\n" +
+		"### `--strict`\n" +
+		"\n" +
+		"Always compare the full contents of target files. By default, chezmoi records\n" +
+		"the size, modification time, inode, and a hash of the contents of each file\n" +
+		"that it writes, and assumes that a file whose size, modification time, and\n" +
+		"inode have not changed still has the recorded contents, unless the file was\n" +
+		"modified within two seconds of the state being recorded. chezmoi also caches the\n" +
+		"output of templates that only use template data and `text/template`'s builtin\n" +
+		"functions. `--strict` disables both.\n" +
+		"\n" +
+		"### `--timings`\n" +
+		"\n" +
+		"When chezmoi exits, write the time spent in each phase to the standard error,\n" +
+		"together with the slowest items in each phase. The phases are:\n" +
+		"\n" +
+		"| Phase      | Time spent                                           |\n" +
+		"| ---------- | ---------------------------------------------------- |\n" +
+		"| `config`   | Reading the configuration file                       |\n" +
+		"| `populate` | Reading the source state                             |\n" +
+		"| `template` | Executing each template                              |\n" +
+		"| `secret`   | Each call to a secret manager                        |\n" +
+		"| `gpg`      | Each file encrypted or decrypted with gpg            |\n" +
+		"| `script`   | Running each script                                  |\n" +
+		"| `command`  | Running each command, including secret managers      |\n" +
+		"| `mutate`   | Each change to the destination or source directories |\n" +
+		"\n" +
+		"Phases overlap: for example, the time spent calling secret managers from\n" +
+		"templates is included in the `template` phase. Templates are executed\n" +
+		"concurrently, so the total for a phase can exceed the total elapsed time.\n" +
+		"\n" +
+		"### `--trace` *filename*\n" +
+		"\n" +
+		"Write an execution trace to *filename*, which can be analyzed with `go tool\n" +
+		"trace`.\n" +
 		"\n" +
 		"### `-v`, `--verbose`\n" +
 		"\n" +
@@ -1668,39 +1751,165 @@ func init() {
 		"\n" +
 		"The following configuration variables are available:\n" +
 		"\n" +
-		"| Variable                | Type     | Default value             | Description                                         |\n" +
-		"| ----------------------- | -------- | ------------------------- | --------------------------------------------------- |\n" +
-		"| `bitwarden.command`     | string   | `bw`                      | Bitwarden CLI command                               |\n" +
-		"| `cd.command`            | string   | *none*                    | Shell to run in `cd` command                        |\n" +
-		"| `color`                 | string   | `auto`                    | Colorize diffs                                      |\n" +
-		"| `data`                  | any      | *none*                    | Template data                                       |\n" +
-		"| `destDir`               | string   | `~`                       | Destination directory                               |\n" +
-		"| `diff.format`           | string   | `chezmoi`                 | Diff format, either `chezmoi` or `git`              |\n" +
-		"| `diff.pager`            | string   | *none*                    | Pager                                               |\n" +
-		"| `dryRun`                | bool     | `false`                   | Dry run mode                                        |\n" +
-		"| `follow`                | bool     | `false`                   | Follow symlinks                                     |\n" +
-		"| `genericSecret.command` | string   | *none*                    | Generic secret command                              |\n" +
-		"| `gopass.command`        | string   | `gopass`                  | gopass CLI command                                  |\n" +
-		"| `gpg.command`           | string   | `gpg`                     | GPG CLI command                                     |\n" +
-		"| `gpg.recipient`         | string   | *none*                    | GPG recipient                                       |\n" +
-		"| `gpg.symmetric`         | bool     | `false`                   | Use symmetric GPG encryption                        |\n" +
-		"| `keepassxc.args`        | []string | *none*                    | Extra args to KeePassXC CLI command                 |\n" +
-		"| `keepassxc.command`     | string   | `keepassxc-cli`           | KeePassXC CLI command                               |\n" +
-		"| `keepassxc.database`    | string   | *none*                    | KeePassXC database                                  |\n" +
-		"| `lastpass.command`      | string   | `lpass`                   | Lastpass CLI command                                |\n" +
-		"| `merge.args`            | []string | *none*                    | Extra args to 3-way merge command                   |\n" +
-		"| `merge.command`         | string   | `vimdiff`                 | 3-way merge command                                 |\n" +
-		"| `onepassword.command`   | string   | `op`                      | 1Password CLI command                               |\n" +
-		"| `pass.command`          | string   | `pass`                    | Pass CLI command                                    |\n" +
-		"| `remove`                | bool     | `false`                   | Remove targets                                      |\n" +
-		"| `sourceDir`             | string   | `~/.local/share/chezmoi`  | Source directory                                    |\n" +
-		"| `sourceVCS.autoCommit`  | bool     | `false`                   | Commit changes to the source state after any change |\n" +
-		"| `sourceVCS.autoPush`    | bool     | `false`                   | Push changes to the source state after any change   |\n" +
-		"| `sourceVCS.command`     | string   | `git`                     | Source version control system                       |\n" +
-		"| `template.options`      | []string | `[\"missingkey=error\"]`    | Template options                                    |\n" +
-		"| `umask`                 | int      | *from system*             | Umask                                               |\n" +
-		"| `vault.command`         | string   | `vault`                   | Vault CLI command                                   |\n" +
-		"| `verbose`               | bool     | `false`                   | Verbose mode                                        |\n" +
+		"| Variable                          | Type     | Default value            | Description                                         |\n" +
+		"| --------------------------------- | -------- | ------------------------ | --------------------------------------------------- |\n" +
+		"| `auditLog.enabled`                | bool     | `false`                  | Record all changes in the audit log                 |\n" +
+		"| `auditLog.file`                   | string   | *XDG state dir*          | Audit log file                                      |\n" +
+		"| `backup.dir`                      | string   | *XDG state dir*          | Directory in which to store backups                 |\n" +
+		"| `backup.enabled`                  | bool     | `false`                  | Back up targets before overwriting or removing them |\n" +
+		"| `bitwarden.command`               | string   | `bw`                     | Bitwarden CLI command                               |\n" +
+		"| `cd.command`                      | string   | *none*                   | Shell to run in `cd` command                        |\n" +
+		"| `color`                           | string   | `auto`                   | Colorize diffs                                      |\n" +
+		"| `concurrency`                     | int      | number of CPUs           | Maximum number of targets to evaluate concurrently  |\n" +
+		"| `data`                            | any      | *none*                   | Template data                                       |\n" +
+		"| `destDir`                         | string   | `~`                      | Destination directory                               |\n" +
+		"| `diff.format`                     | string   | `chezmoi`                | Diff format, either `chezmoi` or `git`              |\n" +
+		"| `diff.pager`                      | string   | *none*                   | Pager                                               |\n" +
+		"| `dryRun`                          | bool     | `false`                  | Dry run mode                                        |\n" +
+		"| `escalation.args`                 | []string | *none*                   | Extra args to privilege escalation command          |\n" +
+		"| `escalation.command`              | string   | `sudo`                   | Privilege escalation command                        |\n" +
+		"| `follow`                          | bool     | `false`                  | Follow symlinks                                     |\n" +
+		"| `genericSecret.command`           | string   | *none*                   | Generic secret command                              |\n" +
+		"| `gopass.command`                  | string   | `gopass`                 | gopass CLI command                                  |\n" +
+		"| `gpg.command`                     | string   | `gpg`                    | GPG CLI command                                     |\n" +
+		"| `gpg.recipient`                   | string   | *none*                   | GPG recipient                                       |\n" +
+		"| `gpg.symmetric`                   | bool     | `false`                  | Use symmetric GPG encryption                        |\n" +
+		"| `hooks.`*command*`.post.args`     | []string | *none*                   | Arguments to the post-*command* hook                |\n" +
+		"| `hooks.`*command*`.post.command`  | string   | *none*                   | Command to run after *command*                      |\n" +
+		"| `hooks.`*command*`.pre.args`      | []string | *none*                   | Arguments to the pre-*command* hook                 |\n" +
+		"| `hooks.`*command*`.pre.command`   | string   | *none*                   | Command to run before *command*                     |\n" +
+		"| `keepassxc.args`                  | []string | *none*                   | Extra args to KeePassXC CLI command                 |\n" +
+		"| `keepassxc.command`               | string   | `keepassxc-cli`          | KeePassXC CLI command                               |\n" +
+		"| `keepassxc.database`              | string   | *none*                   | KeePassXC database                                  |\n" +
+		"| `lastpass.command`                | string   | `lpass`                  | Lastpass CLI command                                |\n" +
+		"| `maxRemove`                       | int      | `100`                    | Maximum number of targets to remove, 0 for no limit |\n" +
+		"| `merge.args`                      | []string | *none*                   | Extra args to 3-way merge command                   |\n" +
+		"| `merge.command`                   | string   | `vimdiff`                | 3-way merge command                                 |\n" +
+		"| `mode`                            | string   | `file`                   | Mode, either `file` or `symlink`                    |\n" +
+		"| `onepassword.command`             | string   | `op`                     | 1Password CLI command                               |\n" +
+		"| `pass.command`                    | string   | `pass`                   | Pass CLI command                                    |\n" +
+		"| `remove`                          | string   | `false`                  | Remove targets (`true`, `false`, or `prompt`)       |\n" +
+		"| `roots.`*name*`.destDir`          | string   | *none*                   | Destination directory of root *name*                |\n" +
+		"| `roots.`*name*`.escalate`         | bool     | `false`                  | Escalate privileges to change root *name*           |\n" +
+		"| `roots.`*name*`.umask`            | int      | `umask`                  | Umask of root *name*                                |\n" +
+		"| `sourceDir`                       | string   | `~/.local/share/chezmoi` | Source directory                                    |\n" +
+		"| `sourceDirs`                      | []string | *none*                   | Source directories, in increasing precedence        |\n" +
+		"| `sourceVCS.autoCommit`            | bool     | `false`                  | Commit changes to the source state after any change |\n" +
+		"| `sourceVCS.autoPush`              | bool     | `false`                  | Push changes to the source state after any change   |\n" +
+		"| `sourceVCS.command`               | string   | `git`                    | Source version control system                       |\n" +
+		"| `sourceVCS.commitMessageTemplate` | string   | *none*                   | Template for auto-generated commit messages         |\n" +
+		"| `sourceVCS.sign`                  | bool     | `false`                  | Sign auto-generated commits                         |\n" +
+		"| `sourceVCS.signingKey`            | string   | *none*                   | Key used to sign auto-generated commits             |\n" +
+		"| `strict`                          | bool     | `false`                  | Always compare the full contents of target files    |\n" +
+		"| `template.options`                | []string | `[\"missingkey=error\"]`   | Template options                                    |\n" +
+		"| `umask`                           | int      | *from system*            | Umask                                               |\n" +
+		"| `vault.command`                   | string   | `vault`                  | Vault CLI command                                   |\n" +
+		"| `verbose`                         | bool     | `false`                  | Verbose mode                                        |\n" +
+		"\n" +
+		"### Hooks\n" +
+		"\n" +
+		"Hooks are commands that chezmoi runs before and after its own commands. The\n" +
+		"hooks for a command are configured in the `hooks.`*command* section of the\n" +
+		"configuration file, where *command* is the chezmoi command without `chezmoi`,\n" +
+		"for example `apply`, `add`, or `update`. The hooks for subcommands include their\n" +
+		"parent commands, for example `hooks.\"backups prune\"`. For example, to unlock\n" +
+		"your password manager before running `chezmoi apply` and reload your systemd\n" +
+		"user units afterwards:\n" +
+		"\n" +
+		"    [hooks.apply.pre]\n" +
+		"        command = \"unlock-password-manager\"\n" +
+		"    [hooks.apply.post]\n" +
+		"        command = \"systemctl\"\n" +
+		"        args = [\"--user\", \"daemon-reload\"]\n" +
+		"\n" +
+		"If a pre-hook exits with a non-zero exit code then chezmoi does not run the\n" +
+		"command. Post-hooks are only run if the command succeeds. Hooks are not run in\n" +
+		"dry-run mode.\n" +
+		"\n" +
+		"Hooks are run with the following environment variables set:\n" +
+		"\n" +
+		"| Variable             | Value                                                             |\n" +
+		"| -------------------- | ----------------------------------------------------------------- |\n" +
+		"| `CHEZMOI_HOOK`       | The hook, for example `apply.pre`                                 |\n" +
+		"| `CHEZMOI_COMMAND`    | The chezmoi command, for example `apply`                          |\n" +
+		"| `CHEZMOI_ARGS`       | The command's arguments, one per line                             |\n" +
+		"| `CHEZMOI_SOURCE_DIR` | The source directory                                              |\n" +
+		"| `CHEZMOI_DEST_DIR`   | The destination directory                                         |\n" +
+		"| `CHEZMOI_TARGETS`    | Post-hooks only: the paths that the command changed, one per line |\n" +
+		"\n" +
+		"### Source directories\n" +
+		"\n" +
+		"`sourceDirs` combines several source directories, for example a shared team\n" +
+		"source directory and a personal one, into a single source state. Source\n" +
+		"directories are read in order, and later source directories override earlier\n" +
+		"ones: an entry in a later source directory replaces the entry with the same\n" +
+		"target, and its templates in `.chezmoitemplates` replace templates with the same\n" +
+		"name. Directories that exist in several source directories are merged, taking\n" +
+		"their attributes from the last. Patterns in `.chezmoiignore` and\n" +
+		"`.chezmoiremove` are combined, and with ordered patterns (see\n" +
+		"[`.chezmoiignore`](#chezmoiignore)) patterns in later source directories take\n" +
+		"precedence.\n" +
+		"\n" +
+		"    sourceDirs = [\n" +
+		"        \"~/.local/share/chezmoi-team\",\n" +
+		"        \"~/.local/share/chezmoi\",\n" +
+		"    ]\n" +
+		"\n" +
+		"Commands that modify the source state, such as `add`, `chattr`, `edit`, and\n" +
+		"`merge`, write to the source directory given by `sourceDir` or `--source`, which\n" +
+		"must be one of `sourceDirs`, or the last source directory if neither is set.\n" +
+		"Entries from other source directories are first copied there, so that they\n" +
+		"override the originals. `forget` and `remove` only remove entries that come\n" +
+		"from the selected source directory, and report an error for entries from other\n" +
+		"source directories. `chezmoi managed --source-dir` and `chezmoi dump` show the\n" +
+		"source directory of each entry.\n" +
+		"\n" +
+		"### Roots\n" +
+		"\n" +
+		"Roots let a single source state manage files outside the destination directory,\n" +
+		"for example system configuration files. Each root is configured in the\n" +
+		"`roots.`*name* section of the configuration file and its source state is the\n" +
+		"top-level directory *name* in the source directory. For example, with the\n" +
+		"following configuration, `system/etc/hosts.tmpl` in the source directory is\n" +
+		"applied to `/etc/hosts`:\n" +
+		"\n" +
+		"    [roots.system]\n" +
+		"        destDir = \"/\"\n" +
+		"        umask = 0o022\n" +
+		"        escalate = true\n" +
+		"\n" +
+		"The root named `home` is the destination directory and the rest of the source\n" +
+		"state. Its `destDir` overrides `destDir`, unless `destDir` or `--destination` is\n" +
+		"set, and its `umask` overrides `umask`. Every other root must set `destDir`.\n" +
+		"Roots share templates in `.chezmoitemplates`, but have their own\n" +
+		"`.chezmoiignore`, `.chezmoiremove`, and `.chezmoitriggers` files in their\n" +
+		"top-level directory.\n" +
+		"\n" +
+		"If `escalate` is `true` then chezmoi changes files in the root by running\n" +
+		"`escalation.command`, followed by `escalation.args`, followed by a command like\n" +
+		"`install`, `mkdir`, or `rm`. Scripts in the root are run the same way. For\n" +
+		"example, to use `doas` instead of `sudo`:\n" +
+		"\n" +
+		"    [escalation]\n" +
+		"        command = \"doas\"\n" +
+		"\n" +
+		"`add`, `apply`, `chattr`, `edit`, `forget`, `managed`, `merge`, and `remove`\n" +
+		"work with targets in all roots. `archive`, `dump`, and `unmanaged` only work\n" +
+		"with the `home` root.\n" +
+		"\n" +
+		"### Symlink mode\n" +
+		"\n" +
+		"By default, chezmoi writes regular files in the destination directory. If `mode`\n" +
+		"is `symlink` then chezmoi instead makes regular files symlinks to their files in\n" +
+		"the source directory, so changes to them are made directly in the source\n" +
+		"directory:\n" +
+		"\n" +
+		"    mode = \"symlink\"\n" +
+		"\n" +
+		"Files that are templates, encrypted, private, or read-only are still written as\n" +
+		"regular files. Symlinked files have the permissions of their files in the source\n" +
+		"directory. `add`, `diff`, `unmanaged`, and `verify` treat a symlink to the\n" +
+		"file in the source directory as in sync with the target state.\n" +
 		"\n" +
 		"## Source state attributes\n" +
 		"\n" +
@@ -1717,11 +1926,14 @@ func init() {
 		"| `encrypted_` | Encrypt the file in the source state.                                          |\n" +
 		"| `once_`      | Only run script once.                                                          |\n" +
 		"| `private_`   | Remove all group and world permissions from the target file or directory.      |\n" +
+		"| `readonly_`  | Remove all write permissions from the target file.                             |\n" +
 		"| `empty_`     | Ensure the file exists, even if is empty. By default, empty files are removed. |\n" +
 		"| `exact_`     | Remove anything not managed by chezmoi.                                        |\n" +
 		"| `executable_`| Add executable permissions to the target file.                                 |\n" +
 		"| `run_`       | Treat the contents as a script to run.                                         |\n" +
 		"| `symlink_`   | Create a symlink instead of a regular file.                                    |\n" +
+		"| `block_`     | Manage a block of lines in a file instead of the whole file.                   |\n" +
+		"| `merge_`     | Merge a JSON, TOML, or YAML document into the file.                            |\n" +
 		"| `dot_`       | Rename to use a leading dot, e.g. `dot_foo` becomes `.foo`.                    |\n" +
 		"\n" +
 		"| Suffix  | Effect                                               |\n" +
@@ -1729,22 +1941,80 @@ func init() {
 		"| `.tmpl` | Treat the contents of the source file as a template. |\n" +
 		"\n" +
 		"Order of prefixes is important, the order is `run_`, `exact_`, `private_`,\n" +
-		"`empty_`, `executable_`, `symlink_`, `once_`, `dot_`.\n" +
+		"`readonly_`, `empty_`, `executable_`, `symlink_`, `once_`, `dot_`.\n" +
 		"\n" +
 		"Different target types allow different prefixes and suffixes:\n" +
 		"\n" +
-		"| Target type   | Allowed prefixes                                          | Allowed suffixes |\n" +
-		"| ------------- | --------------------------------------------------------- | ---------------- |\n" +
-		"| Directory     | `exact_`, `private_`, `dot_`                              | *none*           |\n" +
-		"| Regular file  | `encrypted_`, `private_`, `empty_`, `executable_`, `dot_` | `.tmpl`          |\n" +
-		"| Script        | `run_`, `once_`                                           | `.tmpl`          |\n" +
-		"| Symbolic link | `symlink_`, `dot_`,                                       | `.tmpl`          |\n" +
+		"| Target type   | Allowed prefixes                                                       | Allowed suffixes |\n" +
+		"| ------------- | ---------------------------------------------------------------------- | ---------------- |\n" +
+		"| Directory     | `exact_`, `private_`, `dot_`                                           | *none*           |\n" +
+		"| Regular file  | `encrypted_`, `private_`, `readonly_`, `empty_`, `executable_`, `dot_` | `.tmpl`          |\n" +
+		"| Script        | `run_`, `once_`                                                        | `.tmpl`          |\n" +
+		"| Symbolic link | `symlink_`, `dot_`,                                                    | `.tmpl`          |\n" +
+		"| Block         | `block_`, `dot_`                                                       | `.tmpl`          |\n" +
+		"| Merge         | `merge_`, `dot_`                                                       | `.tmpl`          |\n" +
+		"\n" +
+		"A source file with the `block_` prefix manages a block of lines in its target\n" +
+		"file and leaves the rest of the file alone. The block is delimited by the lines\n" +
+		"`# BEGIN chezmoi `*target*` and `# END chezmoi `*target*`, where *target* is\n" +
+		"the target name, for example `# BEGIN chezmoi .bashrc`. As the markers only\n" +
+		"contain the target name, each target file can contain at most one block. If the\n" +
+		"target file does not contain the block then it is appended, and if the target\n" +
+		"file does not exist then it is created. If the target file contains a begin\n" +
+		"marker without an end marker, an end marker before the begin marker, or\n" +
+		"duplicate markers, then chezmoi reports an error and leaves the target file\n" +
+		"unchanged. `diff` only shows changes to the block. If the source file is\n" +
+		"removed, or its contents are empty, then the block is removed from the target\n" +
+		"file on the next `apply`.\n" +
+		"\n" +
+		"A source file with the `merge_` prefix contains a document that is merged into\n" +
+		"its target file, which is useful for files that are also modified by other\n" +
+		"programs, for example `settings.json` in Visual Studio Code. The format of the\n" +
+		"document is determined by the extension of the target name, which must be\n" +
+		"`.json`, `.toml`, `.yaml`, or `.yml`. JSON files may contain comments and\n" +
+		"trailing commas, as in JSONC. YAML files must contain a single document. Objects are merged recursively and all other\n" +
+		"values, including arrays, replace the values in the target file. Keys whose\n" +
+		"value is the string `chezmoi:delete` are removed from the target file. The\n" +
+		"order of keys in the target file is preserved, new keys are appended, JSON\n" +
+		"files keep their indentation, and the comments on keys that remain in the\n" +
+		"target file are preserved. Trailing commas are removed. The target file is only\n" +
+		"written if its contents change after merging, so `diff` and `verify` ignore\n" +
+		"differences in formatting. chezmoi does not remove the target file, or\n" +
+		"the merged keys, if the source file is removed.\n" +
+		"\n" +
+		"For example, `~/.local/share/chezmoi/dot_config/Code/User/merge_settings.json`\n" +
+		"could contain:\n" +
+		"\n" +
+		"    {\n" +
+		"        \"editor.fontSize\": 14,\n" +
+		"        \"telemetry.enableTelemetry\": \"chezmoi:delete\"\n" +
+		"    }\n" +
 		"\n" +
 		"## Special files and directories\n" +
 		"\n" +
 		"All files and directories in the source state whose name begins with `.` are\n" +
 		"ignored by default, unless they are one of the special files listed here.\n" +
 		"\n" +
+		"### `.chezmoi/commit-message.tmpl`\n" +
+		"\n" +
+		"If a file called `.chezmoi/commit-message.tmpl` exists in the source directory\n" +
+		"then it is used as the template for commit messages generated when\n" +
+		"`sourceVCS.autoCommit` or `sourceVCS.autoPush` is true, unless\n" +
+		"`sourceVCS.commitMessageTemplate` is set in the config file.\n" +
+		"\n" +
+		"The template is passed the output of `git status` (with the fields `.Ordinary`,\n" +
+		"`.RenamedOrCopied`, `.Unmerged`, `.Untracked`, and `.Ignored`), `.Command`, the\n" +
+		"chezmoi command that triggered the commit, `.Hostname`, the hostname of the\n" +
+		"machine, and `.Operations`, a list of the operations performed. Each operation\n" +
+		"has the fields `.Operation` (one of `add`, `edit`, `forget`, or `remove`),\n" +
+		"`.TargetName`, and `.SourceName`.\n" +
+		"\n" +
+		"#### `.chezmoi/commit-message.tmpl` examples\n" +
+		"\n" +
+		"    {{ range .Operations }}{{ .Operation | title }} {{ .TargetName }}\n" +
+		"    {{ end }}\n" +
+		"    Committed by {{ .Command }} on {{ .Hostname }}\n" +
+		"\n" +
 		"### `.chezmoi.<format>.tmpl`\n" +
 		"\n" +
 		"If a file called `.chezmoi.<format>.tmpl` exists then `chezmoi init` will use it\n" +
@@ -1757,6 +2027,34 @@ func init() {
 		"    data:\n" +
 		"        email: \"{{ $email }}\"\n" +
 		"\n" +
+		"### `.chezmoiattributes`\n" +
+		"\n" +
+		"If a file called `.chezmoiattributes` exists at the top level of a source\n" +
+		"directory then it assigns attributes to files and directories in that source\n" +
+		"directory, as an alternative to prefixes in their names. Each line contains a\n" +
+		"pattern followed by attributes, like a `.gitattributes` file. An attribute is\n" +
+		"set by giving its name and unset by prefixing its name with a minus sign (`-`).\n" +
+		"The available attributes are `empty`, `encrypted`, `exact`, `executable`,\n" +
+		"`once`, `private`, `readonly`, and `template`.\n" +
+		"\n" +
+		"Patterns are matched against paths in the source directory, for example\n" +
+		"`dot_ssh/config`, not against paths in the destination directory. Patterns that\n" +
+		"contain a slash match paths relative to the source directory, and other\n" +
+		"patterns match base names at any depth. When several lines match a path, later\n" +
+		"lines take precedence. Attributes assigned in `.chezmoiattributes` take\n" +
+		"precedence over attributes in names. Lines beginning with `#` are comments.\n" +
+		"\n" +
+		"`chezmoi chattr --manifest` changes attributes by editing `.chezmoiattributes`\n" +
+		"instead of renaming files.\n" +
+		"\n" +
+		"#### `.chezmoiattributes` examples\n" +
+		"\n" +
+		"    dot_ssh         exact private\n" +
+		"    dot_ssh/*       private\n" +
+		"    dot_gitconfig   template\n" +
+		"    bin/*           executable\n" +
+		"    run_install.sh  once\n" +
+		"\n" +
 		"### `.chezmoiignore`\n" +
 		"\n" +
 		"If a file called `.chezmoiignore` exists in the source state then it is\n" +
@@ -1767,6 +2065,17 @@ func init() {
 		"Patterns can be excluded by prefixing them with a `!` character. All excludes\n" +
 		"take priority over all includes.\n" +
 		"\n" +
+		"If `.chezmoiversion` is `1.9.0` or later, then patterns in `.chezmoiignore` and\n" +
+		"`.chezmoiremove` are instead interpreted like patterns in `.gitignore` files:\n" +
+		"\n" +
+		"* Patterns are evaluated in order and the last matching pattern wins, so a\n" +
+		"  pattern prefixed with `!` re-includes targets matched by earlier patterns.\n" +
+		"  Patterns in subdirectories come after patterns in their parent directories.\n" +
+		"* Patterns containing a `/`, other than a trailing `/`, are anchored to the\n" +
+		"  directory containing the file. Other patterns match at any depth.\n" +
+		"* Patterns ending in `/` only match directories.\n" +
+		"* Everything in a matching directory is matched, and cannot be re-included.\n" +
+		"\n" +
 		"Comments are introduced with the `#` character and run until the end of the\n" +
 		"line.\n" +
 		"\n" +
@@ -1777,6 +2086,17 @@ func init() {
 		"\n" +
 		"#### `.chezmoiignore` examples\n" +
 		"\n" +
+		"With `.chezmoiversion` `1.9.0` or later, ignore everything in `.config` except\n" +
+		"`.config/nvim`, but still ignore `.config/nvim/backup`, and ignore all\n" +
+		"directories called `cache`:\n" +
+		"\n" +
+		"    .config/*\n" +
+		"    !.config/nvim\n" +
+		"    .config/nvim/backup\n" +
+		"    cache/\n" +
+		"\n" +
+		"With earlier versions:\n" +
+		"\n" +
 		"    README.md\n" +
 		"\n" +
 		"    *.txt   # ignore *.txt in the target directory\n" +
@@ -1795,7 +2115,25 @@ func init() {
 		"\n" +
 		"If a file called `.chezmoiremove` exists in the source state then it is\n" +
 		"interpreted as a list of targets to remove. `.chezmoiremove` is interpreted as a\n" +
-		"template.\n" +
+		"template. Targets are only removed if `--remove` is given. chezmoi refuses to\n" +
+		"remove targets that it manages, or that contain targets that it manages.\n" +
+		"\n" +
+		"### `.chezmoiroot`\n" +
+		"\n" +
+		"If a file called `.chezmoiroot` exists at the top level of the source directory\n" +
+		"then its contents, a path relative to the source directory, name the\n" +
+		"subdirectory that contains the source state. This allows the source directory\n" +
+		"to contain other files, such as a `README.md` or CI configuration, without them\n" +
+		"becoming targets. All commands that read or modify the source state, including\n" +
+		"`add`, `cd`, `edit`, `init`, and `source-path`, use this subdirectory, but\n" +
+		"version control commands continue to run in the source directory itself.\n" +
+		"\n" +
+		"#### `.chezmoiroot` examples\n" +
+		"\n" +
+		"    .chezmoiroot\n" +
+		"    home\n" +
+		"\n" +
+		"    home/dot_bashrc\n" +
 		"\n" +
 		"### `.chezmoitemplates`\n" +
 		"\n" +
@@ -1815,6 +2153,28 @@ func init() {
 		"\n" +
 		"The target state of `.config` will be `bar`.\n" +
 		"\n" +
+		"### `.chezmoitriggers`\n" +
+		"\n" +
+		"If a file called `.chezmoitriggers` exists in the source state then each line\n" +
+		"is interpreted as a pattern followed by a shell command. After `apply`, each\n" +
+		"command whose pattern matches a target that was changed, or any of its parent\n" +
+		"directories, is run once with the user's shell, in the order in which it\n" +
+		"appears. Patterns are matched using\n" +
+		"[`doublestar.PathMatch`](https://pkg.go.dev/github.com/bmatcuk/doublestar?tab=doc#PathMatch)\n" +
+		"against the target path. Duplicate commands are only run once.\n" +
+		"\n" +
+		"Lines beginning with the `#` character are comments. Commands are not run with\n" +
+		"`--dry-run`, but are printed with `--verbose` and by `diff`.\n" +
+		"\n" +
+		"`.chezmoitriggers` is interpreted as a template. `.chezmoitriggers` files in\n" +
+		"subdirectories apply only to that subdirectory.\n" +
+		"\n" +
+		"#### `.chezmoitriggers` examples\n" +
+		"\n" +
+		"    .tmux.conf                     tmux source-file ~/.tmux.conf\n" +
+		"    .config/i3                     i3-msg reload\n" +
+		"    .config/systemd/user/*.service systemctl --user daemon-reload\n" +
+		"\n" +
 		"### `.chezmoiversion`\n" +
 		"\n" +
 		"If a file called `.chezmoiversion` exists, then its contents are interpreted as\n" +
@@ -1843,6 +2203,11 @@ func init() {
 		"the `data` section of the config file. Longer subsitutions occur before shorter\n" +
 		"ones. This implies the `--template` option.\n" +
 		"\n" +
+		"#### `--block`\n" +
+		"\n" +
+		"Add the block of lines delimited by `# BEGIN chezmoi `*target*` and `# END\n" +
+		"chezmoi `*target*` in each target, instead of the whole target.\n" +
+		"\n" +
 		"#### `-e`, `--empty`\n" +
 		"\n" +
 		"Set the `empty` attribute on added files.\n" +
@@ -1873,17 +2238,37 @@ func init() {
 		"    chezmoi add ~/.gitconfig --template\n" +
 		"    chezmoi add ~/.vim --recursive\n" +
 		"    chezmoi add ~/.oh-my-zsh --exact --recursive\n" +
+		"    chezmoi add ~/.bashrc --block\n" +
 		"\n" +
 		"### `apply` [*targets*]\n" +
 		"\n" +
 		"Ensure that *targets* are in the target state, updating them if necessary. If no\n" +
 		"targets are specified, the state of all targets are ensured.\n" +
 		"\n" +
+		"#### `-f`, `--force`\n" +
+		"\n" +
+		"Remove targets with `--remove` without prompting.\n" +
+		"\n" +
+		"#### `-k`, `--keep-going`\n" +
+		"\n" +
+		"Keep going as far as possible after an error applying a target. Targets that\n" +
+		"fail, for example because their template cannot be executed, are skipped, as\n" +
+		"are the contents of directories that cannot be created. When all other targets\n" +
+		"have been applied, chezmoi prints a report of the targets that failed and exits\n" +
+		"with a non-zero exit code.\n" +
+		"\n" +
+		"#### `--report-format` *format*\n" +
+		"\n" +
+		"Print the `--keep-going` report in *format*, which must be `text` (the default)\n" +
+		"or `json`.\n" +
+		"\n" +
 		"#### `apply` examples\n" +
 		"\n" +
 		"    chezmoi apply\n" +
 		"    chezmoi apply --dry-run --verbose\n" +
 		"    chezmoi apply ~/.bashrc\n" +
+		"    chezmoi apply --keep-going --report-format=json\n" +
+		"    chezmoi apply --remove --force\n" +
 		"\n" +
 		"### `archive`\n" +
 		"\n" +
@@ -1894,6 +2279,42 @@ func init() {
 		"\n" +
 		"    chezmoi archive | tar tvf -\n" +
 		"\n" +
+		"### `backups` *subcommand*\n" +
+		"\n" +
+		"Manage the backups made by `--backup`. Each backup is a directory in the backup\n" +
+		"directory named after the time at which it was made, containing the targets\n" +
+		"that chezmoi overwrote or removed at that time.\n" +
+		"\n" +
+		"#### `backups list` [*backup*]\n" +
+		"\n" +
+		"List all backups, oldest first. If *backup* is given, list the targets in\n" +
+		"*backup* instead.\n" +
+		"\n" +
+		"#### `backups restore` *backup* [*targets*]\n" +
+		"\n" +
+		"Restore *targets* from *backup*. If no targets are specified, all targets in\n" +
+		"*backup* are restored. If `--backup` is also given, the current versions of the\n" +
+		"targets are backed up before they are restored.\n" +
+		"\n" +
+		"#### `backups prune`\n" +
+		"\n" +
+		"Remove old backups. At least one of `--keep` and `--older-than` must be given.\n" +
+		"\n" +
+		"#### `--keep` *count*\n" +
+		"\n" +
+		"Remove all but the *count* most recent backups.\n" +
+		"\n" +
+		"#### `--older-than` *duration*\n" +
+		"\n" +
+		"Remove all backups older than *duration*, for example `720h`.\n" +
+		"\n" +
+		"#### `backups` examples\n" +
+		"\n" +
+		"    chezmoi backups list\n" +
+		"    chezmoi backups list 20200102T030405Z\n" +
+		"    chezmoi backups restore 20200102T030405Z ~/.bashrc\n" +
+		"    chezmoi backups prune --keep 10\n" +
+		"\n" +
 		"### `cat` targets\n" +
 		"\n" +
 		"Write the target state of *targets*  to stdout. *targets* must be files or\n" +
@@ -1929,16 +2350,23 @@ func init() {
 		"| `exact`      | *none*       |\n" +
 		"| `executable` | `x`          |\n" +
 		"| `private`    | `p`          |\n" +
+		"| `readonly`   | `r`          |\n" +
 		"| `template`   | `t`          |\n" +
 		"\n" +
 		"Multiple attributes modifications may be specified by separating them with a\n" +
 		"comma (`,`).\n" +
 		"\n" +
+		"#### `--manifest`\n" +
+		"\n" +
+		"Change attributes by editing [`.chezmoiattributes`](#chezmoiattributes) in the\n" +
+		"source directory instead of renaming files.\n" +
+		"\n" +
 		"#### `chattr` examples\n" +
 		"\n" +
 		"    chezmoi chattr template ~/.bashrc\n" +
 		"    chezmoi chattr noempty ~/.profile\n" +
 		"    chezmoi chattr private,template ~/.netrc\n" +
+		"    chezmoi chattr --manifest executable ~/bin/tool\n" +
 		"\n" +
 		"### `completion` *shell*\n" +
 		"\n" +
@@ -2017,7 +2445,8 @@ func init() {
 		"\n" +
 		"### `doctor`\n" +
 		"\n" +
-		"Check for potential problems.\n" +
+		"Check for potential problems. This includes checking whether the source\n" +
+		"directory has uncommitted changes or is ahead of or behind its upstream branch.\n" +
 		"\n" +
 		"#### `doctor` examples\n" +
 		"\n" +
@@ -2128,6 +2557,36 @@ func init() {
 		"\n" +
 		"    chezmoi hg -- pull --rebase --update\n" +
 		"\n" +
+		"### `history` [*targets*]\n" +
+		"\n" +
+		"Print the changes that chezmoi has made to *targets*, or to all targets if no\n" +
+		"targets are specified, as recorded in the audit log. The audit log is only\n" +
+		"written if the `auditLog.enabled` configuration variable is `true`. Every change\n" +
+		"to the filesystem and every command and script run appends a JSON object to the\n" +
+		"audit log containing the time, the user, the chezmoi command, the source\n" +
+		"revision, the operation, the target, and the SHA256 hashes and modes of the\n" +
+		"target before and after the change.\n" +
+		"\n" +
+		"#### `-f`, `--format` *format*\n" +
+		"\n" +
+		"Print the changes in *format*, which must be `text` (the default) or `json`. In\n" +
+		"`json` format, the audit log entries are printed as they were recorded, one per\n" +
+		"line.\n" +
+		"\n" +
+		"#### `--since` *duration*\n" +
+		"\n" +
+		"Only print changes made within *duration*, for example `24h`.\n" +
+		"\n" +
+		"#### `--user` *user*\n" +
+		"\n" +
+		"Only print changes made by *user*.\n" +
+		"\n" +
+		"#### `history` examples\n" +
+		"\n" +
+		"    chezmoi history\n" +
+		"    chezmoi history ~/.bashrc\n" +
+		"    chezmoi history --since 168h --user alice --format json\n" +
+		"\n" +
 		"### `init` [*repo*]\n" +
 		"\n" +
 		"Setup the source directory and update the destination directory to match the\n" +
@@ -2173,6 +2632,33 @@ func init() {
 		"    curl -s -L -o oh-my-zsh-master.tar.gz https://github.com/robbyrussell/oh-my-zsh/archive/master.tar.gz\n" +
 		"    chezmoi import --strip-components 1 --destination ~/.oh-my-zsh oh-my-zsh-master.tar.gz\n" +
 		"\n" +
+		"### `lint`\n" +
+		"\n" +
+		"Check the source state for problems and print each problem as\n" +
+		"*path*`:`*line*`: `*message*, suitable for use in editors and pre-commit hooks.\n" +
+		"chezmoi exits with a non-zero exit code if any problems are found. `lint`\n" +
+		"reports:\n" +
+		"\n" +
+		"* Multiple source files or directories that map to the same target.\n" +
+		"* Attribute prefixes that are misordered, and so are treated as part of the\n" +
+		"  target name, or are likely to be misspelled.\n" +
+		"* Templates that cannot be parsed, including files that are templates because\n" +
+		"  of `.chezmoiattributes`.\n" +
+		"* Invalid patterns in `.chezmoiignore` and `.chezmoiremove` files.\n" +
+		"* Invalid lines in `.chezmoiattributes`, and patterns in it that do not match\n" +
+		"  any source path.\n" +
+		"* Patterns in `.chezmoiignore` files that do not match any target in the source\n" +
+		"  state or any file in the destination directory.\n" +
+		"* `exact_` directories that have no entries.\n" +
+		"* `merge_` files whose target's extension is not a supported merge format.\n" +
+		"\n" +
+		"Line numbers in `.chezmoiignore` and `.chezmoiremove` files refer to the result\n" +
+		"of executing them as templates.\n" +
+		"\n" +
+		"#### `lint` examples\n" +
+		"\n" +
+		"    chezmoi lint\n" +
+		"\n" +
 		"### `manage` *targets*\n" +
 		"\n" +
 		"`manage` is an alias for `add` for symmetry with `unmanage`.\n" +
@@ -2188,6 +2674,11 @@ func init() {
 		"abbreviated to `d`, `f`, and `s` respectively. By default, `manage` will list\n" +
 		"entries of all types.\n" +
 		"\n" +
+		"#### `--source-dir`\n" +
+		"\n" +
+		"Also print the source directory that each entry comes from, separated from the\n" +
+		"entry by a tab. This is useful with [`sourceDirs`](#source-directories).\n" +
+		"\n" +
 		"#### `managed` examples\n" +
 		"\n" +
 		"    chezmoi managed\n" +
@@ -2195,6 +2686,7 @@ func init() {
 		"    chezmoi managed --include=files,symlinks\n" +
 		"    chezmoi managed -i d\n" +
 		"    chezmoi managed -i d,f\n" +
+		"    chezmoi managed --source-dir\n" +
 		"\n" +
 		"### `merge` *targets*\n" +
 		"\n" +
@@ -2295,11 +2787,27 @@ func init() {
 		"\n" +
 		"### `update`\n" +
 		"\n" +
-		"Pull changes from the source VCS and apply any changes.\n" +
+		"Pull changes from the source VCS and apply any changes. If the source VCS is\n" +
+		"git, chezmoi will warn before pulling if the source directory contains\n" +
+		"uncommitted changes or commits that have not been pushed.\n" +
+		"\n" +
+		"#### `-a`, `--apply`\n" +
+		"\n" +
+		"Apply changes after pulling. This is the default. Use `--apply=false` to only\n" +
+		"pull changes.\n" +
+		"\n" +
+		"#### `--review`\n" +
+		"\n" +
+		"Print the changes that applying the updated target state would make to the\n" +
+		"destination directory, including the contents of any scripts that would be run,\n" +
+		"and prompt for confirmation before applying them. If the changes are declined,\n" +
+		"the source directory can optionally be reverted to the revision it was at before\n" +
+		"pulling.\n" +
 		"\n" +
 		"#### `update` examples\n" +
 		"\n" +
 		"    chezmoi update\n" +
+		"    chezmoi update --review\n" +
 		"\n" +
 		"### `upgrade`\n" +
 		"\n" +
@@ -2344,8 +2852,8 @@ func init() {
 		"\n" +
 		"By default, chezmoi uses your current umask as set by your operating system and\n" +
 		"shell. chezmoi only stores crude permissions in its source state, namely in the\n" +
-		"`executable`  and `private` attributes, corresponding to the umasks of `0o111`\n" +
-		"and `0o077` respectively.\n" +
+		"`executable`, `private`, and `readonly` attributes, corresponding to the umasks\n" +
+		"of `0o111`, `0o077`, and `0o222` respectively.\n" +
 		"\n" +
 		"For machine-specific control of umask, set the `umask` configuration variable in\n" +
 		"chezmoi's configuration file, for example:\n" +
@@ -2379,6 +2887,24 @@ func init() {
 		"For a full list of options, see\n" +
 		"[`Template.Option`](https://pkg.go.dev/text/template?tab=doc#Template.Option).\n" +
 		"\n" +
+		"If a template cannot be parsed or executed, including when a template function\n" +
+		"such as `keepassxc` or `lastpass` fails, chezmoi reports the path of the\n" +
+		"template in the source directory, the line and column of the error, the\n" +
+		"surrounding lines of the template with a caret marking the error, and the keys\n" +
+		"of the template data, for example:\n" +
+		"\n" +
+		"    /home/user/.local/share/chezmoi/dot_gitconfig.tmpl:3:16: executing \"/home/user/.local/share/chezmoi/dot_gitconfig.tmpl\" at <.emial>: map has no entry for key \"emial\"\n" +
+		"      1 | [user]\n" +
+		"      2 |     name = {{ .name }}\n" +
+		"    > 3 |     email = {{ .emial }}\n" +
+		"        |                ^\n" +
+		"    data keys: chezmoi, email, name\n" +
+		"\n" +
+		"chezmoi evaluates templates concurrently, using up to `concurrency` templates\n" +
+		"at a time, before it makes any changes. Identical calls to secret manager\n" +
+		"template functions, such as `lastpass` or `vault`, are only executed once. If\n" +
+		"your secret manager prompts for input, set `concurrency` to `1`.\n" +
+		"\n" +
 		"## Template variables\n" +
 		"\n" +
 		"chezmoi provides the following automatically populated variables:\n" +
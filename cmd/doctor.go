@@ -11,6 +11,7 @@ import (
 
 	"github.com/coreos/go-semver/semver"
 	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/git"
 	shell "github.com/twpayne/go-shell"
 )
 
@@ -72,6 +73,14 @@ type doctorFileCheck struct {
 
 type doctorRuntimeCheck struct{}
 
+type doctorSourceVCSStatusCheck struct {
+	path    string
+	command string
+	vcs     VCS
+	status  *git.Status
+	err     error
+}
+
 type doctorSuspiciousFilesCheck struct {
 	path      string
 	filenames map[string]bool
@@ -95,7 +104,8 @@ func (c *Config) runDoctorCmd(cmd *cobra.Command, args []string) error {
 	shell, _ := shell.CurrentUserShell()
 
 	var vcsCommandCheck doctorCheck
-	if vcs, err := c.getVCS(); err == nil {
+	vcs, err := c.getVCS()
+	if err == nil {
 		vcsCommandCheck = &doctorBinaryCheck{
 			name:          "source VCS command",
 			binaryName:    c.SourceVCS.Command,
@@ -155,6 +165,11 @@ func (c *Config) runDoctorCmd(cmd *cobra.Command, args []string) error {
 			binaryName: c.Merge.Command,
 		},
 		vcsCommandCheck,
+		&doctorSourceVCSStatusCheck{
+			path:    c.SourceDir,
+			command: c.SourceVCS.Command,
+			vcs:     vcs,
+		},
 		gpgBinaryCheck,
 		&doctorBinaryCheck{
 			name:          "1Password CLI",
@@ -216,7 +231,7 @@ func (c *Config) runDoctorCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 	if !allOK {
-		os.Exit(1)
+		return exitCodeError(1)
 	}
 	return nil
 }
@@ -395,6 +410,68 @@ func (doctorRuntimeCheck) Skip() bool {
 	return false
 }
 
+func (c *doctorSourceVCSStatusCheck) Check() (bool, error) {
+	//nolint:gosec
+	cmd := exec.Command(c.command, c.vcs.StatusArgs()...)
+	cmd.Dir = c.path
+	output, err := cmd.Output()
+	if err != nil {
+		c.err = err
+		return false, nil
+	}
+	status, err := c.vcs.ParseStatusOutput(output)
+	if err != nil {
+		c.err = err
+		return false, nil
+	}
+	c.status, _ = status.(*git.Status)
+	if c.status == nil {
+		return true, nil
+	}
+	if !c.status.Empty() {
+		return false, nil
+	}
+	if c.status.Branch != nil && (c.status.Branch.Ahead != 0 || c.status.Branch.Behind != 0) {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (c *doctorSourceVCSStatusCheck) Enabled() bool {
+	return c.vcs != nil && c.vcs.StatusArgs() != nil
+}
+
+func (c *doctorSourceVCSStatusCheck) MustSucceed() bool {
+	return false
+}
+
+func (c *doctorSourceVCSStatusCheck) Result() string {
+	switch {
+	case c.err != nil:
+		return fmt.Sprintf("%s (source VCS status, %v)", c.path, c.err)
+	case c.status == nil:
+		return fmt.Sprintf("%s (source VCS status)", c.path)
+	}
+	var components []string
+	if branch := c.status.Branch; branch != nil {
+		if branch.Upstream != "" {
+			components = append(components, fmt.Sprintf("%s...%s, %d ahead, %d behind", branch.Head, branch.Upstream, branch.Ahead, branch.Behind))
+		} else {
+			components = append(components, fmt.Sprintf("%s, no upstream", branch.Head))
+		}
+	}
+	if c.status.Empty() {
+		components = append(components, "no uncommitted changes")
+	} else {
+		components = append(components, "uncommitted changes")
+	}
+	return fmt.Sprintf("%s (source VCS status, %s)", c.path, strings.Join(components, ", "))
+}
+
+func (c *doctorSourceVCSStatusCheck) Skip() bool {
+	return false
+}
+
 func (c *doctorSuspiciousFilesCheck) Check() (bool, error) {
 	if err := filepath.Walk(c.path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
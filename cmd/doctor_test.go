@@ -7,6 +7,7 @@ import (
 	"github.com/coreos/go-semver/semver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/twpayne/chezmoi/internal/git"
 )
 
 func TestDoctorBinaryCheck(t *testing.T) {
@@ -66,3 +67,44 @@ func TestDoctorBinaryCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestDoctorSourceVCSStatusCheckResult(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   *git.Status
+		expected string
+	}{
+		{
+			name: "in_sync",
+			status: &git.Status{
+				Branch: &git.BranchStatus{
+					Head:     "master",
+					Upstream: "origin/master",
+				},
+			},
+			expected: "/home/user/.local/share/chezmoi (source VCS status, master...origin/master, 0 ahead, 0 behind, no uncommitted changes)",
+		},
+		{
+			name: "no_upstream_uncommitted_changes",
+			status: &git.Status{
+				Branch: &git.BranchStatus{
+					Head: "master",
+				},
+				Untracked: []git.UntrackedStatus{
+					{
+						Path: "dot_bashrc",
+					},
+				},
+			},
+			expected: "/home/user/.local/share/chezmoi (source VCS status, master, no upstream, uncommitted changes)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &doctorSourceVCSStatusCheck{
+				path:   "/home/user/.local/share/chezmoi",
+				status: tc.status,
+			}
+			assert.Equal(t, tc.expected, c.Result())
+		})
+	}
+}
@@ -13,8 +13,9 @@ import (
 
 func TestDumpCmd(t *testing.T) {
 	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
-		"/home/user/.local/share/chezmoi/dir/file":        "contents",
-		"/home/user/.local/share/chezmoi/symlink_symlink": "target",
+		"/home/user/.local/share/chezmoi/dir/file":           "contents",
+		"/home/user/.local/share/chezmoi/dir/readonly_other": "other",
+		"/home/user/.local/share/chezmoi/symlink_symlink":    "target",
 	})
 	require.NoError(t, err)
 	defer cleanup()
@@ -48,6 +49,16 @@ func TestDumpCmd(t *testing.T) {
 					"template":   false,
 					"contents":   "contents",
 				},
+				map[string]interface{}{
+					"type":       "file",
+					"sourcePath": filepath.Join("/", "home", "user", ".local", "share", "chezmoi", "dir", "readonly_other"),
+					"targetPath": filepath.Join("dir", "other"),
+					"empty":      false,
+					"encrypted":  false,
+					"perm":       float64(0444),
+					"template":   false,
+					"contents":   "other",
+				},
 			},
 		},
 		map[string]interface{}{
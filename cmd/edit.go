@@ -57,7 +57,11 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		if c.edit.prompt {
 			cmd.Printf("warning: --prompt is currently ignored when edit is run with no arguments\n")
 		}
-		return c.runEditor(c.SourceDir)
+		sourceRootDir, err := chezmoi.SourceRootDir(c.fs, c.SourceDir)
+		if err != nil {
+			return err
+		}
+		return c.runEditor(sourceRootDir)
 	}
 
 	if c.edit.prompt {
@@ -77,12 +81,15 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Build a list of source file names to pass to the editor. Check that each
-	// is either a file or a symlink. If the entry is an encrypted file then
-	// remember it.
+	// is either a file, a block, a merge, or a symlink. If the entry is an
+	// encrypted file then remember it.
 	argv := make([]string, len(entries))
 	var encryptedFiles []encryptedFile
 	for i, entry := range entries {
-		argv[i] = filepath.Join(c.SourceDir, entry.SourceName())
+		if err := ts.CopyToSourceDir(c.fs, entry, c.mutator); err != nil {
+			return err
+		}
+		argv[i] = ts.SourcePath(entry)
 		if file, ok := entry.(*chezmoi.File); ok {
 			if file.Encrypted {
 				ef := encryptedFile{
@@ -92,8 +99,12 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 				}
 				encryptedFiles = append(encryptedFiles, ef)
 			}
-		} else if _, ok := entry.(*chezmoi.Symlink); !ok {
-			return fmt.Errorf("%s: not a file or symlink", args[i])
+		} else {
+			switch entry.(type) {
+			case *chezmoi.Block, *chezmoi.Merge, *chezmoi.Symlink:
+			default:
+				return fmt.Errorf("%s: not a file, block, merge, or symlink", args[i])
+			}
 		}
 	}
 
@@ -142,6 +153,10 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	for _, entry := range entries {
+		c.recordCommitOperation("edit", entry)
+	}
+
 	// Recompute the target state and entries after editing.
 	ts, err = c.getTargetState(nil)
 	if err != nil {
@@ -157,8 +172,10 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 	applyOptions := chezmoi.ApplyOptions{
 		DestDir:           ts.DestDir,
 		DryRun:            c.DryRun,
-		Ignore:            ts.TargetIgnore.Match,
+		Ignore:            ts.Ignore,
+		Mode:              c.Mode,
 		ScriptStateBucket: c.scriptStateBucket,
+		SourceDir:         ts.EntrySourceDir,
 		Stdout:            c.Stdout,
 		Umask:             ts.Umask,
 		Verbose:           c.Verbose,
@@ -169,7 +186,7 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		if c.edit.diff {
 			mutator = chezmoi.NewVerboseMutator(c.Stdout, mutator, c.colored, c.maxDiffDataSize)
 		}
-		if err := entry.Apply(readOnlyFS, mutator, c.Follow, &applyOptions); err != nil {
+		if err := ts.ApplyEntry(readOnlyFS, mutator, c.Follow, &applyOptions, entry); err != nil {
 			return err
 		}
 		if c.edit.apply && anyMutator.Mutated() {
@@ -188,7 +205,7 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 					c.edit.prompt = false
 				}
 			}
-			if err := entry.Apply(readOnlyFS, c.mutator, c.Follow, &applyOptions); err != nil {
+			if err := ts.ApplyEntry(readOnlyFS, c.mutator, c.Follow, &applyOptions, entry); err != nil {
 				return err
 			}
 		}
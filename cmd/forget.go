@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"path/filepath"
-
 	"github.com/spf13/cobra"
 )
 
@@ -29,14 +27,15 @@ func (c *Config) runForgetCmd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	entries, err := c.getEntries(ts, args)
+	entries, err := c.getSourceDirEntries(ts, args)
 	if err != nil {
 		return err
 	}
 	for _, entry := range entries {
-		if err := c.mutator.RemoveAll(filepath.Join(c.SourceDir, entry.SourceName())); err != nil {
+		if err := c.mutator.RemoveAll(ts.SourcePath(entry)); err != nil {
 			return err
 		}
+		c.recordCommitOperation("forget", entry)
 	}
 	return nil
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestForgetCmdSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": "# personal .bashrc\n",
+			".vimrc":  "# team .vimrc\n",
+		},
+		"/home/user/.local/share/chezmoi-team": map[string]interface{}{
+			"dot_bashrc": "# team .bashrc\n",
+			"dot_vimrc":  "# team .vimrc\n",
+		},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc": "# personal .bashrc\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	c := newTestConfig(fs, func(c *Config) {
+		c.SourceDirs = []string{
+			"/home/user/.local/share/chezmoi-team",
+			"/home/user/.local/share/chezmoi",
+		}
+	})
+
+	assert.EqualError(t, c.runForgetCmd(nil, []string{"/home/user/.vimrc"}), "/home/user/.vimrc: in source directory /home/user/.local/share/chezmoi-team, not /home/user/.local/share/chezmoi")
+	assert.NoError(t, c.runForgetCmd(nil, []string{"/home/user/.bashrc"}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_bashrc",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi-team/dot_bashrc",
+			vfst.TestContentsString("# team .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi-team/dot_vimrc",
+			vfst.TestContentsString("# team .vimrc\n"),
+		),
+	)
+}
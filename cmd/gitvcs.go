@@ -18,8 +18,20 @@ func (gitVCS) CloneArgs(repo, dir string) []string {
 	return []string{"clone", repo, dir}
 }
 
-func (gitVCS) CommitArgs(message string) []string {
-	return []string{"commit", "--message", message}
+func (gitVCS) CommitArgs(message string, options *vcsCommitOptions) []string {
+	args := []string{"commit", "--message", message}
+	if options != nil && options.Sign {
+		if options.SigningKey != "" {
+			args = append(args, "--gpg-sign="+options.SigningKey)
+		} else {
+			args = append(args, "--gpg-sign")
+		}
+	}
+	return args
+}
+
+func (gitVCS) HeadArgs() []string {
+	return []string{"rev-parse", "HEAD"}
 }
 
 func (gitVCS) InitArgs() []string {
@@ -38,8 +50,12 @@ func (gitVCS) PushArgs() []string {
 	return []string{"push"}
 }
 
+func (gitVCS) ResetArgs(revision string) []string {
+	return []string{"reset", "--keep", revision}
+}
+
 func (gitVCS) StatusArgs() []string {
-	return []string{"status", "--porcelain=v2"}
+	return []string{"status", "--porcelain=v2", "--branch"}
 }
 
 func (gitVCS) VersionArgs() []string {
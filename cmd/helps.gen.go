@@ -21,6 +21,11 @@ var helps = map[string]help{
 			"  from the `data` section of the config file. Longer subsitutions occur before\n" +
 			"  shorter ones. This implies the `--template` option.\n" +
 			"\n" +
+			"  `--block`\n" +
+			"\n" +
+			"  Add the block of lines delimited by `# BEGIN chezmoi `*target*`and`# END\n" +
+			"  chezmoi `*target*` in each target, instead of the whole target.\n" +
+			"\n" +
 			"  `-e`, `--empty`\n" +
 			"\n" +
 			"  Set the `empty` attribute on added files.\n" +
@@ -49,17 +54,37 @@ var helps = map[string]help{
 			"  chezmoi add ~/.bashrc\n" +
 			"  chezmoi add ~/.gitconfig --template\n" +
 			"  chezmoi add ~/.vim --recursive\n" +
-			"  chezmoi add ~/.oh-my-zsh --exact --recursive",
+			"  chezmoi add ~/.oh-my-zsh --exact --recursive\n" +
+			"  chezmoi add ~/.bashrc --block",
 	},
 	"apply": {
 		long: "" +
 			"Description:\n" +
 			"  Ensure that *targets* are in the target state, updating them if necessary. If\n" +
-			"  no targets are specified, the state of all targets are ensured.",
+			"  no targets are specified, the state of all targets are ensured.\n" +
+			"\n" +
+			"  `-f`, `--force`\n" +
+			"\n" +
+			"  Remove targets with `--remove` without prompting.\n" +
+			"\n" +
+			"  `-k`, `--keep-going`\n" +
+			"\n" +
+			"  Keep going as far as possible after an error applying a target. Targets that\n" +
+			"  fail, for example because their template cannot be executed, are skipped, as\n" +
+			"  are the contents of directories that cannot be created. When all other targets\n" +
+			"  have been applied, chezmoi prints a report of the targets that failed and\n" +
+			"  exits with a non-zero exit code.\n" +
+			"\n" +
+			"  `--report-format` *format*\n" +
+			"\n" +
+			"  Print the `--keep-going` report in *format*, which must be `text` (the default)\n" +
+			"  or `json`.",
 		example: "" +
 			"  chezmoi apply\n" +
 			"  chezmoi apply --dry-run --verbose\n" +
-			"  chezmoi apply ~/.bashrc",
+			"  chezmoi apply ~/.bashrc\n" +
+			"  chezmoi apply --keep-going --report-format=json\n" +
+			"  chezmoi apply --remove --force",
 	},
 	"archive": {
 		long: "" +
@@ -69,6 +94,41 @@ var helps = map[string]help{
 		example: "" +
 			"  chezmoi archive | tar tvf -",
 	},
+	"backups": {
+		long: "" +
+			"Description:\n" +
+			"  Manage the backups made by `--backup`. Each backup is a directory in the backup\n" +
+			"  directory named after the time at which it was made, containing the targets\n" +
+			"  that chezmoi overwrote or removed at that time.\n" +
+			"\n" +
+			"  `backups list` [*backup*]\n" +
+			"\n" +
+			"  List all backups, oldest first. If *backup* is given, list the targets in\n" +
+			"  *backup* instead.\n" +
+			"\n" +
+			"  `backups restore` *backup* [*targets*]\n" +
+			"\n" +
+			"  Restore *targets* from *backup*. If no targets are specified, all targets in\n" +
+			"  *backup* are restored. If `--backup` is also given, the current versions of the\n" +
+			"  targets are backed up before they are restored.\n" +
+			"\n" +
+			"  `backups prune`\n" +
+			"\n" +
+			"  Remove old backups. At least one of `--keep` and `--older-than` must be given.\n" +
+			"\n" +
+			"  `--keep` *count*\n" +
+			"\n" +
+			"  Remove all but the *count* most recent backups.\n" +
+			"\n" +
+			"  `--older-than` *duration*\n" +
+			"\n" +
+			"  Remove all backups older than *duration*, for example `720h`.",
+		example: "" +
+			"  chezmoi backups list\n" +
+			"  chezmoi backups list 20200102T030405Z\n" +
+			"  chezmoi backups restore 20200102T030405Z ~/.bashrc\n" +
+			"  chezmoi backups prune --keep 10",
+	},
 	"cat": {
 		long: "" +
 			"Description:\n" +
@@ -104,14 +164,21 @@ var helps = map[string]help{
 			"    exact      | none\n" +
 			"    executable | x\n" +
 			"    private    | p\n" +
+			"    readonly   | r\n" +
 			"    template   | t\n" +
 			"\n" +
 			"  Multiple attributes modifications may be specified by separating them with a\n" +
-			"  comma (`,`).",
+			"  comma (`,`).\n" +
+			"\n" +
+			"  `--manifest`\n" +
+			"\n" +
+			"  Change attributes by editing .chezmoiattributes in the source directory\n" +
+			"  instead of renaming files.",
 		example: "" +
 			"  chezmoi chattr template ~/.bashrc\n" +
 			"  chezmoi chattr noempty ~/.profile\n" +
-			"  chezmoi chattr private,template ~/.netrc",
+			"  chezmoi chattr private,template ~/.netrc\n" +
+			"  chezmoi chattr --manifest executable ~/bin/tool",
 	},
 	"completion": {
 		long: "" +
@@ -187,7 +254,9 @@ var helps = map[string]help{
 	"doctor": {
 		long: "" +
 			"Description:\n" +
-			"  Check for potential problems.",
+			"  Check for potential problems. This includes checking whether the source\n" +
+			"  directory has uncommitted changes or is ahead of or behind its upstream\n" +
+			"  branch.",
 		example: "" +
 			"  chezmoi doctor",
 	},
@@ -296,6 +365,35 @@ var helps = map[string]help{
 		example: "" +
 			"  chezmoi hg -- pull --rebase --update",
 	},
+	"history": {
+		long: "" +
+			"Description:\n" +
+			"  Print the changes that chezmoi has made to *targets*, or to all targets if no\n" +
+			"  targets are specified, as recorded in the audit log. The audit log is only\n" +
+			"  written if the `auditLog.enabled` configuration variable is `true`. Every\n" +
+			"  change to the filesystem and every command and script run appends a JSON\n" +
+			"  object to the audit log containing the time, the user, the chezmoi command,\n" +
+			"  the source revision, the operation, the target, and the SHA256 hashes and\n" +
+			"  modes of the target before and after the change.\n" +
+			"\n" +
+			"  `-f`, `--format` *format*\n" +
+			"\n" +
+			"  Print the changes in *format*, which must be `text` (the default) or `json`.\n" +
+			"  In `json` format, the audit log entries are printed as they were recorded, one\n" +
+			"  per line.\n" +
+			"\n" +
+			"  `--since` *duration*\n" +
+			"\n" +
+			"  Only print changes made within *duration*, for example `24h`.\n" +
+			"\n" +
+			"  `--user` *user*\n" +
+			"\n" +
+			"  Only print changes made by *user*.",
+		example: "" +
+			"  chezmoi history\n" +
+			"  chezmoi history ~/.bashrc\n" +
+			"  chezmoi history --since 168h --user alice --format json",
+	},
 	"import": {
 		long: "" +
 			"Description:\n" +
@@ -340,6 +438,33 @@ var helps = map[string]help{
 			"  chezmoi init https://github.com/user/dotfiles.git\n" +
 			"  chezmoi init https://github.com/user/dotfiles.git --apply",
 	},
+	"lint": {
+		long: "" +
+			"Description:\n" +
+			"  Check the source state for problems and print each problem as\n" +
+			"  *path*`:`*line*`: `*message*, suitable for use in editors and pre-commit hooks.\n" +
+			"  chezmoi exits with a non-zero exit code if any problems are found. `lint`\n" +
+			"  reports:\n" +
+			"\n" +
+			"  • Multiple source files or directories that map to the same target.\n" +
+			"  • Attribute prefixes that are misordered, and so are treated as part of the\n" +
+			"  target name, or are likely to be misspelled.\n" +
+			"  • Templates that cannot be parsed, including files that are templates because\n" +
+			"  of `.chezmoiattributes`.\n" +
+			"  • Invalid patterns in `.chezmoiignore` and `.chezmoiremove` files.\n" +
+			"  • Invalid lines in `.chezmoiattributes`, and patterns in it that do not match\n" +
+			"  any source path.\n" +
+			"  • Patterns in `.chezmoiignore` files that do not match any target in the\n" +
+			"  source\n" +
+			"  state or any file in the destination directory.\n" +
+			"  • `exact_` directories that have no entries.\n" +
+			"  • `merge_` files whose target's extension is not a supported merge format.\n" +
+			"\n" +
+			"  Line numbers in `.chezmoiignore` and `.chezmoiremove` files refer to the\n" +
+			"  result of executing them as templates.",
+		example: "" +
+			"  chezmoi lint",
+	},
 	"manage": {
 		long: "" +
 			"Description:\n" +
@@ -355,13 +480,19 @@ var helps = map[string]help{
 			"  Only list entries of type *types*. *types* is a comma-separated list of types\n" +
 			"  of entry to include. Valid types are `dirs`, `files`, and `symlinks` which can\n" +
 			"  be abbreviated to `d`, `f`, and `s` respectively. By default, `manage` will\n" +
-			"  list entries of all types.",
+			"  list entries of all types.\n" +
+			"\n" +
+			"  `--source-dir`\n" +
+			"\n" +
+			"  Also print the source directory that each entry comes from, separated from the\n" +
+			"  entry by a tab. This is useful with sourceDirs.",
 		example: "" +
 			"  chezmoi managed\n" +
 			"  chezmoi managed --include=files\n" +
 			"  chezmoi managed --include=files,symlinks\n" +
 			"  chezmoi managed -i d\n" +
-			"  chezmoi managed -i d,f",
+			"  chezmoi managed -i d,f\n" +
+			"  chezmoi managed --source-dir",
 	},
 	"merge": {
 		long: "" +
@@ -463,9 +594,25 @@ var helps = map[string]help{
 	"update": {
 		long: "" +
 			"Description:\n" +
-			"  Pull changes from the source VCS and apply any changes.",
+			"  Pull changes from the source VCS and apply any changes. If the source VCS is\n" +
+			"  git, chezmoi will warn before pulling if the source directory contains\n" +
+			"  uncommitted changes or commits that have not been pushed.\n" +
+			"\n" +
+			"  `-a`, `--apply`\n" +
+			"\n" +
+			"  Apply changes after pulling. This is the default. Use `--apply=false` to only\n" +
+			"  pull changes.\n" +
+			"\n" +
+			"  `--review`\n" +
+			"\n" +
+			"  Print the changes that applying the updated target state would make to the\n" +
+			"  destination directory, including the contents of any scripts that would be\n" +
+			"  run, and prompt for confirmation before applying them. If the changes are\n" +
+			"  declined, the source directory can optionally be reverted to the revision it\n" +
+			"  was at before pulling.",
 		example: "" +
-			"  chezmoi update",
+			"  chezmoi update\n" +
+			"  chezmoi update --review",
 	},
 	"upgrade": {
 		long: "" +
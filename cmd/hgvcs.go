@@ -14,10 +14,14 @@ func (hgVCS) CloneArgs(repo, dir string) []string {
 	return []string{"clone", repo, dir}
 }
 
-func (hgVCS) CommitArgs(message string) []string {
+func (hgVCS) CommitArgs(message string, options *vcsCommitOptions) []string {
 	return nil
 }
 
+func (hgVCS) HeadArgs() []string {
+	return []string{"log", "--rev", ".", "--template", "{node}"}
+}
+
 func (hgVCS) InitArgs() []string {
 	return []string{"init"}
 }
@@ -34,6 +38,10 @@ func (hgVCS) PushArgs() []string {
 	return nil
 }
 
+func (hgVCS) ResetArgs(revision string) []string {
+	return []string{"update", "--check", "--rev", revision}
+}
+
 func (hgVCS) StatusArgs() []string {
 	return nil
 }
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+var historyCmd = &cobra.Command{
+	Use:     "history [targets...]",
+	Short:   "Show the changes that chezmoi has made",
+	Long:    mustGetLongHelp("history"),
+	Example: getExample("history"),
+	PreRunE: config.ensureNoError,
+	RunE:    config.runHistoryCmd,
+}
+
+type auditLogConfig struct {
+	Enabled bool
+	File    string
+}
+
+type historyCmdConfig struct {
+	format string
+	since  time.Duration
+	user   string
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	persistentFlags := historyCmd.PersistentFlags()
+	persistentFlags.StringVarP(&config.history.format, "format", "f", "text", "format (text or json)")
+	persistentFlags.DurationVar(&config.history.since, "since", 0, "only show changes made within this duration")
+	persistentFlags.StringVar(&config.history.user, "user", "", "only show changes made by user")
+
+	markRemainingZshCompPositionalArgumentsAsFiles(historyCmd, 1)
+}
+
+func (c *Config) runHistoryCmd(cmd *cobra.Command, args []string) error {
+	if c.history.format != "text" && c.history.format != "json" {
+		return fmt.Errorf("invalid --format value: %s", c.history.format)
+	}
+
+	var targetPaths []string
+	for _, arg := range args {
+		targetPath, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		targetPaths = append(targetPaths, targetPath)
+	}
+
+	f, err := c.fs.Open(c.AuditLog.File)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tabwriter.NewWriter(c.Stdout, 0, 8, 2, ' ', 0)
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		var entry chezmoi.AuditLogEntry
+		if err := json.Unmarshal(s.Bytes(), &entry); err != nil {
+			return fmt.Errorf("%s:%d: %w", c.AuditLog.File, line, err)
+		}
+		if !c.historyEntrySelected(&entry, targetPaths) {
+			continue
+		}
+		if c.history.format == "json" {
+			if _, err := c.Stdout.Write(append(s.Bytes(), '\n')); err != nil {
+				return err
+			}
+			continue
+		}
+		what := entry.Target
+		if entry.NewTarget != "" {
+			what += " -> " + entry.NewTarget
+		}
+		if entry.Cmd != "" {
+			what = entry.Cmd
+		}
+		if entry.Error != "" {
+			what += ": " + entry.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format(time.RFC3339), entry.User, entry.Command, entry.Operation, what)
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+// closeAuditLog closes the audit log, if it is open.
+func (c *Config) closeAuditLog() error {
+	if c.auditLogFile == nil {
+		return nil
+	}
+	err := c.auditLogFile.Close()
+	c.auditLog = nil
+	c.auditLogFile = nil
+	return err
+}
+
+// historyEntrySelected returns true if entry matches the history command's
+// filters and affects any of targetPaths, or if targetPaths is empty.
+func (c *Config) historyEntrySelected(entry *chezmoi.AuditLogEntry, targetPaths []string) bool {
+	if c.history.since != 0 && time.Since(entry.Time) > c.history.since {
+		return false
+	}
+	if c.history.user != "" && entry.User != c.history.user {
+		return false
+	}
+	if len(targetPaths) == 0 {
+		return true
+	}
+	for _, targetPath := range targetPaths {
+		for _, path := range []string{entry.Target, entry.NewTarget} {
+			if path == targetPath || strings.HasPrefix(path, targetPath+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// openAuditLog opens the audit log for cmd with args, if enabled.
+func (c *Config) openAuditLog(cmd *cobra.Command, args []string) error {
+	if !c.AuditLog.Enabled || c.DryRun {
+		return nil
+	}
+	if err := vfs.MkdirAll(c.fs, filepath.Dir(c.AuditLog.File), 0700); err != nil {
+		return err
+	}
+	f, err := c.fs.OpenFile(c.AuditLog.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	username := os.Getenv("USER")
+	if currentUser, err := user.Current(); err == nil {
+		username = currentUser.Username
+	}
+	command := chezmoi.ShellQuoteArgs(append(strings.Fields(cmd.CommandPath()), args...))
+	c.auditLogFile = f
+	c.auditLog = chezmoi.NewAuditLog(f, command, username, c.sourceRevision)
+	return nil
+}
+
+// sourceRevision returns the current revision of the source directory, or the
+// empty string if it cannot be determined. It does not use c.mutator so that
+// it is not itself recorded.
+func (c *Config) sourceRevision() string {
+	vcs, err := c.getVCS()
+	if err != nil {
+		return ""
+	}
+	headArgs := vcs.HeadArgs()
+	if headArgs == nil {
+		return ""
+	}
+	//nolint:gosec
+	cmd := exec.Command(c.SourceVCS.Command, headArgs...)
+	cmd.Dir = c.SourceDir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestHistory(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": "# old contents of .bashrc\n",
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_bashrc": "# contents of .bashrc\n",
+				"dot_zshrc":  "# contents of .zshrc\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(fs,
+		withAuditLog(auditLogConfig{
+			Enabled: true,
+			File:    "/home/user/.local/state/chezmoi/audit.log",
+		}),
+		withStdout(stdout),
+	)
+	require.NoError(t, c.openAuditLog(applyCmd, nil))
+	c.mutator = chezmoi.NewAuditLogMutator(c.mutator, c.fs, c.auditLog)
+	require.NoError(t, c.runApplyCmd(nil, nil))
+	require.NoError(t, c.closeAuditLog())
+
+	stdout.Reset()
+	c.history.format = "json"
+	require.NoError(t, c.runHistoryCmd(nil, []string{"/home/user/.bashrc"}))
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	require.Len(t, lines, 1)
+	var entry chezmoi.AuditLogEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "chezmoi apply", entry.Command)
+	assert.Equal(t, "write", entry.Operation)
+	assert.Equal(t, "/home/user/.bashrc", entry.Target)
+	assert.NotEmpty(t, entry.OldSHA256)
+	assert.NotEmpty(t, entry.NewSHA256)
+
+	stdout.Reset()
+	c.history.format = "text"
+	require.NoError(t, c.runHistoryCmd(nil, nil))
+	assert.Equal(t, 2, strings.Count(stdout.String(), "\n"))
+	assert.Contains(t, stdout.String(), "/home/user/.zshrc")
+
+	stdout.Reset()
+	c.history.user = "nobody"
+	require.NoError(t, c.runHistoryCmd(nil, nil))
+	assert.Empty(t, stdout.String())
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+)
+
+type hookConfig struct {
+	Command string
+	Args    []string
+}
+
+type hooksConfig struct {
+	Pre  hookConfig
+	Post hookConfig
+}
+
+// hookName returns the name of cmd's hooks, which is cmd's path without the
+// root command, for example "apply" or "backups prune".
+func hookName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
+
+// runHook runs the stage hook for cmd with args, if one is configured. Pre
+// hooks also start recording the paths changed by cmd, which are passed to
+// post hooks.
+func (c *Config) runHook(cmd *cobra.Command, stage string, args []string) error {
+	name := hookName(cmd)
+	hooks, ok := c.Hooks[name]
+	if !ok {
+		return nil
+	}
+	if stage == "pre" && hooks.Post.Command != "" {
+		c.hookMutator = chezmoi.NewAnyMutator(c.mutator)
+		c.mutator = c.hookMutator
+	}
+
+	var hook hookConfig
+	switch stage {
+	case "pre":
+		hook = hooks.Pre
+	case "post":
+		hook = hooks.Post
+	}
+	if hook.Command == "" {
+		return nil
+	}
+
+	env := append(os.Environ(),
+		"CHEZMOI_HOOK="+name+"."+stage,
+		"CHEZMOI_COMMAND="+name,
+		"CHEZMOI_ARGS="+strings.Join(args, "\n"),
+		"CHEZMOI_SOURCE_DIR="+c.SourceDir,
+		"CHEZMOI_DEST_DIR="+c.DestDir,
+	)
+	if stage == "post" && c.hookMutator != nil {
+		env = append(env, "CHEZMOI_TARGETS="+strings.Join(c.hookMutator.MutatedPaths(), "\n"))
+	}
+
+	//nolint:gosec
+	hookCmd := exec.Command(hook.Command, hook.Args...)
+	hookCmd.Env = env
+	hookCmd.Stdin = c.Stdin
+	hookCmd.Stdout = c.Stdout
+	hookCmd.Stderr = c.Stderr
+	if err := c.mutator.RunCmd(hookCmd); err != nil {
+		return fmt.Errorf("%s.%s hook: %w", name, stage, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// +build !windows
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestHooks(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi/dot_bashrc": "# contents of .bashrc\n",
+		"/home/user/hooks.log":                       "",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	hooksLog, err := fs.RawPath("/home/user/hooks.log")
+	require.NoError(t, err)
+
+	c := newTestConfig(fs,
+		withHooks(map[string]hooksConfig{
+			"apply": {
+				Pre: hookConfig{
+					Command: "sh",
+					Args:    []string{"-c", `echo "$CHEZMOI_HOOK" >> "$0"`, hooksLog},
+				},
+				Post: hookConfig{
+					Command: "sh",
+					Args:    []string{"-c", `echo "$CHEZMOI_HOOK $CHEZMOI_TARGETS" >> "$0"`, hooksLog},
+				},
+			},
+		}),
+	)
+	require.NoError(t, c.runHook(applyCmd, "pre", nil))
+	require.NoError(t, c.runApplyCmd(nil, nil))
+	require.NoError(t, c.runHook(applyCmd, "post", nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/hooks.log",
+			vfst.TestContentsString("apply.pre\napply.post /home/user/.bashrc\n"),
+		),
+	)
+}
+
+func TestHooksPreFailure(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi/dot_bashrc": "# contents of .bashrc\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(fs,
+		withHooks(map[string]hooksConfig{
+			"apply": {
+				Pre: hookConfig{
+					Command: "false",
+				},
+			},
+		}),
+	)
+	err = c.runHook(applyCmd, "pre", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "apply.pre hook")
+}
+
+func TestHooksSubcommand(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/hooks.log": "",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	hooksLog, err := fs.RawPath("/home/user/hooks.log")
+	require.NoError(t, err)
+
+	logHook := hookConfig{
+		Command: "sh",
+		Args:    []string{"-c", `echo "$CHEZMOI_HOOK $CHEZMOI_COMMAND" >> "$0"`, hooksLog},
+	}
+	c := newTestConfig(fs,
+		withHooks(map[string]hooksConfig{
+			"backups prune": {
+				Pre: logHook,
+			},
+			"prune": {
+				Pre: hookConfig{
+					Command: "false",
+				},
+			},
+		}),
+	)
+	require.NoError(t, c.runHook(backupsPruneCmd, "pre", nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/hooks.log",
+			vfst.TestContentsString("backups prune.pre backups prune\n"),
+		),
+	)
+}
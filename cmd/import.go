@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -75,7 +74,7 @@ func (c *Config) runImportCmd(cmd *cobra.Command, args []string) error {
 		entry, err := ts.Get(c.fs, c._import.importTAROptions.DestinationDir)
 		switch {
 		case err == nil:
-			if err := c.mutator.RemoveAll(filepath.Join(c.SourceDir, entry.SourceName())); err != nil {
+			if err := c.mutator.RemoveAll(ts.SourcePath(entry)); err != nil {
 				return err
 			}
 		case os.IsNotExist(err):
@@ -83,5 +82,5 @@ func (c *Config) runImportCmd(cmd *cobra.Command, args []string) error {
 			return err
 		}
 	}
-	return ts.ImportTAR(tar.NewReader(r), c._import.importTAROptions, c.mutator)
+	return ts.ImportTAR(c.fs, tar.NewReader(r), c._import.importTAROptions, c.mutator)
 }
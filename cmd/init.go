@@ -160,8 +160,12 @@ func (c *Config) createConfigFile() error {
 }
 
 func (c *Config) findConfigTemplate() (string, string, string, error) {
+	sourceRootDir, err := chezmoi.SourceRootDir(c.fs, c.SourceDir)
+	if err != nil {
+		return "", "", "", err
+	}
 	for _, ext := range viper.SupportedExts {
-		contents, err := c.fs.ReadFile(filepath.Join(c.SourceDir, ".chezmoi."+ext+chezmoi.TemplateSuffix))
+		contents, err := c.fs.ReadFile(filepath.Join(sourceRootDir, ".chezmoi."+ext+chezmoi.TemplateSuffix))
 		switch {
 		case os.IsNotExist(err):
 			continue
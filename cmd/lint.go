@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+var lintCmd = &cobra.Command{
+	Use:     "lint",
+	Args:    cobra.NoArgs,
+	Short:   "Check the source state for problems",
+	Long:    mustGetLongHelp("lint"),
+	Example: getExample("lint"),
+	PreRunE: config.ensureNoError,
+	RunE:    config.runLintCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+func (c *Config) runLintCmd(cmd *cobra.Command, args []string) error {
+	ts, err := c.getTargetState(nil)
+	if err != nil {
+		return err
+	}
+	problems, err := ts.Lint(vfs.NewReadOnlyFS(c.fs))
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		if _, err := fmt.Fprintln(c.Stdout, problem); err != nil {
+			return err
+		}
+	}
+	if len(problems) != 0 {
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+	return nil
+}
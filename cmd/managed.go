@@ -20,7 +20,8 @@ var managedCmd = &cobra.Command{
 }
 
 type managedCmdConfig struct {
-	include []string
+	include   []string
+	sourceDir bool
 }
 
 func init() {
@@ -28,6 +29,7 @@ func init() {
 
 	persistentFlags := managedCmd.PersistentFlags()
 	persistentFlags.StringSliceVarP(&config.managed.include, "include", "i", []string{"dirs", "files", "symlinks"}, "include")
+	persistentFlags.BoolVar(&config.managed.sourceDir, "source-dir", false, "print the source directory of each entry")
 }
 
 func (c *Config) runManagedCmd(cmd *cobra.Command, args []string) error {
@@ -54,28 +56,47 @@ func (c *Config) runManagedCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	allEntries := ts.AllEntries()
-
-	targetNames := make([]string, 0, len(allEntries))
-	for _, entry := range allEntries {
-		if _, ok := entry.(*chezmoi.Dir); ok && !includeDirs {
-			continue
-		}
-		if _, ok := entry.(*chezmoi.File); ok && !includeFiles {
-			continue
-		}
-		if _, ok := entry.(*chezmoi.Symlink); ok && !includeSymlinks {
-			continue
+	type managedEntry struct {
+		targetPath string
+		sourceDir  string
+	}
+	var managedEntries []managedEntry
+	for _, root := range ts.AllTargetStates() {
+		for _, entry := range root.AllEntries() {
+			if _, ok := entry.(*chezmoi.Dir); ok && !includeDirs {
+				continue
+			}
+			if _, ok := entry.(*chezmoi.File); ok && !includeFiles {
+				continue
+			}
+			if _, ok := entry.(*chezmoi.Block); ok && !includeFiles {
+				continue
+			}
+			if _, ok := entry.(*chezmoi.Merge); ok && !includeFiles {
+				continue
+			}
+			if _, ok := entry.(*chezmoi.Symlink); ok && !includeSymlinks {
+				continue
+			}
+			if root.Ignore(entry.TargetName()) {
+				continue
+			}
+			managedEntries = append(managedEntries, managedEntry{
+				targetPath: filepath.Join(root.DestDir, entry.TargetName()),
+				sourceDir:  root.EntrySourceDir(entry.TargetName()),
+			})
 		}
-		targetNames = append(targetNames, entry.TargetName())
 	}
 
-	sort.Strings(targetNames)
-	for _, targetName := range targetNames {
-		if ts.TargetIgnore.Match(targetName) {
-			continue
+	sort.Slice(managedEntries, func(i, j int) bool {
+		return managedEntries[i].targetPath < managedEntries[j].targetPath
+	})
+	for _, managedEntry := range managedEntries {
+		if c.managed.sourceDir {
+			fmt.Fprintf(c.Stdout, "%s\t%s\n", managedEntry.targetPath, managedEntry.sourceDir)
+		} else {
+			fmt.Fprintln(c.Stdout, managedEntry.targetPath)
 		}
-		fmt.Fprintln(c.Stdout, filepath.Join(ts.DestDir, targetName))
 	}
 
 	return nil
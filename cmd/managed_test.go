@@ -92,6 +92,42 @@ func TestManagedCmd(t *testing.T) {
 	}
 }
 
+func TestManagedCmdSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi-team": map[string]interface{}{
+			"dot_bashrc": "# team .bashrc\n",
+			"dot_vimrc":  "# team .vimrc\n",
+		},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc": "# personal .bashrc\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(
+		fs,
+		withStdout(stdout),
+		withManaged(managedCmdConfig{
+			include:   []string{"files"},
+			sourceDir: true,
+		}),
+		func(c *Config) {
+			c.SourceDirs = []string{
+				"/home/user/.local/share/chezmoi-team",
+				"/home/user/.local/share/chezmoi",
+			}
+		},
+	)
+	assert.NoError(t, c.runManagedCmd(nil, nil))
+	posixLines, err := extractPOSIXTargetNames(stdout.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"/home/user/.bashrc\t/home/user/.local/share/chezmoi",
+		"/home/user/.vimrc\t/home/user/.local/share/chezmoi-team",
+	}, posixLines)
+}
+
 // extractPOSIXTargetNames extracts all target names from b and coverts them to
 // POSIX-like names.
 func extractPOSIXTargetNames(b []byte) ([]string, error) {
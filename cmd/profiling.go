@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+)
+
+// maxTimingItems is the maximum number of items reported for each phase.
+const maxTimingItems = 10
+
+type profilingConfig struct {
+	cpuProfile     string
+	timings        bool
+	trace          string
+	cpuProfileFile *os.File
+	traceFile      *os.File
+}
+
+// startProfiling starts recording timings, CPU profiles, and execution traces
+// as requested on the command line. If it returns an error then anything that
+// it started has been stopped.
+func (c *Config) startProfiling() (err error) {
+	defer func() {
+		if err != nil {
+			_ = c.stopProfiling()
+		}
+	}()
+	if c.profiling.timings || c.Debug {
+		c.timings = chezmoi.NewTimings()
+	}
+	if c.profiling.cpuProfile != "" {
+		f, err := os.Create(c.profiling.cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		c.profiling.cpuProfileFile = f
+	}
+	if c.profiling.trace != "" {
+		f, err := os.Create(c.profiling.trace)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		c.profiling.traceFile = f
+	}
+	return nil
+}
+
+// stopProfiling stops any CPU profile and execution trace started by
+// startProfiling and reports any timings to c.Stderr.
+func (c *Config) stopProfiling() error {
+	var err error
+	if c.profiling.cpuProfileFile != nil {
+		pprof.StopCPUProfile()
+		err = c.profiling.cpuProfileFile.Close()
+		c.profiling.cpuProfileFile = nil
+	}
+	if c.profiling.traceFile != nil {
+		trace.Stop()
+		if closeErr := c.profiling.traceFile.Close(); err == nil {
+			err = closeErr
+		}
+		c.profiling.traceFile = nil
+	}
+	if reportErr := c.timings.Report(c.Stderr, maxTimingItems); err == nil {
+		err = reportErr
+	}
+	return err
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -38,13 +37,13 @@ func (c *Config) runRemoveCmd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	entries, err := c.getEntries(ts, args)
+	entries, err := c.getSourceDirEntries(ts, args)
 	if err != nil {
-		return nil
+		return err
 	}
 	for _, entry := range entries {
-		destDirPath := filepath.Join(c.DestDir, entry.TargetName())
-		sourceDirPath := filepath.Join(c.SourceDir, entry.SourceName())
+		destDirPath := ts.TargetPath(entry)
+		sourceDirPath := ts.SourcePath(entry)
 		if !c.remove.force {
 			choice, err := c.prompt(fmt.Sprintf("Remove %s and %s", destDirPath, sourceDirPath), "ynqa")
 			if err != nil {
@@ -66,6 +65,7 @@ func (c *Config) runRemoveCmd(cmd *cobra.Command, args []string) error {
 		if err := c.mutator.RemoveAll(sourceDirPath); err != nil && !os.IsNotExist(err) {
 			return err
 		}
+		c.recordCommitOperation("remove", entry)
 	}
 	return nil
 }
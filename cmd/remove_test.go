@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestRemoveCmdSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": "# personal .bashrc\n",
+			".vimrc":  "# team .vimrc\n",
+		},
+		"/home/user/.local/share/chezmoi-team": map[string]interface{}{
+			"dot_vimrc": "# team .vimrc\n",
+		},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc": "# personal .bashrc\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	c := newTestConfig(fs, func(c *Config) {
+		c.SourceDirs = []string{
+			"/home/user/.local/share/chezmoi-team",
+			"/home/user/.local/share/chezmoi",
+		}
+		c.remove.force = true
+	})
+
+	// No entry is removed if any entry comes from another source directory.
+	assert.EqualError(t, c.runRemoveCmd(nil, []string{"/home/user/.bashrc", "/home/user/.vimrc"}), "/home/user/.vimrc: in source directory /home/user/.local/share/chezmoi-team, not /home/user/.local/share/chezmoi")
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# personal .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_bashrc",
+			vfst.TestContentsString("# personal .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.vimrc",
+			vfst.TestContentsString("# team .vimrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi-team/dot_vimrc",
+			vfst.TestContentsString("# team .vimrc\n"),
+		),
+	)
+
+	assert.NoError(t, c.runRemoveCmd(nil, []string{"/home/user/.bashrc"}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_bashrc",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/coreos/go-semver/semver"
@@ -27,11 +29,12 @@ var (
 )
 
 var rootCmd = &cobra.Command{
-	Use:               "chezmoi",
-	Short:             "Manage your dotfiles across multiple machines, securely",
-	SilenceErrors:     true,
-	SilenceUsage:      true,
-	PersistentPreRunE: config.persistentPreRunRootE,
+	Use:                "chezmoi",
+	Short:              "Manage your dotfiles across multiple machines, securely",
+	SilenceErrors:      true,
+	SilenceUsage:       true,
+	PersistentPreRunE:  config.persistentPreRunRootE,
+	PersistentPostRunE: config.persistentPostRunRootE,
 }
 
 func init() {
@@ -49,15 +52,26 @@ func init() {
 
 	persistentFlags.StringVarP(&config.configFile, "config", "c", getDefaultConfigFile(config.bds), "config file")
 
+	config.AuditLog.File = filepath.Join(getDefaultStateDir(homeDir), "audit.log")
+
+	config.Backup.Dir = filepath.Join(getDefaultStateDir(homeDir), "backups")
+	persistentFlags.StringVar(&config.backupFlag, "backup", "", "back up overwritten and removed targets to directory")
+	persistentFlags.Lookup("backup").NoOptDefVal = config.Backup.Dir
+	panicOnError(rootCmd.MarkPersistentFlagDirname("backup"))
+
 	persistentFlags.BoolVarP(&config.DryRun, "dry-run", "n", false, "dry run")
 	panicOnError(viper.BindPFlag("dry-run", persistentFlags.Lookup("dry-run")))
 
 	persistentFlags.BoolVar(&config.Follow, "follow", false, "follow symlinks")
 	panicOnError(viper.BindPFlag("follow", persistentFlags.Lookup("follow")))
 
-	persistentFlags.BoolVar(&config.Remove, "remove", false, "remove targets")
+	persistentFlags.StringVar(&config.Remove, "remove", "false", "remove targets (true, false, or prompt)")
+	persistentFlags.Lookup("remove").NoOptDefVal = "true"
 	panicOnError(viper.BindPFlag("remove", persistentFlags.Lookup("remove")))
 
+	persistentFlags.BoolVar(&config.Strict, "strict", false, "always compare target contents in full")
+	panicOnError(viper.BindPFlag("strict", persistentFlags.Lookup("strict")))
+
 	persistentFlags.StringVarP(&config.SourceDir, "source", "S", getDefaultSourceDir(config.bds), "source directory")
 	panicOnError(viper.BindPFlag("source", persistentFlags.Lookup("source")))
 
@@ -73,38 +87,54 @@ func init() {
 	persistentFlags.BoolVar(&config.Debug, "debug", false, "write debug logs")
 	panicOnError(viper.BindPFlag("debug", persistentFlags.Lookup("debug")))
 
+	persistentFlags.StringVar(&config.profiling.cpuProfile, "cpu-profile", "", "write CPU profile to file")
+	panicOnError(rootCmd.MarkPersistentFlagFilename("cpu-profile"))
+
+	persistentFlags.BoolVar(&config.profiling.timings, "timings", false, "report time spent in each phase")
+
+	persistentFlags.StringVar(&config.profiling.trace, "trace", "", "write execution trace to file")
+	panicOnError(rootCmd.MarkPersistentFlagFilename("trace"))
+
 	cobra.OnInitialize(func() {
-		_, err := os.Stat(config.configFile)
-		switch {
-		case err == nil:
-			viper.SetConfigFile(config.configFile)
-			config.err = viper.ReadInConfig()
-			if config.err == nil {
-				config.err = viper.Unmarshal(&config)
-			}
-			if config.err == nil {
-				config.err = config.validateData()
-			}
-			if config.err != nil {
-				rootCmd.Printf("warning: %s: %v\n", config.configFile, config.err)
-			}
-			if config.GPGRecipient != "" {
-				rootCmd.Printf("" +
-					"warning: your config file uses gpgRecipient which will be deprecated in v2\n" +
-					"warning: to disable this warning, set gpg.recipient in your config file instead\n",
-				)
-			}
-			if config.SourceVCS.Command != "" && !config.SourceVCS.NotGit && !strings.Contains(filepath.Base(config.SourceVCS.Command), "git") {
-				rootCmd.Printf("" +
-					"warning: it looks like you are using a version control system that is not git which will be deprecated in v2\n" +
-					"warning: please report this at https://github.com/twpayne/chezmoi/issues/459\n" +
-					"warning: to disable this warning, set sourceVCS.notGit = true in your config file\n",
-				)
-			}
-		case os.IsNotExist(err):
-		default:
+		// Check the config file before starting profiling so that profiling
+		// is only started on paths that exit through Execute, which stops it.
+		_, statErr := os.Stat(config.configFile)
+		if statErr != nil && !os.IsNotExist(statErr) {
+			printErrorAndExit(statErr)
+		}
+		if err := config.startProfiling(); err != nil {
 			printErrorAndExit(err)
 		}
+		defer config.timings.Start("config", config.configFile)()
+
+		if statErr != nil {
+			return
+		}
+
+		viper.SetConfigFile(config.configFile)
+		config.err = viper.ReadInConfig()
+		if config.err == nil {
+			config.err = viper.Unmarshal(&config)
+		}
+		if config.err == nil {
+			config.err = config.validateData()
+		}
+		if config.err != nil {
+			rootCmd.Printf("warning: %s: %v\n", config.configFile, config.err)
+		}
+		if config.GPGRecipient != "" {
+			rootCmd.Printf("" +
+				"warning: your config file uses gpgRecipient which will be deprecated in v2\n" +
+				"warning: to disable this warning, set gpg.recipient in your config file instead\n",
+			)
+		}
+		if config.SourceVCS.Command != "" && !config.SourceVCS.NotGit && !strings.Contains(filepath.Base(config.SourceVCS.Command), "git") {
+			rootCmd.Printf("" +
+				"warning: it looks like you are using a version control system that is not git which will be deprecated in v2\n" +
+				"warning: please report this at https://github.com/twpayne/chezmoi/issues/459\n" +
+				"warning: to disable this warning, set sourceVCS.notGit = true in your config file\n",
+			)
+		}
 	})
 }
 
@@ -132,7 +162,18 @@ func Execute() {
 	}
 	rootCmd.Version = strings.Join(versionComponents, ", ")
 
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if stopErr := config.stopProfiling(); err == nil {
+		err = stopErr
+	}
+	if closeErr := config.closeAuditLog(); err == nil {
+		err = closeErr
+	}
+	var exitCode exitCodeError
+	if errors.As(err, &exitCode) {
+		os.Exit(int(exitCode))
+	}
+	if err != nil {
 		printErrorAndExit(err)
 	}
 }
@@ -160,10 +201,48 @@ func (c *Config) persistentPreRunRootE(cmd *cobra.Command, args []string) error
 		}
 	}
 
+	switch c.Mode {
+	case chezmoi.ModeFile, chezmoi.ModeSymlink:
+	default:
+		return fmt.Errorf("invalid mode value: %s", c.Mode)
+	}
+
+	if c.backupFlag != "" {
+		c.Backup.Enabled = true
+		c.Backup.Dir = c.backupFlag
+	}
+
+	if c.Remove != "prompt" {
+		if _, err := strconv.ParseBool(c.Remove); err != nil {
+			return fmt.Errorf("invalid remove value: %s", c.Remove)
+		}
+	}
+
+	if err := c.validateRoots(cmd); err != nil {
+		return err
+	}
+
 	c.fs = vfs.OSFS
 	c.mutator = chezmoi.NewFSMutator(config.fs)
 	if c.DryRun {
 		c.mutator = chezmoi.NullMutator{}
+	} else if escalate, err := c.escalatePredicate(); err != nil {
+		return err
+	} else if escalate != nil {
+		c.mutator = chezmoi.NewEscalateMutator(c.mutator, c.fs, c.Escalation.Command, c.Escalation.Args, escalate)
+	}
+	var err error
+	if c.mutator, err = c.backupMutator(c.mutator); err != nil {
+		return err
+	}
+	if err := c.openAuditLog(cmd, args); err != nil {
+		return err
+	}
+	if c.auditLog != nil {
+		c.mutator = chezmoi.NewAuditLogMutator(c.mutator, c.fs, c.auditLog)
+	}
+	if c.timings != nil {
+		c.mutator = chezmoi.NewTimingMutator(c.mutator, c.timings)
 	}
 	if c.Debug {
 		c.mutator = chezmoi.NewDebugMutator(c.mutator)
@@ -172,6 +251,10 @@ func (c *Config) persistentPreRunRootE(cmd *cobra.Command, args []string) error
 		c.mutator = chezmoi.NewVerboseMutator(c.Stdout, c.mutator, c.colored, c.maxDiffDataSize)
 	}
 
+	if err := c.selectSourceDir(cmd); err != nil {
+		return err
+	}
+
 	info, err := c.fs.Stat(c.SourceDir)
 	switch {
 	case err == nil && !info.IsDir():
@@ -189,7 +272,16 @@ func (c *Config) persistentPreRunRootE(cmd *cobra.Command, args []string) error
 	}
 
 	// Apply any fixes for snap, if needed.
-	return c.snapFix()
+	if err := c.snapFix(); err != nil {
+		return err
+	}
+
+	return c.runHook(cmd, "pre", args)
+}
+
+//nolint:interfacer
+func (c *Config) persistentPostRunRootE(cmd *cobra.Command, args []string) error {
+	return c.runHook(cmd, "post", args)
 }
 
 func getExample(command string) string {
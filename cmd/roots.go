@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// homeRootName is the name of the root whose destination directory is the
+// destination directory and whose source is the whole source state.
+const homeRootName = "home"
+
+type rootConfig struct {
+	DestDir  string
+	Umask    *permValue
+	Escalate bool
+}
+
+type escalationConfig struct {
+	Command string
+	Args    []string
+}
+
+// validateRoots checks the configured roots and applies any configuration of
+// the home root.
+func (c *Config) validateRoots(cmd *cobra.Command) error {
+	for name, root := range c.Roots {
+		if name == homeRootName {
+			continue
+		}
+		if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("%s: invalid root name", name)
+		}
+		if root.DestDir == "" {
+			return fmt.Errorf("roots.%s.destDir: not set", name)
+		}
+	}
+	if home, ok := c.Roots[homeRootName]; ok {
+		if home.DestDir != "" && !cmd.Flags().Changed("destination") && !viper.IsSet("destDir") {
+			c.DestDir = home.DestDir
+		}
+		if home.Umask != nil {
+			c.Umask = *home.Umask
+		}
+	}
+	return nil
+}
+
+// escalatePredicate returns a function that returns true if changes to a path
+// require elevated privileges, or nil if no root requires elevated privileges.
+// A path requires elevated privileges if the root with the longest destination
+// directory that contains it is configured to escalate. The source directory
+// never requires elevated privileges.
+func (c *Config) escalatePredicate() (func(string) bool, error) {
+	escalateDirs := make(map[string]bool)
+	anyEscalate := false
+	addDir := func(dir string, escalate bool) error {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		escalateDirs[absDir] = escalateDirs[absDir] || escalate
+		anyEscalate = anyEscalate || escalate
+		return nil
+	}
+	if err := addDir(c.DestDir, c.Roots[homeRootName].Escalate); err != nil {
+		return nil, err
+	}
+	for name, root := range c.Roots {
+		if name == homeRootName {
+			continue
+		}
+		if err := addDir(root.DestDir, root.Escalate); err != nil {
+			return nil, err
+		}
+	}
+	if !anyEscalate {
+		return nil, nil
+	}
+	absSourceDir, err := filepath.Abs(c.SourceDir)
+	if err != nil {
+		return nil, err
+	}
+	escalateDirs[absSourceDir] = false
+
+	return func(path string) bool {
+		longestDir := ""
+		escalate := false
+		for dir, dirEscalate := range escalateDirs {
+			if pathHasDirPrefix(path, dir) && len(dir) > len(longestDir) {
+				longestDir = dir
+				escalate = dirEscalate
+			}
+		}
+		return escalate
+	}, nil
+}
+
+// pathHasDirPrefix returns true if path is dir or is in dir.
+func pathHasDirPrefix(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
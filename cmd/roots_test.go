@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestApplyRoots(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc":                  "# contents of .bashrc\n",
+			"system/etc/hosts":            "# contents of /etc/hosts\n",
+			"system/usr/local/bin/dot_ok": "# contents of /usr/local/bin/.ok\n",
+		},
+		"/etc":           &vfst.Dir{Perm: 0755},
+		"/usr/local/bin": &vfst.Dir{Perm: 0755},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(fs, withRoots(map[string]rootConfig{
+		"system": {DestDir: "/"},
+	}))
+	require.NoError(t, c.runApplyCmd(nil, nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/system",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/etc/hosts",
+			vfst.TestContentsString("# contents of /etc/hosts\n"),
+		),
+		vfst.TestPath("/usr/local/bin/.ok",
+			vfst.TestContentsString("# contents of /usr/local/bin/.ok\n"),
+		),
+	)
+}
+
+func TestEscalatePredicate(t *testing.T) {
+	c := newConfig(
+		withTestUser("user"),
+		withRoots(map[string]rootConfig{
+			"system": {DestDir: "/", Escalate: true},
+			"opt":    {DestDir: "/opt/user"},
+		}),
+	)
+	escalate, err := c.escalatePredicate()
+	require.NoError(t, err)
+	for path, expected := range map[string]bool{
+		"/etc/hosts":                             true,
+		"/home/user/.bashrc":                     false,
+		"/home/user/.local/share/chezmoi/system": false,
+		"/opt":                                   true,
+		"/opt/user/bin/tool":                     false,
+		"/opt/username":                          true,
+	} {
+		assert.Equal(t, expected, escalate(path), path)
+	}
+
+	c = newConfig(
+		withTestUser("user"),
+		withRoots(map[string]rootConfig{
+			"opt": {DestDir: "/opt/user"},
+		}),
+	)
+	escalate, err = c.escalatePredicate()
+	require.NoError(t, err)
+	assert.Nil(t, escalate)
+}
@@ -22,7 +22,7 @@ type bitwardenCmdConfig struct {
 	Command string
 }
 
-var bitwardenCache = make(map[string]interface{})
+var bitwardenCache concurrentCache
 
 func init() {
 	config.Bitwarden.Command = "bw"
@@ -37,22 +37,21 @@ func (c *Config) runBitwardenCmd(cmd *cobra.Command, args []string) error {
 
 func (c *Config) bitwardenFunc(args ...string) interface{} {
 	key := strings.Join(args, "\x00")
-	if data, ok := bitwardenCache[key]; ok {
+	return bitwardenCache.get(key, func() interface{} {
+		defer c.timings.Start("secret", "bitwarden "+strings.Join(args, " "))()
+		name := c.Bitwarden.Command
+		args := append([]string{"get"}, args...)
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := c.mutator.IdempotentCmdOutput(cmd)
+		if err != nil {
+			panic(fmt.Errorf("bitwarden: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
+		var data interface{}
+		if err := json.Unmarshal(output, &data); err != nil {
+			panic(fmt.Errorf("bitwarden: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
 		return data
-	}
-	name := c.Bitwarden.Command
-	args = append([]string{"get"}, args...)
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	output, err := c.mutator.IdempotentCmdOutput(cmd)
-	if err != nil {
-		panic(fmt.Errorf("bitwarden: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	var data interface{}
-	if err := json.Unmarshal(output, &data); err != nil {
-		panic(fmt.Errorf("bitwarden: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	bitwardenCache[key] = data
-	return data
+	})
 }
@@ -24,8 +24,8 @@ type genericSecretCmdConfig struct {
 }
 
 var (
-	secretCache     = make(map[string][]byte)
-	secretJSONCache = make(map[string]interface{})
+	secretCache     concurrentCache
+	secretJSONCache concurrentCache
 )
 
 func init() {
@@ -41,39 +41,37 @@ func (c *Config) runGenericSecretCmd(cmd *cobra.Command, args []string) error {
 
 func (c *Config) secretFunc(args ...string) interface{} {
 	key := strings.Join(args, "\x00")
-	if value, ok := secretCache[key]; ok {
+	return secretCache.get(key, func() interface{} {
+		defer c.timings.Start("secret", "secret "+strings.Join(args, " "))()
+		name := c.GenericSecret.Command
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := c.mutator.IdempotentCmdOutput(cmd)
+		if err != nil {
+			panic(fmt.Errorf("secret: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
+		value := bytes.TrimSpace(output)
 		return value
-	}
-	name := c.GenericSecret.Command
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	output, err := c.mutator.IdempotentCmdOutput(cmd)
-	if err != nil {
-		panic(fmt.Errorf("secret: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	value := bytes.TrimSpace(output)
-	secretCache[key] = value
-	return value
+	})
 }
 
 func (c *Config) secretJSONFunc(args ...string) interface{} {
 	key := strings.Join(args, "\x00")
-	if value, ok := secretJSONCache[key]; ok {
+	return secretJSONCache.get(key, func() interface{} {
+		defer c.timings.Start("secret", "secretJSON "+strings.Join(args, " "))()
+		name := c.GenericSecret.Command
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := c.mutator.IdempotentCmdOutput(cmd)
+		if err != nil {
+			panic(fmt.Errorf("secretJSON: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
+		var value interface{}
+		if err := json.Unmarshal(output, &value); err != nil {
+			panic(fmt.Errorf("secretJSON: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
 		return value
-	}
-	name := c.GenericSecret.Command
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	output, err := c.mutator.IdempotentCmdOutput(cmd)
-	if err != nil {
-		panic(fmt.Errorf("secretJSON: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	var value interface{}
-	if err := json.Unmarshal(output, &value); err != nil {
-		panic(fmt.Errorf("secretJSON: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	secretJSONCache[key] = value
-	return value
+	})
 }
@@ -20,7 +20,7 @@ type gopassCmdConfig struct {
 	Command string
 }
 
-var gopassCache = make(map[string]string)
+var gopassCache concurrentCache
 
 func init() {
 	secretCmd.AddCommand(gopassCmd)
@@ -34,22 +34,21 @@ func (c *Config) runSecretGopassCmd(cmd *cobra.Command, args []string) error {
 }
 
 func (c *Config) gopassFunc(id string) string {
-	if s, ok := gopassCache[id]; ok {
-		return s
-	}
-	name := c.Gopass.Command
-	args := []string{"show", id}
-	cmd := exec.Command(name, args...)
-	output, err := c.mutator.IdempotentCmdOutput(cmd)
-	if err != nil {
-		panic(fmt.Errorf("gopass: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
-	}
-	var password string
-	if index := bytes.IndexByte(output, '\n'); index != -1 {
-		password = string(output[:index])
-	} else {
-		password = string(output)
-	}
-	gopassCache[id] = password
-	return gopassCache[id]
+	return gopassCache.get(id, func() interface{} {
+		defer c.timings.Start("secret", "gopass "+id)()
+		name := c.Gopass.Command
+		args := []string{"show", id}
+		cmd := exec.Command(name, args...)
+		output, err := c.mutator.IdempotentCmdOutput(cmd)
+		if err != nil {
+			panic(fmt.Errorf("gopass: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
+		}
+		var password string
+		if index := bytes.IndexByte(output, '\n'); index != -1 {
+			password = string(output[:index])
+		} else {
+			password = string(output)
+		}
+		return password
+	}).(string)
 }
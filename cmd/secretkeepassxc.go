@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/coreos/go-semver/semver"
 	"github.com/spf13/cobra"
@@ -36,8 +37,9 @@ type keePassXCAttributeCacheKey struct {
 
 var (
 	keePassXCVersion                     *semver.Version
-	keePassXCCache                       = make(map[string]map[string]string)
-	keePassXCAttributeCache              = make(map[keePassXCAttributeCacheKey]string)
+	keePassXCCache                       concurrentCache
+	keePassXCAttributeCache              concurrentCache
+	keePassXCMutex                       sync.Mutex // keePassXCMutex protects keePassXCPassword and keePassXCVersion.
 	keePassXCPairRegexp                  = regexp.MustCompile(`^([^:]+): (.*)$`)
 	keePassXCPassword                    string
 	keePassXCNeedShowProtectedArgVersion = semver.Version{Major: 2, Minor: 5, Patch: 1}
@@ -56,6 +58,8 @@ func (c *Config) runKeePassXCCmd(cmd *cobra.Command, args []string) error {
 }
 
 func (c *Config) getKeePassXCVersion() *semver.Version {
+	keePassXCMutex.Lock()
+	defer keePassXCMutex.Unlock()
 	if keePassXCVersion != nil {
 		return keePassXCVersion
 	}
@@ -73,30 +77,46 @@ func (c *Config) getKeePassXCVersion() *semver.Version {
 	return keePassXCVersion
 }
 
+// getKeePassXCPassword returns the password to unlock the KeePassXC database,
+// prompting the user for it the first time it is needed.
+func (c *Config) getKeePassXCPassword() (string, error) {
+	keePassXCMutex.Lock()
+	defer keePassXCMutex.Unlock()
+	if keePassXCPassword == "" {
+		fmt.Printf("Insert password to unlock %s: ", c.KeePassXC.Database)
+		password, err := terminal.ReadPassword(int(os.Stdout.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		keePassXCPassword = string(password)
+	}
+	return keePassXCPassword, nil
+}
+
 func (c *Config) keePassXCFunc(entry string) map[string]string {
-	if data, ok := keePassXCCache[entry]; ok {
+	return keePassXCCache.get(entry, func() interface{} {
+		defer c.timings.Start("secret", "keepassxc "+entry)()
+		if c.KeePassXC.Database == "" {
+			panic(errors.New("keepassxc: keepassxc.database not set"))
+		}
+		name := c.KeePassXC.Command
+		args := []string{"show"}
+		if c.getKeePassXCVersion().Compare(keePassXCNeedShowProtectedArgVersion) >= 0 {
+			args = append(args, "--show-protected")
+		}
+		args = append(args, c.KeePassXC.Args...)
+		args = append(args, c.KeePassXC.Database, entry)
+		output, err := c.runKeePassXCCLICommand(name, args)
+		if err != nil {
+			panic(fmt.Errorf("keepassxc: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
+		}
+		data, err := parseKeyPassXCOutput(output)
+		if err != nil {
+			panic(fmt.Errorf("keepassxc: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
+		}
 		return data
-	}
-	if c.KeePassXC.Database == "" {
-		panic(errors.New("keepassxc: keepassxc.database not set"))
-	}
-	name := c.KeePassXC.Command
-	args := []string{"show"}
-	if c.getKeePassXCVersion().Compare(keePassXCNeedShowProtectedArgVersion) >= 0 {
-		args = append(args, "--show-protected")
-	}
-	args = append(args, c.KeePassXC.Args...)
-	args = append(args, c.KeePassXC.Database, entry)
-	output, err := c.runKeePassXCCLICommand(name, args)
-	if err != nil {
-		panic(fmt.Errorf("keepassxc: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
-	}
-	data, err := parseKeyPassXCOutput(output)
-	if err != nil {
-		panic(fmt.Errorf("keepassxc: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
-	}
-	keePassXCCache[entry] = data
-	return data
+	}).(map[string]string)
 }
 
 func (c *Config) keePassXCAttributeFunc(entry, attribute string) string {
@@ -104,40 +124,33 @@ func (c *Config) keePassXCAttributeFunc(entry, attribute string) string {
 		entry:     entry,
 		attribute: attribute,
 	}
-	if data, ok := keePassXCAttributeCache[key]; ok {
-		return data
-	}
-	if c.KeePassXC.Database == "" {
-		panic(errors.New("keepassxc: keepassxc.database not set"))
-	}
-	name := c.KeePassXC.Command
-	args := []string{"show", "--attributes", attribute, "--quiet"}
-	if c.getKeePassXCVersion().Compare(keePassXCNeedShowProtectedArgVersion) >= 0 {
-		args = append(args, "--show-protected")
-	}
-	args = append(args, c.KeePassXC.Args...)
-	args = append(args, c.KeePassXC.Database, entry)
-	output, err := c.runKeePassXCCLICommand(name, args)
-	if err != nil {
-		panic(fmt.Errorf("keepassxc: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
-	}
-	outputStr := strings.TrimSpace(string(output))
-	keePassXCAttributeCache[key] = outputStr
-	return outputStr
+	return keePassXCAttributeCache.get(key, func() interface{} {
+		defer c.timings.Start("secret", "keepassxcAttribute "+entry+" "+attribute)()
+		if c.KeePassXC.Database == "" {
+			panic(errors.New("keepassxc: keepassxc.database not set"))
+		}
+		name := c.KeePassXC.Command
+		args := []string{"show", "--attributes", attribute, "--quiet"}
+		if c.getKeePassXCVersion().Compare(keePassXCNeedShowProtectedArgVersion) >= 0 {
+			args = append(args, "--show-protected")
+		}
+		args = append(args, c.KeePassXC.Args...)
+		args = append(args, c.KeePassXC.Database, entry)
+		output, err := c.runKeePassXCCLICommand(name, args)
+		if err != nil {
+			panic(fmt.Errorf("keepassxc: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
+		}
+		return strings.TrimSpace(string(output))
+	}).(string)
 }
 
 func (c *Config) runKeePassXCCLICommand(name string, args []string) ([]byte, error) {
-	if keePassXCPassword == "" {
-		fmt.Printf("Insert password to unlock %s: ", c.KeePassXC.Database)
-		password, err := terminal.ReadPassword(int(os.Stdout.Fd()))
-		fmt.Println()
-		if err != nil {
-			return nil, err
-		}
-		keePassXCPassword = string(password)
+	password, err := c.getKeePassXCPassword()
+	if err != nil {
+		return nil, err
 	}
 	cmd := exec.Command(name, args...)
-	cmd.Stdin = bytes.NewBufferString(keePassXCPassword + "\n")
+	cmd.Stdin = bytes.NewBufferString(password + "\n")
 	cmd.Stderr = c.Stderr
 	return c.mutator.IdempotentCmdOutput(cmd)
 }
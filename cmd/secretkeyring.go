@@ -24,7 +24,7 @@ type keyringKey struct {
 	user    string
 }
 
-var keyringCache = make(map[keyringKey]string)
+var keyringCache concurrentCache
 
 func init() {
 	secretCmd.AddCommand(keyringCmd)
@@ -40,18 +40,17 @@ func init() {
 	config.addTemplateFunc("keyring", config.keyringFunc)
 }
 
-func (*Config) keyringFunc(service, user string) string {
+func (c *Config) keyringFunc(service, user string) string {
 	key := keyringKey{
 		service: service,
 		user:    user,
 	}
-	if password, ok := keyringCache[key]; ok {
+	return keyringCache.get(key, func() interface{} {
+		defer c.timings.Start("secret", "keyring "+service+" "+user)()
+		password, err := keyring.Get(service, user)
+		if err != nil {
+			panic(fmt.Errorf("keyring %q %q: %w", service, user, err))
+		}
 		return password
-	}
-	password, err := keyring.Get(service, user)
-	if err != nil {
-		panic(fmt.Errorf("keyring %q %q: %w", service, user, err))
-	}
-	keyringCache[key] = password
-	return password
+	}).(string)
 }
@@ -38,7 +38,7 @@ type lastpassCmdConfig struct {
 	versionCheckOnce sync.Once
 }
 
-var lastPassCache = make(map[string][]map[string]interface{})
+var lastPassCache concurrentCache
 
 func init() {
 	config.Lastpass.Command = "lpass"
@@ -68,25 +68,31 @@ func (c *Config) lastpassRawFunc(id string) []map[string]interface{} {
 	c.Lastpass.versionCheckOnce.Do(func() {
 		panicOnError(c.lastpassVersionCheck())
 	})
-	if data, ok := lastPassCache[id]; ok {
+	return lastPassCache.get(id, func() interface{} {
+		defer c.timings.Start("secret", "lastpass "+id)()
+		output, err := c.lastpassOutput("show", "--json", id)
+		panicOnError(err)
+		var data []map[string]interface{}
+		if err := json.Unmarshal(output, &data); err != nil {
+			panic(fmt.Errorf("lastpass: parse error: %w\n%q", err, output))
+		}
 		return data
-	}
-	output, err := c.lastpassOutput("show", "--json", id)
-	panicOnError(err)
-	var data []map[string]interface{}
-	if err := json.Unmarshal(output, &data); err != nil {
-		panic(fmt.Errorf("lastpass: parse error: %w\n%q", err, output))
-	}
-	lastPassCache[id] = data
-	return data
+	}).([]map[string]interface{})
 }
 
 func (c *Config) lastpassFunc(id string) []map[string]interface{} {
-	data := c.lastpassRawFunc(id)
-	for _, d := range data {
+	// Copy the cached raw data so that parsing notes does not modify it.
+	rawData := c.lastpassRawFunc(id)
+	data := make([]map[string]interface{}, 0, len(rawData))
+	for _, rawD := range rawData {
+		d := make(map[string]interface{}, len(rawD))
+		for key, value := range rawD {
+			d[key] = value
+		}
 		if note, ok := d["note"].(string); ok {
 			d["note"] = lastpassParseNote(note)
 		}
+		data = append(data, d)
 	}
 	return data
 }
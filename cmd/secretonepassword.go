@@ -22,8 +22,8 @@ type onepasswordCmdConfig struct {
 }
 
 var (
-	onepasswordCache         = make(map[string]interface{})
-	onepasswordDocumentCache = make(map[string]string)
+	onepasswordCache         concurrentCache
+	onepasswordDocumentCache concurrentCache
 )
 
 func init() {
@@ -39,39 +39,37 @@ func (c *Config) runOnepasswordCmd(cmd *cobra.Command, args []string) error {
 }
 
 func (c *Config) onepasswordFunc(item string) interface{} {
-	if data, ok := onepasswordCache[item]; ok {
+	return onepasswordCache.get(item, func() interface{} {
+		defer c.timings.Start("secret", "onepassword "+item)()
+		name := c.Onepassword.Command
+		args := []string{"get", "item", item}
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := c.mutator.IdempotentCmdOutput(cmd)
+		if err != nil {
+			panic(fmt.Errorf("onepassword: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
+		var data interface{}
+		if err := json.Unmarshal(output, &data); err != nil {
+			panic(fmt.Errorf("onepassword: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
 		return data
-	}
-	name := c.Onepassword.Command
-	args := []string{"get", "item", item}
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	output, err := c.mutator.IdempotentCmdOutput(cmd)
-	if err != nil {
-		panic(fmt.Errorf("onepassword: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	var data interface{}
-	if err := json.Unmarshal(output, &data); err != nil {
-		panic(fmt.Errorf("onepassword: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	onepasswordCache[item] = data
-	return data
+	})
 }
 
 func (c *Config) onepasswordDocumentFunc(item string) interface{} {
-	if output, ok := onepasswordDocumentCache[item]; ok {
-		return output
-	}
-	name := c.Onepassword.Command
-	args := []string{"get", "document", item}
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	output, err := c.mutator.IdempotentCmdOutput(cmd)
-	if err != nil {
-		panic(fmt.Errorf("onepassword: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	onepasswordDocumentCache[item] = string(output)
-	return string(output)
+	return onepasswordDocumentCache.get(item, func() interface{} {
+		defer c.timings.Start("secret", "onepasswordDocument "+item)()
+		name := c.Onepassword.Command
+		args := []string{"get", "document", item}
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := c.mutator.IdempotentCmdOutput(cmd)
+		if err != nil {
+			panic(fmt.Errorf("onepassword: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
+		return string(output)
+	}).(string)
 }
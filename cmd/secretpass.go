@@ -20,7 +20,7 @@ type passCmdConfig struct {
 	Command string
 }
 
-var passCache = make(map[string]string)
+var passCache concurrentCache
 
 func init() {
 	secretCmd.AddCommand(passCmd)
@@ -34,22 +34,21 @@ func (c *Config) runSecretPassCmd(cmd *cobra.Command, args []string) error {
 }
 
 func (c *Config) passFunc(id string) string {
-	if s, ok := passCache[id]; ok {
-		return s
-	}
-	name := c.Pass.Command
-	args := []string{"show", id}
-	cmd := exec.Command(name, args...)
-	output, err := c.mutator.IdempotentCmdOutput(cmd)
-	if err != nil {
-		panic(fmt.Errorf("pass: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
-	}
-	var password string
-	if index := bytes.IndexByte(output, '\n'); index != -1 {
-		password = string(output[:index])
-	} else {
-		password = string(output)
-	}
-	passCache[id] = password
-	return passCache[id]
+	return passCache.get(id, func() interface{} {
+		defer c.timings.Start("secret", "pass "+id)()
+		name := c.Pass.Command
+		args := []string{"show", id}
+		cmd := exec.Command(name, args...)
+		output, err := c.mutator.IdempotentCmdOutput(cmd)
+		if err != nil {
+			panic(fmt.Errorf("pass: %s %s: %w", name, chezmoi.ShellQuoteArgs(args), err))
+		}
+		var password string
+		if index := bytes.IndexByte(output, '\n'); index != -1 {
+			password = string(output[:index])
+		} else {
+			password = string(output)
+		}
+		return password
+	}).(string)
 }
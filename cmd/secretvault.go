@@ -21,7 +21,7 @@ type vaultCmdConfig struct {
 	Command string
 }
 
-var vaultCache = make(map[string]interface{})
+var vaultCache concurrentCache
 
 func init() {
 	config.Vault.Command = "vault"
@@ -35,22 +35,21 @@ func (c *Config) runVaultCmd(cmd *cobra.Command, args []string) error {
 }
 
 func (c *Config) vaultFunc(key string) interface{} {
-	if data, ok := vaultCache[key]; ok {
+	return vaultCache.get(key, func() interface{} {
+		defer c.timings.Start("secret", "vault "+key)()
+		name := c.Vault.Command
+		args := []string{"kv", "get", "-format=json", key}
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := c.mutator.IdempotentCmdOutput(cmd)
+		if err != nil {
+			panic(fmt.Errorf("vault: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
+		var data interface{}
+		if err := json.Unmarshal(output, &data); err != nil {
+			panic(fmt.Errorf("vault: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
+		}
 		return data
-	}
-	name := c.Vault.Command
-	args := []string{"kv", "get", "-format=json", key}
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	output, err := c.mutator.IdempotentCmdOutput(cmd)
-	if err != nil {
-		panic(fmt.Errorf("vault: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	var data interface{}
-	if err := json.Unmarshal(output, &data); err != nil {
-		panic(fmt.Errorf("vault: %s %s: %w\n%s", name, chezmoi.ShellQuoteArgs(args), err, output))
-	}
-	vaultCache[key] = data
-	return data
+	})
 }
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	"github.com/twpayne/go-shell"
+)
+
+// runTriggers runs each command in ts's triggers that matches any of
+// targetPaths once, using the user's shell.
+func (c *Config) runTriggers(ts *chezmoi.TargetState, targetPaths []string) error {
+	commands := ts.TriggeredCommands(targetPaths)
+	if len(commands) == 0 {
+		return nil
+	}
+	shell, _ := shell.CurrentUserShell()
+	for _, command := range commands {
+		//nolint:gosec
+		cmd := exec.Command(shell, "-c", command)
+		cmd.Stdin = c.Stdin
+		cmd.Stdout = c.Stdout
+		cmd.Stderr = c.Stderr
+		if err := c.mutator.RunCmd(cmd); err != nil {
+			return fmt.Errorf("trigger %q: %w", command, err)
+		}
+	}
+	return nil
+}
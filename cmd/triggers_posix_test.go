@@ -0,0 +1,41 @@
+// +build !windows
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestTriggers(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc":         "# contents of .bashrc\n",
+			"dot_tmux.conf":      "# contents of .tmux.conf\n",
+			".chezmoitriggers":   "",
+			"dot_config/i3/conf": "# contents of .config/i3/conf\n",
+		},
+		"/home/user/.config/i3/conf": "# contents of .config/i3/conf\n",
+		"/home/user/triggers.log":    "",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	triggersLog, err := fs.RawPath("/home/user/triggers.log")
+	require.NoError(t, err)
+	require.NoError(t, fs.WriteFile("/home/user/.local/share/chezmoi/.chezmoitriggers", []byte(""+
+		".bashrc echo bashrc >> "+triggersLog+"\n"+
+		".tmux.conf echo tmux >> "+triggersLog+"\n"+
+		".bash* echo bashrc >> "+triggersLog+"\n"+
+		".config/i3 echo i3 >> "+triggersLog+"\n",
+	), 0644))
+
+	c := newTestConfig(fs)
+	require.NoError(t, c.runApplyCmd(nil, nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/triggers.log",
+			vfst.TestContentsString("bashrc\ntmux\n"),
+		),
+	)
+}
@@ -38,7 +38,11 @@ func (c *Config) runUnmanagedCmd(cmd *cobra.Command, args []string) error {
 		}
 		entry, _ := ts.Get(c.fs, path)
 		managed := entry != nil
-		ignored := ts.TargetIgnore.Match(strings.TrimPrefix(path, c.DestDir+"/"))
+		targetName := strings.TrimPrefix(path, c.DestDir+"/")
+		ignored := ts.Ignore(targetName)
+		if info.IsDir() {
+			ignored = ts.TargetIgnore.MatchDir(targetName)
+		}
 		if !managed && !ignored {
 			fmt.Println(path)
 		}
@@ -2,13 +2,18 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	"github.com/twpayne/chezmoi/internal/git"
+	vfs "github.com/twpayne/go-vfs"
 )
 
 type updateCmdConfig struct {
-	apply bool
+	apply  bool
+	review bool
 }
 
 var updateCmd = &cobra.Command{
@@ -26,6 +31,7 @@ func init() {
 
 	persistentFlags := updateCmd.PersistentFlags()
 	persistentFlags.BoolVarP(&config.update.apply, "apply", "a", true, "apply after pulling")
+	persistentFlags.BoolVar(&config.update.review, "review", false, "review changes before applying")
 }
 
 func (c *Config) runUpdateCmd(cmd *cobra.Command, args []string) error {
@@ -50,20 +56,145 @@ func (c *Config) runUpdateCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("%s: pull not supported", c.SourceVCS.Command)
 	}
 
+	c.warnSourceVCSStatus(cmd, vcs)
+
+	// If reviewing, record the current source revision so that it can be
+	// restored if the user declines the changes or if the pulled source state
+	// cannot be evaluated.
+	review := c.update.apply && c.update.review
+	var prevRevision string
+	if review {
+		headArgs := vcs.HeadArgs()
+		if headArgs == nil || vcs.ResetArgs("") == nil {
+			return fmt.Errorf("%s: review not supported", c.SourceVCS.Command)
+		}
+		output, err := c.output(c.SourceDir, c.SourceVCS.Command, headArgs...)
+		if err != nil {
+			return err
+		}
+		prevRevision = strings.TrimSpace(string(output))
+	}
+
 	if err := c.run(c.SourceDir, c.SourceVCS.Command, pullArgs...); err != nil {
 		return err
 	}
 
-	if c.update.apply {
-		persistentState, err := c.getPersistentState(nil)
+	if review {
+		ts, err := c.getTargetState(nil)
+		if err == nil {
+			err = ts.Evaluate()
+		}
 		if err != nil {
+			if resetErr := c.run(c.SourceDir, c.SourceVCS.Command, vcs.ResetArgs(prevRevision)...); resetErr != nil {
+				return resetErr
+			}
 			return err
 		}
-		defer persistentState.Close()
-		if err := c.applyArgs(nil, persistentState); err != nil {
+	}
+
+	if !c.update.apply {
+		return nil
+	}
+
+	persistentState, err := c.getPersistentState(nil)
+	if err != nil {
+		return err
+	}
+	defer persistentState.Close()
+
+	if review {
+		choice, err := c.reviewUpdate(persistentState)
+		if err != nil {
 			return err
 		}
+		switch choice {
+		case 'y':
+		case 'n':
+			return nil
+		case 'r':
+			return c.run(c.SourceDir, c.SourceVCS.Command, vcs.ResetArgs(prevRevision)...)
+		}
+	}
+
+	return c.applyArgs(nil, persistentState)
+}
+
+// reviewUpdate prints the changes that applying the target state would make to
+// the destination directory, including the contents of any scripts that would
+// be run, and prompts the user to confirm them. It returns 'y' to apply the
+// changes, 'n' to leave the destination directory unchanged, or 'r' to also
+// revert the source directory to its previous revision.
+func (c *Config) reviewUpdate(persistentState chezmoi.PersistentState) (byte, error) {
+	ts, err := c.getTargetState(nil)
+	if err != nil {
+		return 0, err
+	}
+	// Scripts are not run through the mutator, so detect changes by checking
+	// whether anything was written.
+	w := &anyWriter{w: c.Stdout}
+	mutator := chezmoi.NewVerboseMutator(w, chezmoi.NullMutator{}, c.colored, c.maxDiffDataSize)
+	remove, _ := c.getRemove()
+	applyOptions := &chezmoi.ApplyOptions{
+		DestDir:           ts.DestDir,
+		DryRun:            true,
+		Ignore:            ts.Ignore,
+		Mode:              c.Mode,
+		PersistentState:   persistentState,
+		Remove:            remove,
+		ScriptStateBucket: c.scriptStateBucket,
+		SourceDir:         ts.EntrySourceDir,
+		Stdout:            w,
+		Umask:             ts.Umask,
+		Verbose:           true,
+	}
+	if err := ts.Apply(vfs.NewReadOnlyFS(c.fs), mutator, c.Follow, applyOptions); err != nil {
+		return 0, err
+	}
+	if !w.written {
+		return 'y', nil
+	}
+	return c.prompt("Apply these changes (yes, no, or no and revert the source directory)", "ynr")
+}
+
+// warnSourceVCSStatus prints warnings if the source directory contains
+// uncommitted changes or unpushed commits, which might otherwise be forgotten.
+// The status is only advisory, so failing to get it is also only a warning.
+func (c *Config) warnSourceVCSStatus(cmd *cobra.Command, vcs VCS) {
+	statusArgs := vcs.StatusArgs()
+	if statusArgs == nil {
+		return
+	}
+	output, err := c.output(c.SourceDir, c.SourceVCS.Command, statusArgs...)
+	if err != nil {
+		cmd.Printf("warning: %s: cannot get source directory status: %v\n", c.SourceDir, err)
+		return
+	}
+	status, err := vcs.ParseStatusOutput(output)
+	if err != nil {
+		cmd.Printf("warning: %s: cannot parse source directory status: %v\n", c.SourceDir, err)
+		return
+	}
+	gitStatus, ok := status.(*git.Status)
+	if !ok || gitStatus == nil {
+		return
+	}
+	if !gitStatus.Empty() {
+		cmd.Printf("warning: %s: source directory contains uncommitted changes\n", c.SourceDir)
 	}
+	if gitStatus.Branch != nil && gitStatus.Branch.Ahead > 0 {
+		cmd.Printf("warning: %s: source directory contains %d unpushed commit(s)\n", c.SourceDir, gitStatus.Branch.Ahead)
+	}
+}
 
-	return nil
+// An anyWriter wraps an io.Writer and records if anything was written.
+type anyWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (w *anyWriter) Write(p []byte) (int, error) {
+	if len(p) != 0 {
+		w.written = true
+	}
+	return w.w.Write(p)
 }
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestReviewUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		root           interface{}
+		stdin          string
+		expectedChoice byte
+		expectedOutput bool
+	}{
+		{
+			name: "unchanged",
+			root: map[string]interface{}{
+				"/home/user/.file":                         "contents",
+				"/home/user/.local/share/chezmoi/dot_file": "contents",
+			},
+			expectedChoice: 'y',
+		},
+		{
+			name: "changed_accept",
+			root: map[string]interface{}{
+				"/home/user/.file":                         "old contents",
+				"/home/user/.local/share/chezmoi/dot_file": "new contents",
+			},
+			stdin:          "y\n",
+			expectedChoice: 'y',
+			expectedOutput: true,
+		},
+		{
+			name: "changed_revert",
+			root: map[string]interface{}{
+				"/home/user/.file":                         "old contents",
+				"/home/user/.local/share/chezmoi/dot_file": "new contents",
+			},
+			stdin:          "r\n",
+			expectedChoice: 'r',
+			expectedOutput: true,
+		},
+		{
+			name: "new_script",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/run_script": "#!/bin/sh\n",
+			},
+			stdin:          "n\n",
+			expectedChoice: 'n',
+			expectedOutput: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+
+			stdout := &bytes.Buffer{}
+			c := newTestConfig(
+				fs,
+				withStdin(bytes.NewBufferString(tc.stdin)),
+				withStdout(stdout),
+			)
+			persistentState, err := c.getPersistentState(nil)
+			require.NoError(t, err)
+			defer persistentState.Close()
+
+			choice, err := c.reviewUpdate(persistentState)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedChoice, choice)
+			assert.Equal(t, tc.expectedOutput, stdout.Len() != 0)
+		})
+	}
+}
+
+func TestUpdateRevertsUnevaluableSource(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.file":                         "old contents",
+		"/home/user/.local/share/chezmoi/dot_file": "old contents",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	var resetArgv []string
+	mutator := &cmdMutator{
+		Mutator: chezmoi.NewFSMutator(fs),
+		cmdFunc: func(argv []string) ([]byte, error) {
+			switch argv[1] {
+			case "rev-parse":
+				return []byte("0123456789abcdef\n"), nil
+			case "pull":
+				// Simulate pulling a template that cannot be executed.
+				return nil, fs.WriteFile("/home/user/.local/share/chezmoi/dot_broken.tmpl", []byte(`{{ template "missing" }}`), 0644)
+			case "reset":
+				resetArgv = argv
+				return nil, fs.Remove("/home/user/.local/share/chezmoi/dot_broken.tmpl")
+			}
+			return nil, nil
+		},
+	}
+	c := newTestConfig(fs, withMutator(mutator))
+	c.update = updateCmdConfig{
+		apply:  true,
+		review: true,
+	}
+
+	assert.Error(t, c.runUpdateCmd(nil, nil))
+	assert.Equal(t, []string{"git", "reset", "--keep", "0123456789abcdef"}, resetArgv)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.file",
+			vfst.TestContentsString("old contents"),
+		),
+		vfst.TestPath("/home/user/.broken",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_broken.tmpl",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
+func TestUpdateContinuesIfStatusFails(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi/dot_file": "contents",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	var pulled bool
+	mutator := &cmdMutator{
+		Mutator: chezmoi.NewFSMutator(fs),
+		cmdFunc: func(argv []string) ([]byte, error) {
+			switch argv[1] {
+			case "status":
+				return nil, errors.New("not a git repository")
+			case "pull":
+				pulled = true
+			}
+			return nil, nil
+		},
+	}
+	c := newTestConfig(fs, withMutator(mutator))
+	c.update = updateCmdConfig{
+		apply: true,
+	}
+	cmd := &cobra.Command{}
+	output := &bytes.Buffer{}
+	cmd.SetOutput(output)
+
+	require.NoError(t, c.runUpdateCmd(cmd, nil))
+	assert.True(t, pulled)
+	assert.Contains(t, output.String(), "warning: /home/user/.local/share/chezmoi: cannot get source directory status: not a git repository")
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.file",
+			vfst.TestContentsString("contents"),
+		),
+	)
+}
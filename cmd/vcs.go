@@ -2,15 +2,23 @@ package cmd
 
 import "regexp"
 
+// A vcsCommitOptions contains options for VCS.CommitArgs.
+type vcsCommitOptions struct {
+	Sign       bool
+	SigningKey string
+}
+
 // A VCS is a version control system.
 type VCS interface {
 	AddArgs(string) []string
 	CloneArgs(string, string) []string
-	CommitArgs(string) []string
+	CommitArgs(string, *vcsCommitOptions) []string
+	HeadArgs() []string
 	InitArgs() []string
 	ParseStatusOutput([]byte) (interface{}, error)
 	PullArgs() []string
 	PushArgs() []string
+	ResetArgs(string) []string
 	StatusArgs() []string
 	VersionArgs() []string
 	VersionRegexp() *regexp.Regexp
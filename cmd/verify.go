@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"os"
-
 	"github.com/spf13/cobra"
 	"github.com/twpayne/chezmoi/internal/chezmoi"
 	bolt "go.etcd.io/bbolt"
@@ -24,6 +22,7 @@ func init() {
 }
 
 func (c *Config) runVerifyCmd(cmd *cobra.Command, args []string) error {
+	c.DryRun = true // Prevent scripts from running and file states from being recorded.
 	mutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
 	c.mutator = mutator
 
@@ -39,7 +38,7 @@ func (c *Config) runVerifyCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	if mutator.Mutated() {
-		os.Exit(1)
+		return exitCodeError(1)
 	}
 	return nil
 }
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestVerifyCmd(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		root        interface{}
+		expectedErr error
+	}{
+		{
+			name: "unchanged",
+			root: map[string]interface{}{
+				"/home/user/.file":                         "contents",
+				"/home/user/.local/share/chezmoi/dot_file": "contents",
+			},
+		},
+		{
+			name: "changed",
+			root: map[string]interface{}{
+				"/home/user/.file":                         "old contents",
+				"/home/user/.local/share/chezmoi/dot_file": "new contents",
+			},
+			expectedErr: exitCodeError(1),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+
+			c := newTestConfig(fs)
+			assert.Equal(t, tc.expectedErr, c.runVerifyCmd(nil, nil))
+		})
+	}
+}
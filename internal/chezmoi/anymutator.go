@@ -6,10 +6,12 @@ import (
 )
 
 // An AnyMutator wraps another Mutator and records if any of its mutating
-// methods are called.
+// methods are called, and the paths that they change.
 type AnyMutator struct {
 	m       Mutator
 	mutated bool
+	paths   []string
+	seen    map[string]struct{}
 }
 
 // NewAnyMutator returns a new AnyMutator.
@@ -17,12 +19,13 @@ func NewAnyMutator(m Mutator) *AnyMutator {
 	return &AnyMutator{
 		m:       m,
 		mutated: false,
+		seen:    make(map[string]struct{}),
 	}
 }
 
 // Chmod implements Mutator.Chmod.
 func (m *AnyMutator) Chmod(name string, mode os.FileMode) error {
-	m.mutated = true
+	m.mutate(name)
 	return m.m.Chmod(name, mode)
 }
 
@@ -33,7 +36,7 @@ func (m *AnyMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 
 // Mkdir implements Mutator.Mkdir.
 func (m *AnyMutator) Mkdir(name string, perm os.FileMode) error {
-	m.mutated = true
+	m.mutate(name)
 	return m.m.Mkdir(name, perm)
 }
 
@@ -42,15 +45,21 @@ func (m *AnyMutator) Mutated() bool {
 	return m.mutated
 }
 
+// MutatedPaths returns the paths changed by any of its methods, in the order
+// in which they were first changed.
+func (m *AnyMutator) MutatedPaths() []string {
+	return m.paths
+}
+
 // RemoveAll implements Mutator.RemoveAll.
 func (m *AnyMutator) RemoveAll(name string) error {
-	m.mutated = true
+	m.mutate(name)
 	return m.m.RemoveAll(name)
 }
 
 // Rename implements Mutator.Rename.
 func (m *AnyMutator) Rename(oldpath, newpath string) error {
-	m.mutated = true
+	m.mutate(oldpath, newpath)
 	return m.m.Rename(oldpath, newpath)
 }
 
@@ -67,12 +76,24 @@ func (m *AnyMutator) Stat(path string) (os.FileInfo, error) {
 
 // WriteFile implements Mutator.WriteFile.
 func (m *AnyMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
-	m.mutated = true
+	m.mutate(name)
 	return m.m.WriteFile(name, data, perm, currData)
 }
 
 // WriteSymlink implements Mutator.WriteSymlink.
 func (m *AnyMutator) WriteSymlink(oldname, newname string) error {
-	m.mutated = true
+	m.mutate(newname)
 	return m.m.WriteSymlink(oldname, newname)
 }
+
+// mutate records that paths have been changed.
+func (m *AnyMutator) mutate(paths ...string) {
+	m.mutated = true
+	for _, path := range paths {
+		if _, ok := m.seen[path]; ok {
+			continue
+		}
+		m.seen[path] = struct{}{}
+		m.paths = append(m.paths, path)
+	}
+}
@@ -0,0 +1,57 @@
+package chezmoi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An ApplyError is an error applying a single target, recorded when
+// ApplyOptions.KeepGoing is set.
+type ApplyError struct {
+	TargetName string
+	SourceName string
+	Err        error
+	Skipped    []string // Skipped contains the target names of children that were not applied.
+	DestDir    string   // DestDir is the destination directory that TargetName is relative to.
+	SourceDir  string   // SourceDir is the source directory that SourceName is relative to.
+}
+
+// ApplyErrors is a list of ApplyErrors.
+type ApplyErrors []*ApplyError
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("%s: %v", e.TargetName, e.Err)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+func (e ApplyErrors) Error() string {
+	ss := make([]string, 0, len(e))
+	for _, applyError := range e {
+		ss = append(ss, applyError.Error())
+	}
+	return strings.Join(ss, "\n")
+}
+
+// handleError returns err if KeepGoing is not set. Otherwise, it records err
+// as an error applying entry, with skipped as the target names of entry's
+// children that were not applied, and returns nil.
+func (o *ApplyOptions) handleError(entry Entry, err error, skipped []string) error {
+	if err == nil || !o.KeepGoing {
+		return err
+	}
+	o.recordError(entry.TargetName(), entry.SourceName(), err, skipped)
+	return nil
+}
+
+// recordError records err as an error applying targetName.
+func (o *ApplyOptions) recordError(targetName, sourceName string, err error, skipped []string) {
+	o.Errors = append(o.Errors, &ApplyError{
+		TargetName: targetName,
+		SourceName: sourceName,
+		Err:        err,
+		Skipped:    skipped,
+	})
+}
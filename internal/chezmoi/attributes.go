@@ -0,0 +1,331 @@
+package chezmoi
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// Attributes that can be assigned in an AttributeManifest.
+const (
+	AttributeEmpty      = "empty"
+	AttributeEncrypted  = "encrypted"
+	AttributeExact      = "exact"
+	AttributeExecutable = "executable"
+	AttributeOnce       = "once"
+	AttributePrivate    = "private"
+	AttributeReadOnly   = "readonly"
+	AttributeTemplate   = "template"
+)
+
+var validAttributes = map[string]struct{}{
+	AttributeEmpty:      {},
+	AttributeEncrypted:  {},
+	AttributeExact:      {},
+	AttributeExecutable: {},
+	AttributeOnce:       {},
+	AttributePrivate:    {},
+	AttributeReadOnly:   {},
+	AttributeTemplate:   {},
+}
+
+// An AttributeManifest assigns attributes to source paths with patterns, like
+// a .gitattributes file. Each line contains a pattern followed by attributes.
+// An attribute is set by giving its name and unset by prefixing its name with
+// a minus sign (-). Patterns that contain a slash match source paths relative
+// to the source directory, other patterns match base names. When several lines
+// match a source path, later lines take precedence.
+type AttributeManifest struct {
+	lines []string
+	rules []*attributeRule
+}
+
+// An attributeRule is a line of an AttributeManifest.
+type attributeRule struct {
+	line       int
+	pattern    string
+	attributes []attributeValue
+}
+
+// An attributeManifestError is an error on a line of an AttributeManifest.
+type attributeManifestError struct {
+	line    int // line is 1-based.
+	message string
+}
+
+// An attributeValue is an attribute that is set or unset.
+type attributeValue struct {
+	name  string
+	value bool
+}
+
+// AttributeManifestPath returns the path of the attribute manifest in
+// sourceDir.
+func AttributeManifestPath(sourceDir string) string {
+	return filepath.Join(sourceDir, attributesName)
+}
+
+func (e *attributeManifestError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.message)
+}
+
+// ParseAttributeManifest parses an AttributeManifest from data.
+func ParseAttributeManifest(data []byte) (*AttributeManifest, error) {
+	m := &AttributeManifest{}
+	if len(data) == 0 {
+		return m, nil
+	}
+	m.lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i, line := range m.lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := &attributeRule{
+			line:    i,
+			pattern: fields[0],
+		}
+		if !rule.valid() {
+			return nil, &attributeManifestError{
+				line:    i + 1,
+				message: fmt.Sprintf("%s: invalid pattern", rule.pattern),
+			}
+		}
+		for _, field := range fields[1:] {
+			av := attributeValue{
+				name:  strings.TrimPrefix(field, "-"),
+				value: !strings.HasPrefix(field, "-"),
+			}
+			if _, ok := validAttributes[av.name]; !ok {
+				return nil, &attributeManifestError{
+					line:    i + 1,
+					message: fmt.Sprintf("%s: unknown attribute", av.name),
+				}
+			}
+			rule.attributes = append(rule.attributes, av)
+		}
+		m.rules = append(m.rules, rule)
+	}
+	return m, nil
+}
+
+// ReadAttributeManifest reads the AttributeManifest in sourceDir in fs. If
+// there is no attribute manifest then an empty AttributeManifest is returned.
+func ReadAttributeManifest(fs vfs.FS, sourceDir string) (*AttributeManifest, error) {
+	path := AttributeManifestPath(sourceDir)
+	data, err := fs.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return &AttributeManifest{}, nil
+	case err != nil:
+		return nil, err
+	}
+	m, err := ParseAttributeManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Bytes returns m's contents.
+func (m *AttributeManifest) Bytes() []byte {
+	if len(m.lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(m.lines, "\n") + "\n")
+}
+
+// Get returns the attributes that m sets or unsets for sourceName.
+func (m *AttributeManifest) Get(sourceName string) map[string]bool {
+	attributes := make(map[string]bool)
+	for _, rule := range m.rules {
+		if !rule.match(sourceName) {
+			continue
+		}
+		for _, av := range rule.attributes {
+			attributes[av.name] = av.value
+		}
+	}
+	return attributes
+}
+
+// Set sets or unsets attribute for sourceName. It updates the last line whose
+// pattern is sourceName if no later line overrides it, otherwise it adds a
+// line.
+func (m *AttributeManifest) Set(sourceName, attribute string, value bool) error {
+	if _, ok := validAttributes[attribute]; !ok {
+		return fmt.Errorf("%s: unknown attribute", attribute)
+	}
+	pattern := filepath.ToSlash(sourceName)
+	var literalRule, overridingRule *attributeRule
+	for _, rule := range m.rules {
+		if rule.pattern == pattern {
+			literalRule = rule
+		}
+		if rule.match(sourceName) && rule.has(attribute) {
+			overridingRule = rule
+		}
+	}
+	rule := literalRule
+	if rule == nil || overridingRule != nil && overridingRule.line > rule.line {
+		rule = &attributeRule{
+			line:    len(m.lines),
+			pattern: pattern,
+		}
+		m.lines = append(m.lines, "")
+		m.rules = append(m.rules, rule)
+	}
+	rule.set(attribute, value)
+	m.lines[rule.line] = rule.String()
+	return nil
+}
+
+// applyBlockAttributes returns ba with the attributes that m assigns to
+// sourceName.
+func (m *AttributeManifest) applyBlockAttributes(sourceName string, ba BlockAttributes) BlockAttributes {
+	if template, ok := m.Get(sourceName)[AttributeTemplate]; ok {
+		ba.Template = template
+	}
+	return ba
+}
+
+// applyDirAttributes returns da with the attributes that m assigns to
+// sourceName.
+func (m *AttributeManifest) applyDirAttributes(sourceName string, da DirAttributes) DirAttributes {
+	attributes := m.Get(sourceName)
+	if exact, ok := attributes[AttributeExact]; ok {
+		da.Exact = exact
+	}
+	if private, ok := attributes[AttributePrivate]; ok {
+		da.Perm = 0777
+		if private {
+			da.Perm &= 0700
+		}
+	}
+	return da
+}
+
+// applyFileAttributes returns fa with the attributes that m assigns to
+// sourceName.
+func (m *AttributeManifest) applyFileAttributes(sourceName string, fa FileAttributes) FileAttributes {
+	attributes := m.Get(sourceName)
+	if template, ok := attributes[AttributeTemplate]; ok {
+		fa.Template = template
+	}
+	if fa.Mode&os.ModeType != 0 {
+		return fa
+	}
+	if empty, ok := attributes[AttributeEmpty]; ok {
+		fa.Empty = empty
+	}
+	if encrypted, ok := attributes[AttributeEncrypted]; ok {
+		fa.Encrypted = encrypted
+	}
+	executable := fa.Mode&0111 != 0
+	if value, ok := attributes[AttributeExecutable]; ok {
+		executable = value
+	}
+	private := fa.Mode&077 == 0
+	if value, ok := attributes[AttributePrivate]; ok {
+		private = value
+	}
+	readOnly := fa.Mode&0222 == 0
+	if value, ok := attributes[AttributeReadOnly]; ok {
+		readOnly = value
+	}
+	fa.Mode = 0666
+	if executable {
+		fa.Mode |= 0111
+	}
+	if private {
+		fa.Mode &= 0700
+	}
+	if readOnly {
+		fa.Mode &^= 0222
+	}
+	return fa
+}
+
+// applyMergeAttributes returns ma with the attributes that m assigns to
+// sourceName.
+func (m *AttributeManifest) applyMergeAttributes(sourceName string, ma MergeAttributes) MergeAttributes {
+	if template, ok := m.Get(sourceName)[AttributeTemplate]; ok {
+		ma.Template = template
+	}
+	return ma
+}
+
+// applyScriptAttributes returns sa with the attributes that m assigns to
+// sourceName.
+func (m *AttributeManifest) applyScriptAttributes(sourceName string, sa ScriptAttributes) ScriptAttributes {
+	attributes := m.Get(sourceName)
+	if once, ok := attributes[AttributeOnce]; ok {
+		sa.Once = once
+	}
+	if template, ok := attributes[AttributeTemplate]; ok {
+		sa.Template = template
+	}
+	return sa
+}
+
+// String returns r as a line of an AttributeManifest.
+func (r *attributeRule) String() string {
+	fields := []string{r.pattern}
+	for _, av := range r.attributes {
+		if av.value {
+			fields = append(fields, av.name)
+		} else {
+			fields = append(fields, "-"+av.name)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// has returns true if r sets or unsets attribute.
+func (r *attributeRule) has(attribute string) bool {
+	for _, av := range r.attributes {
+		if av.name == attribute {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns true if r matches sourceName. r's pattern must be valid.
+func (r *attributeRule) match(sourceName string) bool {
+	name := filepath.ToSlash(sourceName)
+	pattern := r.pattern
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		name = path.Base(name)
+	}
+	match, _ := doublestar.Match(pattern, name)
+	return match
+}
+
+// valid returns true if r's pattern is a valid pattern.
+func (r *attributeRule) valid() bool {
+	pattern := strings.TrimPrefix(r.pattern, "/")
+	_, err := doublestar.Match(pattern, pattern)
+	return err == nil
+}
+
+// set sets or unsets attribute in r.
+func (r *attributeRule) set(attribute string, value bool) {
+	for i, av := range r.attributes {
+		if av.name == attribute {
+			r.attributes[i].value = value
+			return
+		}
+	}
+	r.attributes = append(r.attributes, attributeValue{
+		name:  attribute,
+		value: value,
+	})
+}
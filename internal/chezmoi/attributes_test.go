@@ -0,0 +1,109 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestAttributeManifest(t *testing.T) {
+	m, err := ParseAttributeManifest([]byte(`# comment
+*.sh executable
+dot_ssh/** private
+dot_ssh/config -private template
+/dot_bashrc template
+`))
+	require.NoError(t, err)
+	for sourceName, expected := range map[string]map[string]bool{
+		"bin/script.sh":   {AttributeExecutable: true},
+		"dot_bashrc":      {AttributeTemplate: true},
+		"dot_ssh":         {},
+		"dot_ssh/config":  {AttributePrivate: false, AttributeTemplate: true},
+		"dot_ssh/id_rsa":  {AttributePrivate: true},
+		"foo/dot_bashrc":  {},
+		"dot_ssh/run.sh":  {AttributeExecutable: true, AttributePrivate: true},
+		"dot_vimrc":       {},
+		"script.sh.other": {},
+	} {
+		assert.Equal(t, expected, m.Get(sourceName), sourceName)
+	}
+
+	require.NoError(t, m.Set("dot_bashrc", AttributeTemplate, false))
+	require.NoError(t, m.Set("dot_ssh/id_rsa", AttributePrivate, false))
+	require.NoError(t, m.Set("dot_ssh/id_rsa", AttributeEncrypted, true))
+	assert.Error(t, m.Set("dot_ssh/id_rsa", "unknown", true))
+	assert.Equal(t, `# comment
+*.sh executable
+dot_ssh/** private
+dot_ssh/config -private template
+/dot_bashrc template
+dot_bashrc -template
+dot_ssh/id_rsa -private encrypted
+`, string(m.Bytes()))
+
+	_, err = ParseAttributeManifest([]byte("dot_bashrc unknown\n"))
+	assert.EqualError(t, err, "line 1: unknown: unknown attribute")
+
+	_, err = ParseAttributeManifest([]byte("dot_bashrc template\ndot_[ssh template\n"))
+	assert.EqualError(t, err, "line 2: dot_[ssh: invalid pattern")
+}
+
+func TestTargetStatePopulateAttributeManifest(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoiattributes": "dot_ssh exact private\n" +
+				"dot_ssh/* private\n" +
+				"dot_gitconfig template readonly\n" +
+				"private_dot_netrc -private executable\n" +
+				"run_install once\n" +
+				"symlink_dot_link template\n",
+			"dot_gitconfig":     "{{ \"# contents of .gitconfig\" }}\n",
+			"dot_ssh/config":    "# contents of .ssh/config\n",
+			"private_dot_netrc": "# contents of .netrc\n",
+			"run_install":       "#!/bin/sh\n",
+			"symlink_dot_link":  "{{ \"target\" }}",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+
+	ssh, err := ts.findEntry(".ssh")
+	require.NoError(t, err)
+	assert.True(t, ssh.(*Dir).Exact)
+	assert.True(t, ssh.(*Dir).Private())
+
+	sshConfig, err := ts.findEntry(".ssh/config")
+	require.NoError(t, err)
+	assert.True(t, sshConfig.(*File).Private())
+
+	gitconfig, err := ts.findEntry(".gitconfig")
+	require.NoError(t, err)
+	contents, err := gitconfig.(*File).Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "# contents of .gitconfig\n", string(contents))
+	assert.True(t, gitconfig.(*File).ReadOnly())
+
+	netrc, err := ts.findEntry(".netrc")
+	require.NoError(t, err)
+	assert.Equal(t, "private_dot_netrc", netrc.SourceName())
+	assert.False(t, netrc.(*File).Private())
+	assert.True(t, netrc.(*File).Executable())
+
+	install, err := ts.findEntry("install")
+	require.NoError(t, err)
+	assert.True(t, install.(*Script).Once)
+
+	link, err := ts.findEntry(".link")
+	require.NoError(t, err)
+	linkname, err := link.(*Symlink).Linkname()
+	require.NoError(t, err)
+	assert.Equal(t, "target", linkname)
+}
@@ -0,0 +1,78 @@
+package chezmoi
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// An AuditLogEntry is a single entry in an audit log.
+type AuditLogEntry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	Revision  string    `json:"revision,omitempty"`
+	Operation string    `json:"operation"`
+	Target    string    `json:"target,omitempty"`
+	NewTarget string    `json:"newTarget,omitempty"`
+	Cmd       string    `json:"cmd,omitempty"`
+	OldSHA256 string    `json:"oldSHA256,omitempty"`
+	NewSHA256 string    `json:"newSHA256,omitempty"`
+	OldMode   string    `json:"oldMode,omitempty"`
+	NewMode   string    `json:"newMode,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// An AuditLog appends AuditLogEntries to a writer as JSON lines. All methods
+// are safe for concurrent use and may be called on a nil *AuditLog, in which
+// case they do nothing.
+type AuditLog struct {
+	mutex         sync.Mutex
+	encoder       *json.Encoder
+	command       string
+	user          string
+	revisionFunc  func() string
+	revision      string
+	revisionValid bool
+}
+
+// NewAuditLog returns a new AuditLog that writes to w. command and user are
+// recorded in every entry. revisionFunc is called to get the current source
+// revision when it is first needed and again after any command is run.
+func NewAuditLog(w io.Writer, command, user string, revisionFunc func() string) *AuditLog {
+	return &AuditLog{
+		encoder:      json.NewEncoder(w),
+		command:      command,
+		user:         user,
+		revisionFunc: revisionFunc,
+	}
+}
+
+// InvalidateRevision marks the source revision as possibly changed.
+func (l *AuditLog) InvalidateRevision() {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.revisionValid = false
+}
+
+// Record records entry, setting its time, user, command, and revision.
+func (l *AuditLog) Record(entry *AuditLogEntry) error {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.revisionValid && l.revisionFunc != nil {
+		l.revision = l.revisionFunc()
+		l.revisionValid = true
+	}
+	entry.Time = time.Now().UTC()
+	entry.User = l.user
+	entry.Command = l.command
+	entry.Revision = l.revision
+	return l.encoder.Encode(entry)
+}
@@ -0,0 +1,145 @@
+package chezmoi
+
+import (
+	"os"
+	"os/exec"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// An AuditLogMutator wraps a Mutator and records all of the changes it makes
+// and all of the commands it runs in an AuditLog.
+type AuditLogMutator struct {
+	m        Mutator
+	fs       vfs.FS
+	auditLog *AuditLog
+}
+
+// NewAuditLogMutator returns a new AuditLogMutator that reads the state of
+// targets from fs and records changes in auditLog.
+func NewAuditLogMutator(m Mutator, fs vfs.FS, auditLog *AuditLog) *AuditLogMutator {
+	return &AuditLogMutator{
+		m:        m,
+		fs:       fs,
+		auditLog: auditLog,
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *AuditLogMutator) Chmod(name string, mode os.FileMode) error {
+	return m.record("chmod", name, func() error {
+		return m.m.Chmod(name, mode)
+	})
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *AuditLogMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *AuditLogMutator) Mkdir(name string, perm os.FileMode) error {
+	return m.record("mkdir", name, func() error {
+		return m.m.Mkdir(name, perm)
+	})
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *AuditLogMutator) RemoveAll(name string) error {
+	return m.record("remove", name, func() error {
+		return m.m.RemoveAll(name)
+	})
+}
+
+// Rename implements Mutator.Rename.
+func (m *AuditLogMutator) Rename(oldpath, newpath string) error {
+	entry := &AuditLogEntry{
+		Operation: "rename",
+		Target:    oldpath,
+		NewTarget: newpath,
+	}
+	entry.OldSHA256, entry.OldMode = m.state(oldpath)
+	err := m.m.Rename(oldpath, newpath)
+	entry.NewSHA256, entry.NewMode = m.state(newpath)
+	return m.recordEntry(entry, err)
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *AuditLogMutator) RunCmd(cmd *exec.Cmd) error {
+	err := m.m.RunCmd(cmd)
+	// The command might have changed the source revision.
+	m.auditLog.InvalidateRevision()
+	return m.recordEntry(&AuditLogEntry{
+		Operation: "run",
+		Cmd:       cmdString(cmd),
+	}, err)
+}
+
+// Stat implements Mutator.Stat.
+func (m *AuditLogMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *AuditLogMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	return m.record("write", name, func() error {
+		return m.m.WriteFile(name, data, perm, currData)
+	})
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *AuditLogMutator) WriteSymlink(oldname, newname string) error {
+	return m.record("symlink", newname, func() error {
+		return m.m.WriteSymlink(oldname, newname)
+	})
+}
+
+// record calls f, which changes name, and records operation with the states of
+// name before and after.
+func (m *AuditLogMutator) record(operation, name string, f func() error) error {
+	entry := &AuditLogEntry{
+		Operation: operation,
+		Target:    name,
+	}
+	entry.OldSHA256, entry.OldMode = m.state(name)
+	err := f()
+	entry.NewSHA256, entry.NewMode = m.state(name)
+	return m.recordEntry(entry, err)
+}
+
+// recordEntry records entry with err, the error returned by the operation, and
+// returns err or any error recording entry.
+func (m *AuditLogMutator) recordEntry(entry *AuditLogEntry, err error) error {
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if recordErr := m.auditLog.Record(entry); err == nil {
+		err = recordErr
+	}
+	return err
+}
+
+// state returns the SHA256 of the contents of name, or of its target if it is
+// a symlink, and its mode. If name does not exist then both are empty.
+func (m *AuditLogMutator) state(name string) (string, string) {
+	info, err := m.fs.Lstat(name)
+	if err != nil {
+		return "", ""
+	}
+	switch {
+	case info.Mode().IsRegular():
+		data, err := m.fs.ReadFile(name)
+		if err != nil {
+			return "", info.Mode().String()
+		}
+		return sha256Sum(data), info.Mode().String()
+	case info.Mode()&os.ModeType == os.ModeSymlink:
+		linkname, err := m.fs.Readlink(name)
+		if err != nil {
+			return "", info.Mode().String()
+		}
+		return sha256Sum([]byte(linkname)), info.Mode().String()
+	default:
+		return "", info.Mode().String()
+	}
+}
@@ -0,0 +1,75 @@
+package chezmoi
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+var _ Mutator = &AuditLogMutator{}
+
+func TestAuditLogMutator(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.bashrc": "# old contents of .bashrc\n",
+		"/home/user/.old":    "# contents of .old\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	b := &bytes.Buffer{}
+	revisions := 0
+	auditLog := NewAuditLog(b, "chezmoi apply", "user", func() string {
+		revisions++
+		return "abcdef"
+	})
+	m := NewAuditLogMutator(NewFSMutator(fs), fs, auditLog)
+	require.NoError(t, m.WriteFile("/home/user/.bashrc", []byte("# new contents of .bashrc\n"), 0644, nil))
+	require.NoError(t, m.Mkdir("/home/user/.dir", 0755))
+	require.NoError(t, m.RemoveAll("/home/user/.old"))
+
+	var entries []AuditLogEntry
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		var entry AuditLogEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.False(t, entry.Time.IsZero())
+		entry.Time = time.Time{}
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, []AuditLogEntry{
+		{
+			User:      "user",
+			Command:   "chezmoi apply",
+			Revision:  "abcdef",
+			Operation: "write",
+			Target:    "/home/user/.bashrc",
+			OldSHA256: sha256Sum([]byte("# old contents of .bashrc\n")),
+			NewSHA256: sha256Sum([]byte("# new contents of .bashrc\n")),
+			OldMode:   "-rw-r--r--",
+			NewMode:   "-rw-r--r--",
+		},
+		{
+			User:      "user",
+			Command:   "chezmoi apply",
+			Revision:  "abcdef",
+			Operation: "mkdir",
+			Target:    "/home/user/.dir",
+			NewMode:   "drwxr-xr-x",
+		},
+		{
+			User:      "user",
+			Command:   "chezmoi apply",
+			Revision:  "abcdef",
+			Operation: "remove",
+			Target:    "/home/user/.old",
+			OldSHA256: sha256Sum([]byte("# contents of .old\n")),
+			OldMode:   "-rw-r--r--",
+		},
+	}, entries)
+	assert.Equal(t, 1, revisions)
+}
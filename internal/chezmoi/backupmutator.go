@@ -0,0 +1,168 @@
+package chezmoi
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// BackupTimeFormat is the format of the names of backup directories.
+const BackupTimeFormat = "20060102T150405Z"
+
+// A BackupMutator wraps a Mutator and copies any existing target into a backup
+// directory before it is overwritten or removed.
+type BackupMutator struct {
+	m         Mutator
+	fs        vfs.FS
+	destDir   string
+	backupDir string
+	exclude   []string
+}
+
+// NewBackupMutator returns a new BackupMutator that backs up targets in
+// destDir to backupDir, preserving their paths relative to destDir. Paths in
+// backupDir and in any of exclude are never backed up.
+func NewBackupMutator(m Mutator, fs vfs.FS, destDir, backupDir string, exclude ...string) *BackupMutator {
+	return &BackupMutator{
+		m:         m,
+		fs:        fs,
+		destDir:   destDir,
+		backupDir: backupDir,
+		exclude:   append([]string{backupDir}, exclude...),
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *BackupMutator) Chmod(name string, mode os.FileMode) error {
+	return m.m.Chmod(name, mode)
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *BackupMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *BackupMutator) Mkdir(name string, perm os.FileMode) error {
+	return m.m.Mkdir(name, perm)
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *BackupMutator) RemoveAll(name string) error {
+	if err := m.backup(name); err != nil {
+		return err
+	}
+	return m.m.RemoveAll(name)
+}
+
+// Rename implements Mutator.Rename.
+func (m *BackupMutator) Rename(oldpath, newpath string) error {
+	return m.m.Rename(oldpath, newpath)
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *BackupMutator) RunCmd(cmd *exec.Cmd) error {
+	return m.m.RunCmd(cmd)
+}
+
+// Stat implements Mutator.Stat.
+func (m *BackupMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *BackupMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	if err := m.backup(name); err != nil {
+		return err
+	}
+	return m.m.WriteFile(name, data, perm, currData)
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *BackupMutator) WriteSymlink(oldname, newname string) error {
+	if err := m.backup(newname); err != nil {
+		return err
+	}
+	return m.m.WriteSymlink(oldname, newname)
+}
+
+// backup copies name to the backup directory, if it exists and has not
+// already been backed up.
+func (m *BackupMutator) backup(name string) error {
+	if !isInDir(name, m.destDir) {
+		return nil
+	}
+	if m.excluded(name) {
+		return nil
+	}
+	if _, err := m.fs.Lstat(name); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	backupPath := filepath.Join(m.backupDir, strings.TrimPrefix(name, m.destDir+string(filepath.Separator)))
+	// Only keep the first backup of each target, which is the version that
+	// existed before chezmoi was run.
+	if _, err := m.fs.Lstat(backupPath); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := vfs.MkdirAll(m.fs, filepath.Dir(backupPath), 0700); err != nil {
+		return err
+	}
+	return m.copyTree(name, backupPath)
+}
+
+// copyTree copies src and, if it is a directory, all of its contents except
+// the excluded paths, to dst.
+func (m *BackupMutator) copyTree(src, dst string) error {
+	return vfs.Walk(m.fs, src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if m.excluded(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		dstPath := filepath.Join(dst, strings.TrimPrefix(path, src))
+		switch {
+		case info.IsDir():
+			return m.fs.Mkdir(dstPath, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			data, err := m.fs.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return m.fs.WriteFile(dstPath, data, info.Mode().Perm())
+		case info.Mode()&os.ModeType == os.ModeSymlink:
+			linkname, err := m.fs.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return m.fs.Symlink(linkname, dstPath)
+		default:
+			return nil
+		}
+	})
+}
+
+// excluded returns true if path is, or is inside, any of m's excluded paths.
+func (m *BackupMutator) excluded(path string) bool {
+	for _, dir := range m.exclude {
+		if path == dir || isInDir(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isInDir returns true if path is inside dir.
+func isInDir(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,108 @@
+package chezmoi
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+var _ Mutator = &BackupMutator{}
+
+func TestBackupMutator(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": "# old contents of .bashrc\n",
+			".dir": map[string]interface{}{
+				"file": &vfst.File{
+					Perm:     0600,
+					Contents: []byte("# contents of .dir/file\n"),
+				},
+				"symlink": &vfst.Symlink{Target: "file"},
+			},
+			".local/share/chezmoi/dot_bashrc": "# contents of .bashrc\n",
+		},
+		"/etc/hosts": "# contents of /etc/hosts\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	backupDir := "/home/user/.local/state/chezmoi/backups/20200102T030405Z"
+	m := NewBackupMutator(NewFSMutator(fs), fs, "/home/user", backupDir, "/home/user/.local/share/chezmoi")
+
+	require.NoError(t, m.WriteFile("/home/user/.bashrc", []byte("# new contents of .bashrc\n"), 0644, nil))
+	require.NoError(t, m.WriteFile("/home/user/.bashrc", []byte("# newer contents of .bashrc\n"), 0644, nil))
+	require.NoError(t, m.RemoveAll("/home/user/.dir"))
+	require.NoError(t, m.WriteFile("/home/user/.new", []byte("# contents of .new\n"), 0644, nil))
+	require.NoError(t, m.WriteFile("/home/user/.local/share/chezmoi/dot_bashrc", []byte("# new contents of .bashrc\n"), 0644, nil))
+	require.NoError(t, m.WriteFile("/etc/hosts", []byte("# new contents of /etc/hosts\n"), 0644, nil))
+
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# newer contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.dir",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath(backupDir,
+			vfst.TestIsDir,
+			vfst.TestModePerm(0700),
+		),
+		vfst.TestPath(backupDir+"/.bashrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# old contents of .bashrc\n"),
+		),
+		vfst.TestPath(backupDir+"/.dir/file",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0600),
+			vfst.TestContentsString("# contents of .dir/file\n"),
+		),
+		vfst.TestPath(backupDir+"/.dir/symlink",
+			vfst.TestModeType(os.ModeSymlink),
+			vfst.TestSymlinkTarget("file"),
+		),
+		vfst.TestPath(backupDir+"/.new",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath(backupDir+"/.local",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
+func TestBackupMutatorRemoveAncestor(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local": map[string]interface{}{
+			"bin/tool":                                       "# contents of .local/bin/tool\n",
+			"share/chezmoi/dot_bashrc":                       "# contents of .bashrc\n",
+			"share/chezmoi-team/dot_bashrc":                  "# team contents of .bashrc\n",
+			"state/chezmoi/backups/20200101T000000Z/.bashrc": "# earlier backup of .bashrc\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	backupsDir := "/home/user/.local/state/chezmoi/backups"
+	backupDir := backupsDir + "/20200102T030405Z"
+	// Removing .local would also remove the backups, so only check what is
+	// backed up.
+	m := NewBackupMutator(NullMutator{}, fs, "/home/user", backupDir, backupsDir, "/home/user/.local/share/chezmoi", "/home/user/.local/share/chezmoi-team")
+
+	require.NoError(t, m.RemoveAll("/home/user/.local"))
+
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath(backupDir+"/.local/bin/tool",
+			vfst.TestContentsString("# contents of .local/bin/tool\n"),
+		),
+		vfst.TestPath(backupDir+"/.local/share/chezmoi",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath(backupDir+"/.local/share/chezmoi-team",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath(backupDir+"/.local/state/chezmoi/backups",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
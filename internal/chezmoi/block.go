@@ -0,0 +1,293 @@
+package chezmoi
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// A BlockAttributes holds attributes parsed from a source block name.
+type BlockAttributes struct {
+	Name     string
+	Template bool
+}
+
+// A Block represents the target state of a block of lines in a file that is
+// otherwise not managed by chezmoi. The block is delimited by marker lines
+// containing its target name, so each file contains at most one block.
+type Block struct {
+	sourceName       string
+	targetName       string
+	Template         bool
+	contentsMutex    sync.Mutex
+	contents         []byte
+	contentsErr      error
+	evaluateContents func() ([]byte, error)
+}
+
+type blockConcreteValue struct {
+	Type       string `json:"type" yaml:"type"`
+	SourcePath string `json:"sourcePath" yaml:"sourcePath"`
+	TargetPath string `json:"targetPath" yaml:"targetPath"`
+	Template   bool   `json:"template" yaml:"template"`
+	Contents   string `json:"contents" yaml:"contents"`
+}
+
+// ParseBlockAttributes parses a source block name.
+func ParseBlockAttributes(sourceName string) BlockAttributes {
+	name := strings.TrimPrefix(sourceName, blockPrefix)
+	template := false
+	if strings.HasPrefix(name, dotPrefix) {
+		name = "." + strings.TrimPrefix(name, dotPrefix)
+	}
+	if strings.HasSuffix(name, TemplateSuffix) {
+		name = strings.TrimSuffix(name, TemplateSuffix)
+		template = true
+	}
+	return BlockAttributes{
+		Name:     name,
+		Template: template,
+	}
+}
+
+// SourceName returns ba's source name.
+func (ba BlockAttributes) SourceName() string {
+	sourceName := blockPrefix
+	if strings.HasPrefix(ba.Name, ".") {
+		sourceName += dotPrefix + strings.TrimPrefix(ba.Name, ".")
+	} else {
+		sourceName += ba.Name
+	}
+	if ba.Template {
+		sourceName += TemplateSuffix
+	}
+	return sourceName
+}
+
+// BlockMarkers returns the lines that begin and end the block for targetName.
+func BlockMarkers(targetName string) (string, string) {
+	id := filepath.ToSlash(targetName)
+	return "# BEGIN chezmoi " + id, "# END chezmoi " + id
+}
+
+// FindBlock returns the contents of the block for targetName in data and
+// whether it was found. It returns an error if the block's markers are
+// malformed.
+func FindBlock(data []byte, targetName string) ([]byte, bool, error) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	begin, end, err := findBlockLines(lines, targetName)
+	if err != nil {
+		return nil, false, err
+	}
+	if end == -1 {
+		return nil, false, nil
+	}
+	return bytes.Join(lines[begin+1:end], nil), true, nil
+}
+
+// ReplaceBlock returns data with the block for targetName replaced by contents.
+// If data does not contain the block then it is appended. If contents is nil
+// then the block is removed. It returns an error, and leaves data unchanged, if
+// the block's markers in data are malformed.
+func ReplaceBlock(data []byte, targetName string, contents []byte) ([]byte, error) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	begin, end, err := findBlockLines(lines, targetName)
+	if err != nil {
+		return nil, err
+	}
+	var block []byte
+	if contents != nil {
+		beginMarker, endMarker := BlockMarkers(targetName)
+		block = append(block, beginMarker+"\n"...)
+		block = append(block, contents...)
+		if len(contents) != 0 && !bytes.HasSuffix(contents, []byte("\n")) {
+			block = append(block, '\n')
+		}
+		block = append(block, endMarker+"\n"...)
+	}
+	if end == -1 {
+		if block == nil {
+			return data, nil
+		}
+		result := append([]byte{}, data...)
+		if len(result) != 0 && !bytes.HasSuffix(result, []byte("\n")) {
+			result = append(result, '\n')
+		}
+		return append(result, block...), nil
+	}
+	result := bytes.Join(lines[:begin], nil)
+	result = append(result, block...)
+	return append(result, bytes.Join(lines[end+1:], nil)...), nil
+}
+
+// AppendAllEntries appends b to allEntries.
+func (b *Block) AppendAllEntries(allEntries []Entry) []Entry {
+	return append(allEntries, b)
+}
+
+// Apply ensures that the block in targetPath in fs matches b, leaving the rest
+// of targetPath unchanged.
+func (b *Block) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
+	if applyOptions.Ignore(b.targetName) {
+		return nil
+	}
+	contents, err := b.Contents()
+	if err != nil {
+		return err
+	}
+	if isEmpty(contents) {
+		contents = nil
+	}
+	return applyBlock(fs, mutator, follow, applyOptions, b.targetName, contents)
+}
+
+// ConcreteValue implements Entry.ConcreteValue.
+func (b *Block) ConcreteValue(ignore func(string) bool, sourceDir func(string) string, umask os.FileMode, recursive bool) (interface{}, error) {
+	if ignore(b.targetName) {
+		return nil, nil
+	}
+	contents, err := b.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return &blockConcreteValue{
+		Type:       "block",
+		SourcePath: filepath.Join(sourceDir(b.targetName), b.SourceName()),
+		TargetPath: b.TargetName(),
+		Template:   b.Template,
+		Contents:   string(contents),
+	}, nil
+}
+
+// Contents returns b's contents. It is safe to call Contents concurrently.
+func (b *Block) Contents() ([]byte, error) {
+	b.contentsMutex.Lock()
+	defer b.contentsMutex.Unlock()
+	if b.evaluateContents != nil {
+		b.contents, b.contentsErr = b.evaluateContents()
+		b.evaluateContents = nil
+	}
+	return b.contents, b.contentsErr
+}
+
+// Evaluate evaluates b's contents.
+func (b *Block) Evaluate(ignore func(string) bool) error {
+	if ignore(b.targetName) {
+		return nil
+	}
+	_, err := b.Contents()
+	return err
+}
+
+// SourceName implements Entry.SourceName.
+func (b *Block) SourceName() string {
+	return b.sourceName
+}
+
+// TargetName implements Entry.TargetName.
+func (b *Block) TargetName() string {
+	return b.targetName
+}
+
+// archive writes b to w as a file that contains only the block, as b would be
+// applied to a destination directory that does not contain its target.
+func (b *Block) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
+	if ignore(b.targetName) {
+		return nil
+	}
+	contents, err := b.Contents()
+	if err != nil {
+		return err
+	}
+	if isEmpty(contents) {
+		return nil
+	}
+	data, err := ReplaceBlock(nil, b.targetName, contents)
+	if err != nil {
+		return err
+	}
+	header := *headerTemplate
+	header.Typeflag = tar.TypeReg
+	header.Name = b.targetName
+	header.Size = int64(len(data))
+	header.Mode = int64(0666 &^ umask)
+	if err := w.WriteHeader(&header); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// applyBlock ensures that the block for targetName in applyOptions.DestDir in
+// fs contains contents, or is removed if contents is nil.
+func applyBlock(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions, targetName string, contents []byte) error {
+	targetPath := filepath.Join(applyOptions.DestDir, targetName)
+	stat := fs.Lstat
+	if follow {
+		stat = fs.Stat
+	}
+	switch info, err := stat(targetPath); {
+	case err == nil && info.Mode().IsRegular():
+		currData, err := fs.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		newData, err := ReplaceBlock(currData, targetName, contents)
+		if err != nil {
+			return fmt.Errorf("%s: %w", targetPath, err)
+		}
+		if bytes.Equal(newData, currData) {
+			return nil
+		}
+		return mutator.WriteFile(targetPath, newData, info.Mode().Perm(), currData)
+	case err == nil:
+		return fmt.Errorf("%s: not a regular file", targetPath)
+	case os.IsNotExist(err):
+		if contents == nil {
+			return nil
+		}
+	default:
+		return err
+	}
+	newData, err := ReplaceBlock(nil, targetName, contents)
+	if err != nil {
+		return err
+	}
+	return mutator.WriteFile(targetPath, newData, 0666&^applyOptions.Umask, nil)
+}
+
+// findBlockLines returns the indexes of the lines that begin and end the block
+// for targetName in lines, or -1 if the block is not found. It returns an error
+// if a marker is duplicated, the end marker comes before the begin marker, or
+// the begin marker is not followed by an end marker.
+func findBlockLines(lines [][]byte, targetName string) (int, int, error) {
+	beginMarker, endMarker := BlockMarkers(targetName)
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch string(bytes.TrimRight(line, "\r\n")) {
+		case beginMarker:
+			if begin != -1 {
+				return -1, -1, fmt.Errorf("line %d: duplicate %q", i+1, beginMarker)
+			}
+			begin = i
+		case endMarker:
+			switch {
+			case end != -1:
+				return -1, -1, fmt.Errorf("line %d: duplicate %q", i+1, endMarker)
+			case begin == -1:
+				return -1, -1, fmt.Errorf("line %d: %q before %q", i+1, endMarker, beginMarker)
+			}
+			end = i
+		}
+	}
+	if begin != -1 && end == -1 {
+		return -1, -1, fmt.Errorf("line %d: %q without %q", begin+1, beginMarker, endMarker)
+	}
+	return begin, end, nil
+}
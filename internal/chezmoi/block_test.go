@@ -0,0 +1,201 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestBlockAttributes(t *testing.T) {
+	for sourceName, ba := range map[string]BlockAttributes{
+		"block_foo":             {Name: "foo"},
+		"block_dot_bashrc":      {Name: ".bashrc"},
+		"block_dot_bashrc.tmpl": {Name: ".bashrc", Template: true},
+	} {
+		assert.Equal(t, ba, ParseBlockAttributes(sourceName))
+		assert.Equal(t, sourceName, ba.SourceName())
+	}
+}
+
+func TestReplaceBlock(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		contents []byte
+		expected string
+	}{
+		{
+			name:     "empty",
+			contents: []byte("bar\n"),
+			expected: "# BEGIN chezmoi .foo\nbar\n# END chezmoi .foo\n",
+		},
+		{
+			name:     "append",
+			data:     "foo",
+			contents: []byte("bar"),
+			expected: "foo\n# BEGIN chezmoi .foo\nbar\n# END chezmoi .foo\n",
+		},
+		{
+			name:     "replace",
+			data:     "before\n# BEGIN chezmoi .foo\nold\n# END chezmoi .foo\nafter\n",
+			contents: []byte("new\n"),
+			expected: "before\n# BEGIN chezmoi .foo\nnew\n# END chezmoi .foo\nafter\n",
+		},
+		{
+			name:     "replace_crlf",
+			data:     "before\r\n# BEGIN chezmoi .foo\r\nold\r\n# END chezmoi .foo\r\nafter\r\n",
+			contents: []byte("new\n"),
+			expected: "before\r\n# BEGIN chezmoi .foo\nnew\n# END chezmoi .foo\nafter\r\n",
+		},
+		{
+			name:     "remove",
+			data:     "before\n# BEGIN chezmoi .foo\nold\n# END chezmoi .foo\nafter\n",
+			expected: "before\nafter\n",
+		},
+		{
+			name:     "remove_missing",
+			data:     "before\n# BEGIN chezmoi .bar\nold\n# END chezmoi .bar\n",
+			expected: "before\n# BEGIN chezmoi .bar\nold\n# END chezmoi .bar\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ReplaceBlock([]byte(tc.data), ".foo", tc.contents)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(actual))
+		})
+	}
+
+	for _, tc := range []struct {
+		name        string
+		data        string
+		expectedErr string
+	}{
+		{
+			name:        "unterminated",
+			data:        "# BEGIN chezmoi .foo\nold\n",
+			expectedErr: `line 1: "# BEGIN chezmoi .foo" without "# END chezmoi .foo"`,
+		},
+		{
+			name:        "duplicate_begin",
+			data:        "# BEGIN chezmoi .foo\nold\n# BEGIN chezmoi .foo\n# END chezmoi .foo\n",
+			expectedErr: `line 3: duplicate "# BEGIN chezmoi .foo"`,
+		},
+		{
+			name:        "duplicate_end",
+			data:        "# BEGIN chezmoi .foo\nold\n# END chezmoi .foo\n# END chezmoi .foo\n",
+			expectedErr: `line 4: duplicate "# END chezmoi .foo"`,
+		},
+		{
+			name:        "out_of_order",
+			data:        "# END chezmoi .foo\nold\n# BEGIN chezmoi .foo\n",
+			expectedErr: `line 1: "# END chezmoi .foo" before "# BEGIN chezmoi .foo"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, contents := range [][]byte{[]byte("new\n"), nil} {
+				_, err := ReplaceBlock([]byte(tc.data), ".foo", contents)
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+			_, _, err := FindBlock([]byte(tc.data), ".foo")
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+
+	contents, ok, err := FindBlock([]byte("before\n# BEGIN chezmoi .foo\nfoo\nbar\n# END chezmoi .foo\n"), ".foo")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "foo\nbar\n", string(contents))
+	_, ok, err = FindBlock([]byte("before\n"), ".foo")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestTargetStateApplyBlocks(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": &vfst.File{
+				Perm:     0600,
+				Contents: []byte("# local contents of .bashrc\n"),
+			},
+			".config/chezmoi": &vfst.Dir{Perm: 0755},
+			".local/share/chezmoi": map[string]interface{}{
+				"block_dot_bashrc":          "# managed contents of .bashrc\n",
+				"dot_ssh/block_config.tmpl": "Host {{ \"example.com\" }}\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	persistentState, err := NewBoltPersistentState(fs, "/home/user/.config/chezmoi/chezmoistate.boltdb", vfst.DefaultUmask, nil)
+	require.NoError(t, err)
+	defer persistentState.Close()
+
+	apply := func() {
+		ts := NewTargetState(
+			WithDestDir("/home/user"),
+			WithSourceDir("/home/user/.local/share/chezmoi"),
+		)
+		require.NoError(t, ts.Populate(fs, nil))
+		require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+			DestDir:             ts.DestDir,
+			Ignore:              ts.TargetIgnore.Match,
+			ManagedBlocksBucket: []byte("managedBlocks"),
+			PersistentState:     persistentState,
+			Umask:               022,
+		}))
+	}
+
+	apply()
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestModePerm(0600),
+			vfst.TestContentsString("# local contents of .bashrc\n# BEGIN chezmoi .bashrc\n# managed contents of .bashrc\n# END chezmoi .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.ssh/config",
+			vfst.TestModePerm(0644),
+			vfst.TestContentsString("# BEGIN chezmoi .ssh/config\nHost example.com\n# END chezmoi .ssh/config\n"),
+		),
+	)
+
+	require.NoError(t, fs.WriteFile("/home/user/.bashrc", []byte("# BEGIN chezmoi .bashrc\n# old managed contents\n# END chezmoi .bashrc\n# more local contents\n"), 0600))
+	require.NoError(t, fs.RemoveAll("/home/user/.local/share/chezmoi/dot_ssh/block_config.tmpl"))
+	apply()
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# BEGIN chezmoi .bashrc\n# managed contents of .bashrc\n# END chezmoi .bashrc\n# more local contents\n"),
+		),
+		vfst.TestPath("/home/user/.ssh/config",
+			vfst.TestContentsString(""),
+		),
+	)
+}
+
+func TestTargetStateApplyBlockMalformed(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc":                               "# BEGIN chezmoi .bashrc\n# local contents of .bashrc\n",
+			".local/share/chezmoi/block_dot_bashrc": "# managed contents of .bashrc\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	assert.EqualError(t, ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.TargetIgnore.Match,
+		Umask:   022,
+	}), `/home/user/.bashrc: line 1: "# BEGIN chezmoi .bashrc" without "# END chezmoi .bashrc"`)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# BEGIN chezmoi .bashrc\n# local contents of .bashrc\n"),
+		),
+	)
+}
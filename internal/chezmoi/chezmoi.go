@@ -14,18 +14,30 @@ import (
 
 // Suffixes and prefixes.
 const (
+	blockPrefix      = "block_"
 	dotPrefix        = "dot_"
 	emptyPrefix      = "empty_"
 	encryptedPrefix  = "encrypted_"
 	exactPrefix      = "exact_"
 	executablePrefix = "executable_"
+	mergePrefix      = "merge_"
 	oncePrefix       = "once_"
 	privatePrefix    = "private_"
+	readOnlyPrefix   = "readonly_"
 	runPrefix        = "run_"
 	symlinkPrefix    = "symlink_"
 	TemplateSuffix   = ".tmpl"
 )
 
+// A Mode is a way of applying files.
+type Mode string
+
+// Modes.
+const (
+	ModeFile    Mode = "file"
+	ModeSymlink Mode = "symlink"
+)
+
 // A PersistentState is an interface to a persistent state.
 type PersistentState interface {
 	Close() error
@@ -36,22 +48,34 @@ type PersistentState interface {
 
 // An ApplyOptions is a big ball of mud for things that affect Entry.Apply.
 type ApplyOptions struct {
-	DestDir           string
-	DryRun            bool
-	Ignore            func(string) bool
-	PersistentState   PersistentState
-	Remove            bool
-	ScriptStateBucket []byte
-	Stdout            io.Writer
-	Umask             os.FileMode
-	Verbose           bool
+	AuditLog             *AuditLog
+	ConfirmRemove        func(targetPaths []string) (bool, error)
+	DestDir              string
+	DryRun               bool
+	Errors               ApplyErrors
+	FileStateBucket      []byte
+	Ignore               func(string) bool
+	KeepGoing            bool
+	ManagedBlocksBucket  []byte
+	ManagedTargetsBucket []byte
+	MaxRemove            int
+	Mode                 Mode
+	PersistentState      PersistentState
+	Remove               bool
+	ScriptStateBucket    []byte
+	SourceDir            func(string) string
+	Stdout               io.Writer
+	Strict               bool
+	Timings              *Timings
+	Umask                os.FileMode
+	Verbose              bool
 }
 
 // An Entry is either a Dir, a File, or a Symlink.
 type Entry interface {
 	AppendAllEntries(allEntries []Entry) []Entry
 	Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error
-	ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error)
+	ConcreteValue(ignore func(string) bool, sourceDir func(string) string, umask os.FileMode, recursive bool) (interface{}, error)
 	Evaluate(ignore func(string) bool) error
 	SourceName() string
 	TargetName() string
@@ -60,7 +84,9 @@ type Entry interface {
 
 type parsedSourceFilePath struct {
 	dirAttributes    []DirAttributes
+	blockAttributes  *BlockAttributes
 	fileAttributes   *FileAttributes
+	mergeAttributes  *MergeAttributes
 	scriptAttributes *ScriptAttributes
 }
 
@@ -78,6 +104,19 @@ func isEmpty(b []byte) bool {
 	return len(bytes.TrimSpace(b)) == 0
 }
 
+// isSymlinkTo returns true if the symlink name in fs points to path.
+func isSymlinkTo(fs vfs.FS, name, path string) (bool, error) {
+	linkname, err := fs.Readlink(name)
+	if err != nil {
+		return false, err
+	}
+	rawPath, err := fs.RawPath(path)
+	if err != nil {
+		return false, err
+	}
+	return linkname == path || linkname == rawPath, nil
+}
+
 // parseDirNameComponents parses multiple directory name components.
 func parseDirNameComponents(components []string) []DirAttributes {
 	das := []DirAttributes{}
@@ -100,6 +139,20 @@ func parseSourceFilePath(path string) parsedSourceFilePath {
 			scriptAttributes: &sa,
 		}
 	}
+	if strings.HasPrefix(sourceName, blockPrefix) {
+		ba := ParseBlockAttributes(sourceName)
+		return parsedSourceFilePath{
+			dirAttributes:   das,
+			blockAttributes: &ba,
+		}
+	}
+	if strings.HasPrefix(sourceName, mergePrefix) {
+		ma := ParseMergeAttributes(sourceName)
+		return parsedSourceFilePath{
+			dirAttributes:   das,
+			mergeAttributes: &ma,
+		}
+	}
 	fa := ParseFileAttributes(components[len(components)-1])
 	return parsedSourceFilePath{
 		dirAttributes:  das,
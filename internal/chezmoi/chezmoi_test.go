@@ -26,7 +26,8 @@ func TestReturnTemplateError(t *testing.T) {
 				WithTemplateFuncs(funcs),
 			)
 			_, err := ts.ExecuteTemplateData(name, []byte(dataString))
-			assert.Error(t, err)
+			var te *TemplateError
+			assert.True(t, errors.As(err, &te))
 		})
 	}
 }
@@ -100,6 +100,39 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 		return nil
 	}
 	targetPath := filepath.Join(applyOptions.DestDir, d.targetName)
+	if err := d.applyDir(fs, mutator, follow, applyOptions, targetPath); err != nil {
+		// The children of a directory that cannot be created are skipped.
+		return applyOptions.handleError(d, err, d.childTargetNames(applyOptions.Ignore))
+	}
+	for _, entryName := range sortedEntryNames(d.Entries) {
+		entry := d.Entries[entryName]
+		if err := applyOptions.handleError(entry, entry.Apply(fs, mutator, follow, applyOptions), nil); err != nil {
+			return err
+		}
+	}
+	if d.Exact {
+		infos, err := fs.ReadDir(targetPath)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if _, ok := d.Entries[name]; !ok {
+				if applyOptions.Ignore(filepath.Join(d.targetName, name)) {
+					continue
+				}
+				if err := mutator.RemoveAll(filepath.Join(targetPath, name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyDir ensures that targetPath is a directory with the correct
+// permissions.
+func (d *Dir) applyDir(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions, targetPath string) error {
 	var info os.FileInfo
 	var err error
 	if follow {
@@ -110,49 +143,40 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 	switch {
 	case err == nil && info.IsDir():
 		if info.Mode().Perm() != d.Perm&^applyOptions.Umask {
-			if err := mutator.Chmod(targetPath, d.Perm&^applyOptions.Umask); err != nil {
-				return err
-			}
+			return mutator.Chmod(targetPath, d.Perm&^applyOptions.Umask)
 		}
+		return nil
 	case err == nil:
 		if err := mutator.RemoveAll(targetPath); err != nil {
 			return err
 		}
 		fallthrough
 	case os.IsNotExist(err):
-		if err := mutator.Mkdir(targetPath, d.Perm&^applyOptions.Umask); err != nil {
-			return err
-		}
+		return mutator.Mkdir(targetPath, d.Perm&^applyOptions.Umask)
 	default:
 		return err
 	}
+}
+
+// childTargetNames returns the target names of all of d's descendants that
+// are not ignored.
+func (d *Dir) childTargetNames(ignore func(string) bool) []string {
+	var targetNames []string
 	for _, entryName := range sortedEntryNames(d.Entries) {
-		if err := d.Entries[entryName].Apply(fs, mutator, follow, applyOptions); err != nil {
-			return err
-		}
-	}
-	if d.Exact {
-		infos, err := fs.ReadDir(targetPath)
-		if err != nil {
-			return err
+		entry := d.Entries[entryName]
+		if ignore(entry.TargetName()) {
+			continue
 		}
-		for _, info := range infos {
-			name := info.Name()
-			if _, ok := d.Entries[name]; !ok {
-				if applyOptions.Ignore(filepath.Join(d.targetName, name)) {
-					continue
-				}
-				if err := mutator.RemoveAll(filepath.Join(targetPath, name)); err != nil {
-					return err
-				}
-			}
+		targetNames = append(targetNames, entry.TargetName())
+		if dir, ok := entry.(*Dir); ok {
+			targetNames = append(targetNames, dir.childTargetNames(ignore)...)
 		}
 	}
-	return nil
+	return targetNames
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (d *Dir) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (d *Dir) ConcreteValue(ignore func(string) bool, sourceDir func(string) string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(d.targetName) {
 		return nil, nil
 	}
@@ -170,7 +194,7 @@ func (d *Dir) ConcreteValue(ignore func(string) bool, sourceDir string, umask os
 	}
 	return &dirConcreteValue{
 		Type:       "dir",
-		SourcePath: filepath.Join(sourceDir, d.SourceName()),
+		SourcePath: filepath.Join(sourceDir(d.targetName), d.SourceName()),
 		TargetPath: d.TargetName(),
 		Exact:      d.Exact,
 		Perm:       int(d.Perm &^ umask),
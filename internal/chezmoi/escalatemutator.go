@@ -0,0 +1,138 @@
+package chezmoi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// An EscalateMutator wraps a Mutator and makes changes to paths that require
+// elevated privileges by running commands through a privilege escalation
+// command like sudo or doas.
+type EscalateMutator struct {
+	m        Mutator
+	fs       vfs.FS
+	command  string
+	args     []string
+	escalate func(string) bool
+}
+
+// NewEscalateMutator returns a new EscalateMutator that makes changes to paths
+// for which escalate returns true by running commands in fs with command and
+// args, and passes all other changes to m.
+func NewEscalateMutator(m Mutator, fs vfs.FS, command string, args []string, escalate func(string) bool) *EscalateMutator {
+	return &EscalateMutator{
+		m:        m,
+		fs:       fs,
+		command:  command,
+		args:     args,
+		escalate: escalate,
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *EscalateMutator) Chmod(name string, mode os.FileMode) error {
+	if !m.escalate(name) {
+		return m.m.Chmod(name, mode)
+	}
+	return m.run("chmod", []string{fmt.Sprintf("%o", mode)}, name)
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *EscalateMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *EscalateMutator) Mkdir(name string, perm os.FileMode) error {
+	if !m.escalate(name) {
+		return m.m.Mkdir(name, perm)
+	}
+	return m.run("mkdir", []string{"-m", fmt.Sprintf("%o", perm)}, name)
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *EscalateMutator) RemoveAll(name string) error {
+	if !m.escalate(name) {
+		return m.m.RemoveAll(name)
+	}
+	return m.run("rm", []string{"-rf"}, name)
+}
+
+// Rename implements Mutator.Rename.
+func (m *EscalateMutator) Rename(oldpath, newpath string) error {
+	if !m.escalate(oldpath) && !m.escalate(newpath) {
+		return m.m.Rename(oldpath, newpath)
+	}
+	return m.run("mv", []string{"-f"}, oldpath, newpath)
+}
+
+// RunCmd implements Mutator.RunCmd. Commands that run in a directory that
+// requires elevated privileges are run with elevated privileges.
+func (m *EscalateMutator) RunCmd(cmd *exec.Cmd) error {
+	if cmd.Dir == "" || !m.escalate(cmd.Dir) {
+		return m.m.RunCmd(cmd)
+	}
+	escalatedCmd := exec.Command(m.command, append(append(append([]string{}, m.args...), cmd.Path), cmd.Args[1:]...)...)
+	escalatedCmd.Dir = cmd.Dir
+	escalatedCmd.Env = cmd.Env
+	escalatedCmd.Stdin = cmd.Stdin
+	escalatedCmd.Stdout = cmd.Stdout
+	escalatedCmd.Stderr = cmd.Stderr
+	return m.m.RunCmd(escalatedCmd)
+}
+
+// Stat implements Mutator.Stat.
+func (m *EscalateMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile. The data is first written to a
+// temporary file, which is then installed at filename.
+func (m *EscalateMutator) WriteFile(filename string, data []byte, perm os.FileMode, currData []byte) error {
+	if !m.escalate(filename) {
+		return m.m.WriteFile(filename, data, perm, currData)
+	}
+	tempFile, err := ioutil.TempFile("", "chezmoi")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return m.run("install", []string{"-m", fmt.Sprintf("%o", perm), tempFile.Name()}, filename)
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *EscalateMutator) WriteSymlink(oldname, newname string) error {
+	if !m.escalate(newname) {
+		return m.m.WriteSymlink(oldname, newname)
+	}
+	return m.run("ln", []string{"-sfn", oldname}, newname)
+}
+
+// run runs name with args followed by paths in m.fs with elevated privileges.
+func (m *EscalateMutator) run(name string, args []string, paths ...string) error {
+	argv := append(append(append([]string{}, m.args...), name), args...)
+	for _, path := range paths {
+		rawPath, err := m.fs.RawPath(path)
+		if err != nil {
+			return err
+		}
+		argv = append(argv, rawPath)
+	}
+	cmd := exec.Command(m.command, argv...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %w: %s", m.command, name, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
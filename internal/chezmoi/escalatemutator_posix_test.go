@@ -0,0 +1,74 @@
+// +build !windows
+
+package chezmoi
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+var _ Mutator = &EscalateMutator{}
+
+func TestEscalateMutator(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/etc": map[string]interface{}{
+			"hosts":  "# old contents of /etc/hosts\n",
+			"old":    "# contents of /etc/old\n",
+			"remove": map[string]interface{}{"file": ""},
+		},
+		"/home/user/.bashrc":  "# old contents of .bashrc\n",
+		"/home/user/sudo.log": "",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	sudoLog, err := fs.RawPath("/home/user/sudo.log")
+	require.NoError(t, err)
+
+	escalate := func(path string) bool {
+		return strings.HasPrefix(path, "/etc/")
+	}
+	m := NewEscalateMutator(NewFSMutator(fs), fs, "sh", []string{"-c", `echo "$1" >> "$0" && exec "$@"`, sudoLog}, escalate)
+
+	require.NoError(t, m.WriteFile("/etc/hosts", []byte("# new contents of /etc/hosts\n"), 0644, nil))
+	require.NoError(t, m.Chmod("/etc/hosts", 0600))
+	require.NoError(t, m.Mkdir("/etc/dir", 0755))
+	require.NoError(t, m.Rename("/etc/old", "/etc/new"))
+	require.NoError(t, m.RemoveAll("/etc/remove"))
+	require.NoError(t, m.WriteSymlink("hosts", "/etc/symlink"))
+	require.NoError(t, m.WriteFile("/home/user/.bashrc", []byte("# new contents of .bashrc\n"), 0644, nil))
+
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/etc/hosts",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0600),
+			vfst.TestContentsString("# new contents of /etc/hosts\n"),
+		),
+		vfst.TestPath("/etc/dir",
+			vfst.TestIsDir,
+			vfst.TestModePerm(0755),
+		),
+		vfst.TestPath("/etc/old",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/etc/new",
+			vfst.TestContentsString("# contents of /etc/old\n"),
+		),
+		vfst.TestPath("/etc/remove",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/etc/symlink",
+			vfst.TestModeType(os.ModeSymlink),
+			vfst.TestSymlinkTarget("hosts"),
+		),
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# new contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/sudo.log",
+			vfst.TestContentsString("install\nchmod\nmkdir\nmv\nrm\nln\n"),
+		),
+	)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	vfs "github.com/twpayne/go-vfs"
 )
@@ -28,6 +29,7 @@ type File struct {
 	Encrypted        bool
 	Perm             os.FileMode
 	Template         bool
+	contentsMutex    sync.Mutex
 	contents         []byte
 	contentsErr      error
 	evaluateContents func() ([]byte, error)
@@ -56,6 +58,7 @@ func ParseFileAttributes(sourceName string) FileAttributes {
 		mode |= os.ModeSymlink
 	} else {
 		private := false
+		readOnly := false
 		if strings.HasPrefix(name, encryptedPrefix) {
 			name = strings.TrimPrefix(name, encryptedPrefix)
 			encrypted = true
@@ -64,6 +67,10 @@ func ParseFileAttributes(sourceName string) FileAttributes {
 			name = strings.TrimPrefix(name, privatePrefix)
 			private = true
 		}
+		if strings.HasPrefix(name, readOnlyPrefix) {
+			name = strings.TrimPrefix(name, readOnlyPrefix)
+			readOnly = true
+		}
 		if strings.HasPrefix(name, emptyPrefix) {
 			name = strings.TrimPrefix(name, emptyPrefix)
 			empty = true
@@ -75,6 +82,9 @@ func ParseFileAttributes(sourceName string) FileAttributes {
 		if private {
 			mode &= 0700
 		}
+		if readOnly {
+			mode &^= 0222
+		}
 	}
 	if strings.HasPrefix(name, dotPrefix) {
 		name = "." + strings.TrimPrefix(name, dotPrefix)
@@ -103,6 +113,9 @@ func (fa FileAttributes) SourceName() string {
 		if fa.Mode.Perm()&os.FileMode(077) == os.FileMode(0) {
 			sourceName += privatePrefix
 		}
+		if fa.Mode.Perm()&os.FileMode(0222) == os.FileMode(0) {
+			sourceName += readOnlyPrefix
+		}
 		if fa.Empty {
 			sourceName += emptyPrefix
 		}
@@ -140,27 +153,37 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 		return err
 	}
 	targetPath := filepath.Join(applyOptions.DestDir, f.targetName)
-	var info os.FileInfo
+	if applyOptions.Mode == ModeSymlink && f.linkable(contents) {
+		return f.applySymlink(fs, mutator, targetPath, applyOptions)
+	}
+	stat := fs.Lstat
 	if follow {
-		info, err = fs.Stat(targetPath)
-	} else {
-		info, err = fs.Lstat(targetPath)
+		stat = fs.Stat
 	}
+	info, err := stat(targetPath)
+	perm := f.Perm &^ applyOptions.Umask
 	var currData []byte
+	var currPerm os.FileMode
 	switch {
 	case err == nil && info.Mode().IsRegular():
 		if isEmpty(contents) && !f.Empty {
 			return mutator.RemoveAll(targetPath)
 		}
-		currData, err = fs.ReadFile(targetPath)
-		if err != nil {
-			return err
-		}
-		if !bytes.Equal(currData, contents) {
-			break
+		if !applyOptions.fileUnchanged(targetPath, info, contents) {
+			currData, err = fs.ReadFile(targetPath)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(currData, contents) {
+				currPerm = info.Mode().Perm()
+				break
+			}
+			if err := applyOptions.recordFileState(targetPath, info, contents); err != nil {
+				return err
+			}
 		}
-		if info.Mode().Perm() != f.Perm&^applyOptions.Umask {
-			if err := mutator.Chmod(targetPath, f.Perm&^applyOptions.Umask); err != nil {
+		if info.Mode().Perm() != perm {
+			if err := mutator.Chmod(targetPath, perm); err != nil {
 				return err
 			}
 		}
@@ -176,11 +199,35 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 	if isEmpty(contents) && !f.Empty {
 		return nil
 	}
-	return mutator.WriteFile(targetPath, contents, f.Perm&^applyOptions.Umask, currData)
+	// An existing read-only target cannot be opened for writing, so make it
+	// writable first. Writing an existing file does not necessarily change its
+	// permissions, so set them afterwards.
+	overwriteReadOnly := currData != nil && currPerm&0200 == 0
+	if overwriteReadOnly {
+		if err := mutator.Chmod(targetPath, currPerm|0200); err != nil {
+			return err
+		}
+	}
+	if err := mutator.WriteFile(targetPath, contents, perm, currData); err != nil {
+		return err
+	}
+	if overwriteReadOnly || currData != nil && perm&0200 == 0 {
+		if err := mutator.Chmod(targetPath, perm); err != nil {
+			return err
+		}
+	}
+	if applyOptions.DryRun {
+		return nil
+	}
+	info, err = stat(targetPath)
+	if err != nil {
+		return err
+	}
+	return applyOptions.recordFileState(targetPath, info, contents)
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (f *File) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (f *File) ConcreteValue(ignore func(string) bool, sourceDir func(string) string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(f.targetName) {
 		return nil, nil
 	}
@@ -190,7 +237,7 @@ func (f *File) ConcreteValue(ignore func(string) bool, sourceDir string, umask o
 	}
 	return &fileConcreteValue{
 		Type:       "file",
-		SourcePath: filepath.Join(sourceDir, f.SourceName()),
+		SourcePath: filepath.Join(sourceDir(f.targetName), f.SourceName()),
 		TargetPath: f.TargetName(),
 		Empty:      f.Empty,
 		Encrypted:  f.Encrypted,
@@ -200,8 +247,10 @@ func (f *File) ConcreteValue(ignore func(string) bool, sourceDir string, umask o
 	}, nil
 }
 
-// Contents returns f's contents.
+// Contents returns f's contents. It is safe to call Contents concurrently.
 func (f *File) Contents() ([]byte, error) {
+	f.contentsMutex.Lock()
+	defer f.contentsMutex.Unlock()
 	if f.evaluateContents != nil {
 		f.contents, f.contentsErr = f.evaluateContents()
 		f.evaluateContents = nil
@@ -228,6 +277,11 @@ func (f *File) Private() bool {
 	return f.Perm&077 == 0
 }
 
+// ReadOnly returns true if f is read-only.
+func (f *File) ReadOnly() bool {
+	return f.Perm&0222 == 0
+}
+
 // SourceName implements Entry.SourceName.
 func (f *File) SourceName() string {
 	return f.sourceName
@@ -238,6 +292,34 @@ func (f *File) TargetName() string {
 	return f.targetName
 }
 
+// applySymlink ensures that targetPath in fs is a symlink to f's file in the
+// source directory.
+func (f *File) applySymlink(fs vfs.FS, mutator Mutator, targetPath string, applyOptions *ApplyOptions) error {
+	sourcePath, err := filepath.Abs(filepath.Join(applyOptions.SourceDir(f.targetName), f.sourceName))
+	if err != nil {
+		return err
+	}
+	info, err := fs.Lstat(targetPath)
+	switch {
+	case err == nil && info.Mode()&os.ModeType == os.ModeSymlink:
+		linksToSourcePath, err := isSymlinkTo(fs, targetPath, sourcePath)
+		if err != nil {
+			return err
+		}
+		if linksToSourcePath {
+			return nil
+		}
+	case err == nil:
+		if err := mutator.RemoveAll(targetPath); err != nil {
+			return err
+		}
+	case os.IsNotExist(err):
+	default:
+		return err
+	}
+	return mutator.WriteSymlink(sourcePath, targetPath)
+}
+
 // archive writes f to w.
 func (f *File) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
 	if ignore(f.targetName) {
@@ -261,3 +343,11 @@ func (f *File) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *
 	_, err = w.Write(contents)
 	return err
 }
+
+// linkable returns true if f, with contents, can be applied as a symlink to
+// its file in the source directory. Templates and encrypted files have
+// different contents in the source directory and executable, private, and
+// read-only files would not be executable, private, or read-only.
+func (f *File) linkable(contents []byte) bool {
+	return !f.Template && !f.Encrypted && !f.Executable() && !f.Private() && !f.ReadOnly() && (f.Empty || !isEmpty(contents))
+}
@@ -84,6 +84,20 @@ func TestFileAttributes(t *testing.T) {
 				Template: true,
 			},
 		},
+		{
+			sourceName: "readonly_dot_foo",
+			fa: FileAttributes{
+				Name: ".foo",
+				Mode: 0444,
+			},
+		},
+		{
+			sourceName: "private_readonly_executable_foo",
+			fa: FileAttributes{
+				Name: "foo",
+				Mode: 0500,
+			},
+		},
 		{
 			sourceName: "symlink_foo",
 			fa: FileAttributes{
@@ -0,0 +1,82 @@
+package chezmoi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// fileStateRacyWindow is the coarsest resolution of modification times that
+// is assumed for filesystems.
+const fileStateRacyWindow = 2 * time.Second
+
+// A FileState records the state of a target file when chezmoi last wrote or
+// verified it, so that unchanged files can be detected without reading them.
+type FileState struct {
+	Size           int64     `json:"size"`
+	ModTime        time.Time `json:"modTime"`
+	Inode          uint64    `json:"inode,omitempty"`
+	ContentsSHA256 string    `json:"contentsSHA256"`
+	RecordedAt     time.Time `json:"recordedAt"`
+}
+
+// newFileState returns a new FileState for a file with info and contents.
+func newFileState(info os.FileInfo, contents []byte) *FileState {
+	return &FileState{
+		Size:           info.Size(),
+		ModTime:        info.ModTime(),
+		Inode:          inode(info),
+		ContentsSHA256: sha256Sum(contents),
+		RecordedAt:     time.Now(),
+	}
+}
+
+// matches returns true if the file with info matches s and has contents. Like
+// git's racily clean index entries, s is not trusted if the file was modified
+// within fileStateRacyWindow of s being recorded, as a later modification in
+// the same timestamp tick would not change the file's modification time.
+func (s *FileState) matches(info os.FileInfo, contents []byte) bool {
+	return s.ModTime.Before(s.RecordedAt.Add(-fileStateRacyWindow)) &&
+		s.Size == info.Size() &&
+		s.ModTime.Equal(info.ModTime()) &&
+		s.Inode == inode(info) &&
+		s.ContentsSHA256 == sha256Sum(contents)
+}
+
+// fileUnchanged returns true if the file at targetPath with info is known,
+// from the persistent state, to have contents without reading it.
+func (o *ApplyOptions) fileUnchanged(targetPath string, info os.FileInfo, contents []byte) bool {
+	if o.Strict || o.PersistentState == nil || o.FileStateBucket == nil {
+		return false
+	}
+	data, err := o.PersistentState.Get(o.FileStateBucket, []byte(targetPath))
+	if err != nil || data == nil {
+		return false
+	}
+	var fileState FileState
+	if err := json.Unmarshal(data, &fileState); err != nil {
+		return false
+	}
+	return fileState.matches(info, contents)
+}
+
+// recordFileState records that the file at targetPath with info has
+// contents. Nothing is recorded in dry run mode, as the file on disk might not
+// reflect what was written.
+func (o *ApplyOptions) recordFileState(targetPath string, info os.FileInfo, contents []byte) error {
+	if o.DryRun || o.PersistentState == nil || o.FileStateBucket == nil {
+		return nil
+	}
+	data, err := json.Marshal(newFileState(info, contents))
+	if err != nil {
+		return err
+	}
+	return o.PersistentState.Set(o.FileStateBucket, []byte(targetPath), data)
+}
+
+func sha256Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,16 @@
+// +build !windows
+
+package chezmoi
+
+import (
+	"os"
+	"syscall"
+)
+
+// inode returns the inode number of info.
+func inode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
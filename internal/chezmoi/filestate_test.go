@@ -0,0 +1,97 @@
+package chezmoi
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestFileStateFastPath(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.config/chezmoi":              &vfst.Dir{Perm: 0755},
+		"/home/user/.local/share/chezmoi/dot_foo": "contents",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	persistentState, err := NewBoltPersistentState(fs, "/home/user/.config/chezmoi/chezmoistate.boltdb", vfst.DefaultUmask, nil)
+	require.NoError(t, err)
+	defer persistentState.Close()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	apply := func(strict bool) {
+		require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+			DestDir:         ts.DestDir,
+			FileStateBucket: []byte("fileState"),
+			Ignore:          ts.TargetIgnore.Match,
+			PersistentState: persistentState,
+			Strict:          strict,
+			Umask:           022,
+		}))
+	}
+
+	apply(false)
+	data, err := persistentState.Get([]byte("fileState"), []byte("/home/user/.foo"))
+	require.NoError(t, err)
+	var fileState FileState
+	require.NoError(t, json.Unmarshal(data, &fileState))
+	assert.Equal(t, int64(len("contents")), fileState.Size)
+	assert.Equal(t, sha256Sum([]byte("contents")), fileState.ContentsSHA256)
+
+	// modify changes the file without changing its size, modification time,
+	// or inode.
+	modify := func() {
+		info, err := fs.Stat("/home/user/.foo")
+		require.NoError(t, err)
+		f, err := fs.OpenFile("/home/user/.foo", os.O_WRONLY, 0)
+		require.NoError(t, err)
+		_, err = f.Write([]byte("CONTENTS"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.NoError(t, fs.Chtimes("/home/user/.foo", info.ModTime(), info.ModTime()))
+	}
+
+	// The file was modified in the same timestamp tick as its state was
+	// recorded, so the recorded state is not trusted.
+	modify()
+	apply(false)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.foo",
+			vfst.TestContentsString("contents"),
+		),
+	)
+
+	// Once the state is recorded well after the file was last modified,
+	// chezmoi trusts it unless --strict is given.
+	data, err = persistentState.Get([]byte("fileState"), []byte("/home/user/.foo"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &fileState))
+	fileState.RecordedAt = fileState.ModTime.Add(time.Hour)
+	data, err = json.Marshal(&fileState)
+	require.NoError(t, err)
+	require.NoError(t, persistentState.Set([]byte("fileState"), []byte("/home/user/.foo"), data))
+	modify()
+
+	apply(false)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.foo",
+			vfst.TestContentsString("CONTENTS"),
+		),
+	)
+
+	apply(true)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.foo",
+			vfst.TestContentsString("contents"),
+		),
+	)
+}
@@ -0,0 +1,12 @@
+// +build windows
+
+package chezmoi
+
+import (
+	"os"
+)
+
+// inode always returns 0 on Windows.
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
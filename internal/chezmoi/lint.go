@@ -0,0 +1,399 @@
+package chezmoi
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/bmatcuk/doublestar"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// attributePrefixes are all attribute prefixes, used to detect misordered or
+// misspelled prefixes.
+var attributePrefixes = []string{
+	dotPrefix,
+	emptyPrefix,
+	encryptedPrefix,
+	exactPrefix,
+	executablePrefix,
+	oncePrefix,
+	privatePrefix,
+	readOnlyPrefix,
+	runPrefix,
+	symlinkPrefix,
+}
+
+// A Problem is a problem found in the source state by TargetState.Lint.
+type Problem struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (p *Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Message)
+}
+
+// A linter accumulates problems found in a source state.
+type linter struct {
+	ts          *TargetState
+	fs          vfs.FS
+	manifest    *AttributeManifest
+	problems    []*Problem
+	sourceNames []string          // sourceNames are the source names walked.
+	sourcePaths map[string]string // sourcePaths maps target names to source paths.
+}
+
+// Lint returns all problems found in the source state in fs. ts must already
+// have been populated from fs.
+func (ts *TargetState) Lint(fs vfs.FS) ([]*Problem, error) {
+	l := &linter{
+		ts:          ts,
+		fs:          fs,
+		sourcePaths: make(map[string]string),
+	}
+	if err := l.readAttributeManifest(); err != nil {
+		return nil, err
+	}
+	if err := vfs.Walk(fs, ts.SourceDir, l.lintPath); err != nil {
+		return nil, err
+	}
+	l.lintAttributeManifest()
+	for _, entry := range ts.AllEntries() {
+		if dir, ok := entry.(*Dir); ok && dir.Exact && len(dir.Entries) == 0 {
+			l.addProblem(filepath.Join(ts.SourceDir, dir.sourceName), 0, "exact directory has no entries, all of its contents will be removed")
+		}
+	}
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		if l.problems[i].Path != l.problems[j].Path {
+			return l.problems[i].Path < l.problems[j].Path
+		}
+		return l.problems[i].Line < l.problems[j].Line
+	})
+	return l.problems, nil
+}
+
+func (l *linter) addProblem(path string, line int, format string, args ...interface{}) {
+	l.problems = append(l.problems, &Problem{
+		Path:    path,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// addTemplateProblem adds a problem from a text/template error, extracting the
+// line number if possible.
+func (l *linter) addTemplateProblem(path string, err error) {
+	var te *TemplateError
+	if !errors.As(err, &te) {
+		te = newTemplateError(path, nil, nil, err)
+	}
+	l.addProblem(path, te.Line, "%s", te.Message)
+}
+
+// lintName checks name, the name remaining after parsing all known prefixes,
+// for misordered or misspelled prefixes.
+func (l *linter) lintName(path, name string) {
+	index := strings.IndexByte(name, '_')
+	if index <= 0 {
+		return
+	}
+	prefix := name[:index+1]
+	for _, attributePrefix := range attributePrefixes {
+		if prefix == attributePrefix {
+			l.addProblem(path, 0, "%s: misordered or unsupported attribute prefix, taken as part of the name %q", prefix, name)
+			return
+		}
+	}
+	for _, attributePrefix := range attributePrefixes {
+		// Short prefixes are too likely to match ordinary words.
+		if len(attributePrefix) < 5 {
+			continue
+		}
+		if levenshteinDistance(prefix, attributePrefix) <= 2 {
+			l.addProblem(path, 0, "%s: unknown attribute prefix, did you mean %s?", prefix, attributePrefix)
+			return
+		}
+	}
+}
+
+func (l *linter) lintPath(path string, info os.FileInfo, err error) error {
+	if err != nil {
+		return err
+	}
+	relPath, err := filepath.Rel(l.ts.SourceDir, path)
+	if err != nil {
+		return err
+	}
+	if relPath == "." {
+		return nil
+	}
+	if strings.HasPrefix(info.Name(), ".") {
+		switch {
+		case info.Name() == ignoreName:
+			dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
+			return l.lintPatterns(path, filepath.Join(dns...), true)
+		case info.Name() == attributesName:
+			// The attribute manifest is checked separately.
+			return nil
+		case info.Name() == removeName:
+			dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
+			return l.lintPatterns(path, filepath.Join(dns...), false)
+		case info.Name() == templatesDirName:
+			return l.lintTemplatesDir(path)
+		case info.Name() == triggersName:
+			return l.lintTriggers(path)
+		case info.IsDir():
+			return filepath.SkipDir
+		}
+		return nil
+	}
+	l.sourceNames = append(l.sourceNames, relPath)
+	switch {
+	case info.IsDir():
+		das := parseDirNameComponents(splitPathList(relPath))
+		l.lintName(path, das[len(das)-1].Name)
+		l.lintTargetName(path, filepath.Join(dirNames(das)...))
+	case info.Mode().IsRegular():
+		psfp := parseSourceFilePath(relPath)
+		dns := dirNames(psfp.dirAttributes)
+		switch {
+		case psfp.fileAttributes != nil:
+			fa := l.manifest.applyFileAttributes(relPath, *psfp.fileAttributes)
+			l.lintName(path, fa.Name)
+			l.lintTargetName(path, filepath.Join(append(dns, fa.Name)...))
+			if fa.Template && !fa.Encrypted {
+				if err := l.lintTemplate(path); err != nil {
+					return err
+				}
+			}
+		case psfp.scriptAttributes != nil:
+			sa := l.manifest.applyScriptAttributes(relPath, *psfp.scriptAttributes)
+			l.lintName(path, sa.Name)
+			l.lintTargetName(path, filepath.Join(append(dns, sa.Name)...))
+			if sa.Template {
+				if err := l.lintTemplate(path); err != nil {
+					return err
+				}
+			}
+		case psfp.blockAttributes != nil:
+			ba := l.manifest.applyBlockAttributes(relPath, *psfp.blockAttributes)
+			l.lintName(path, ba.Name)
+			l.lintTargetName(path, filepath.Join(append(dns, ba.Name)...))
+			if ba.Template {
+				if err := l.lintTemplate(path); err != nil {
+					return err
+				}
+			}
+		case psfp.mergeAttributes != nil:
+			ma := l.manifest.applyMergeAttributes(relPath, *psfp.mergeAttributes)
+			l.lintName(path, ma.Name)
+			l.lintTargetName(path, filepath.Join(append(dns, ma.Name)...))
+			if _, ok := mergeFormats[strings.ToLower(filepath.Ext(ma.Name))]; !ok {
+				l.addProblem(path, 0, "%s: unsupported merge format", ma.Name)
+			}
+			if ma.Template {
+				if err := l.lintTemplate(path); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		l.addProblem(path, 0, "unsupported file type")
+	}
+	return nil
+}
+
+// lintAttributeManifest checks that every pattern in the attribute manifest
+// matches at least one source name.
+func (l *linter) lintAttributeManifest() {
+	path := AttributeManifestPath(l.ts.SourceDir)
+RULE:
+	for _, rule := range l.manifest.rules {
+		for _, sourceName := range l.sourceNames {
+			if rule.match(sourceName) {
+				continue RULE
+			}
+		}
+		l.addProblem(path, rule.line+1, "%s: pattern does not match anything", rule.pattern)
+	}
+}
+
+// lintPatterns checks the patterns in the .chezmoiignore or .chezmoiremove
+// file at path. If checkMatches is true then patterns that do not match
+// anything are reported. Line numbers refer to the output of executing the
+// file as a template.
+func (l *linter) lintPatterns(path, relPath string, checkMatches bool) error {
+	data, err := l.fs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = l.ts.ExecuteTemplateData(path, data)
+	if err != nil {
+		l.addTemplateProblem(path, err)
+		return nil
+	}
+	dir := filepath.Dir(relPath)
+	var targetNames []string
+	for _, entry := range l.ts.AllEntries() {
+		targetNames = append(targetNames, entry.TargetName())
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if index := strings.IndexRune(text, '#'); index != -1 {
+			text = text[:index]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		text = strings.TrimPrefix(text, "!")
+		pattern := filepath.Join(dir, text)
+		if _, err := doublestar.PathMatch(pattern, pattern); err != nil {
+			l.addProblem(path, line, "%s: invalid pattern: %v", text, err)
+			continue
+		}
+		if checkMatches && !l.patternMatchesAnything(pattern, targetNames) {
+			l.addProblem(path, line, "%s: pattern does not match anything", text)
+		}
+	}
+	return s.Err()
+}
+
+// readAttributeManifest reads the attribute manifest, reporting any error in
+// it as a problem.
+func (l *linter) readAttributeManifest() error {
+	manifest, err := ReadAttributeManifest(l.fs, l.ts.SourceDir)
+	var ame *attributeManifestError
+	switch {
+	case errors.As(err, &ame):
+		l.addProblem(AttributeManifestPath(l.ts.SourceDir), ame.line, "%s", ame.message)
+		l.manifest = &AttributeManifest{}
+	case err != nil:
+		return err
+	default:
+		l.manifest = manifest
+	}
+	return nil
+}
+
+// lintTriggers checks the triggers in the .chezmoitriggers file at path.
+func (l *linter) lintTriggers(path string) error {
+	data, err := l.fs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = l.ts.ExecuteTemplateData(path, data)
+	if err != nil {
+		l.addTemplateProblem(path, err)
+		return nil
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		index := strings.IndexFunc(text, unicode.IsSpace)
+		if index == -1 {
+			l.addProblem(path, line, "%s: missing command", text)
+			continue
+		}
+		if _, err := doublestar.PathMatch(text[:index], text[:index]); err != nil {
+			l.addProblem(path, line, "%s: invalid pattern: %v", text[:index], err)
+		}
+	}
+	return s.Err()
+}
+
+// lintTargetName checks that targetName is not generated by more than one
+// source path.
+func (l *linter) lintTargetName(path, targetName string) {
+	if otherPath, ok := l.sourcePaths[targetName]; ok {
+		l.addProblem(path, 0, "duplicate target %s, also generated by %s", targetName, otherPath)
+		return
+	}
+	l.sourcePaths[targetName] = path
+}
+
+// lintTemplate checks that the template at path can be parsed.
+func (l *linter) lintTemplate(path string) error {
+	data, err := l.fs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err := template.New(path).Option(l.ts.TemplateOptions...).Funcs(l.ts.TemplateFuncs).Parse(string(data)); err != nil {
+		l.addTemplateProblem(path, err)
+	}
+	return nil
+}
+
+func (l *linter) lintTemplatesDir(dir string) error {
+	if err := vfs.Walk(l.fs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			return l.lintTemplate(path)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return filepath.SkipDir
+}
+
+// patternMatchesAnything returns true if pattern matches any of targetNames or
+// anything in the destination directory.
+func (l *linter) patternMatchesAnything(pattern string, targetNames []string) bool {
+	for _, targetName := range targetNames {
+		if ok, _ := doublestar.PathMatch(pattern, targetName); ok {
+			return true
+		}
+	}
+	if l.ts.DestDir == "" {
+		return false
+	}
+	matches, err := doublestar.GlobOS(l.fs, filepath.Join(l.ts.DestDir, pattern))
+	return err == nil && len(matches) > 0
+}
+
+// levenshteinDistance returns the Levenshtein distance between a and b.
+func levenshteinDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
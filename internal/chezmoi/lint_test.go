@@ -0,0 +1,188 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestLint(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		root     interface{}
+		expected []string
+	}{
+		{
+			name: "clean",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoiignore":      "README.md\n",
+					"README.md":           "",
+					"dot_bashrc":          "",
+					"exact_dir/file":      "",
+					"private_dot_ssh/foo": "",
+				},
+			},
+		},
+		{
+			name: "duplicate_target",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"dot_foo":      "",
+					"dot_foo.tmpl": "",
+				},
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/dot_foo.tmpl: duplicate target .foo, also generated by /home/user/.local/share/chezmoi/dot_foo",
+			},
+		},
+		{
+			name: "block",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"block_dot_bashrc":     "",
+					"block_dot_zshrc.tmpl": "{{ if }}\n",
+					"dot_bashrc":           "",
+				},
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/block_dot_zshrc.tmpl:1: missing value for if",
+				"/home/user/.local/share/chezmoi/dot_bashrc: duplicate target .bashrc, also generated by /home/user/.local/share/chezmoi/block_dot_bashrc",
+			},
+		},
+		{
+			name: "merge",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"dot_config.json":             "",
+					"merge_dot_config.json":       "",
+					"merge_dot_settings.ini":      "",
+					"merge_dot_settings.yml.tmpl": "{{ if }}\n",
+				},
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/merge_dot_config.json: duplicate target .config.json, also generated by /home/user/.local/share/chezmoi/dot_config.json",
+				"/home/user/.local/share/chezmoi/merge_dot_settings.ini: .settings.ini: unsupported merge format",
+				"/home/user/.local/share/chezmoi/merge_dot_settings.yml.tmpl:1: missing value for if",
+			},
+		},
+		{
+			name: "misordered_prefix",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/executable_private_foo": "",
+			},
+			expected: []string{
+				`/home/user/.local/share/chezmoi/executable_private_foo: private_: misordered or unsupported attribute prefix, taken as part of the name "private_foo"`,
+			},
+		},
+		{
+			name: "misspelled_prefix",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/privat_dot_foo": "",
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/privat_dot_foo: privat_: unknown attribute prefix, did you mean private_?",
+			},
+		},
+		{
+			name: "template_parse_error",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/dot_foo.tmpl": "line1\n{{ if }}\n",
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/dot_foo.tmpl:2: missing value for if",
+			},
+		},
+		{
+			name: "attributes_template_parse_error",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoiattributes": "dot_foo template\n",
+					"dot_foo":            "{{ if }}\n",
+				},
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/dot_foo:1: missing value for if",
+			},
+		},
+		{
+			name: "attributes_patterns",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoiattributes": "dot_foo private\ndot_bar template\n",
+					"dot_foo":            "",
+				},
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/.chezmoiattributes:2: dot_bar: pattern does not match anything",
+			},
+		},
+		{
+			name: "ignore_patterns",
+			root: map[string]interface{}{
+				"/home/user/.bashrc": "",
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoiignore": "# comment\n.bashrc\n[\nREADME.md\n",
+				},
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/.chezmoiignore:3: [: invalid pattern: syntax error in pattern",
+				"/home/user/.local/share/chezmoi/.chezmoiignore:4: README.md: pattern does not match anything",
+			},
+		},
+		{
+			name: "empty_exact_dir",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/exact_dir/.keep": "",
+			},
+			expected: []string{
+				"/home/user/.local/share/chezmoi/exact_dir: exact directory has no entries, all of its contents will be removed",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			ts := NewTargetState(
+				WithDestDir("/home/user"),
+				WithSourceDir("/home/user/.local/share/chezmoi"),
+			)
+			require.NoError(t, ts.Populate(fs, nil))
+			problems, err := ts.Lint(fs)
+			require.NoError(t, err)
+			var actual []string
+			for _, problem := range problems {
+				actual = append(actual, problem.String())
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestLintAttributeManifestError(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoiattributes": "dot_foo private\ndot_[bar template\n",
+			"dot_foo":            "",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	assert.Error(t, ts.Populate(fs, nil))
+	problems, err := ts.Lint(fs)
+	require.NoError(t, err)
+	var actual []string
+	for _, problem := range problems {
+		actual = append(actual, problem.String())
+	}
+	assert.Equal(t, []string{
+		"/home/user/.local/share/chezmoi/.chezmoiattributes:2: dot_[bar: invalid pattern",
+	}, actual)
+}
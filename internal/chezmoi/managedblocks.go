@@ -0,0 +1,69 @@
+package chezmoi
+
+import (
+	"encoding/json"
+	"sort"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// managedBlockTargetNames returns the target names of all blocks in ts that are
+// not ignored.
+func (ts *TargetState) managedBlockTargetNames(ignore func(string) bool) map[string]struct{} {
+	managedBlockTargetNames := make(map[string]struct{})
+	for _, entry := range ts.AllEntries() {
+		if _, ok := entry.(*Block); ok && !ignore(entry.TargetName()) {
+			managedBlockTargetNames[entry.TargetName()] = struct{}{}
+		}
+	}
+	return managedBlockTargetNames
+}
+
+// removeUnmanagedBlocks removes blocks that were managed by a previous apply
+// but are no longer in ts from their targets, and records the blocks that are
+// now managed. The rest of each target is left unchanged. Ignored blocks are
+// never removed.
+func (ts *TargetState) removeUnmanagedBlocks(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
+	if applyOptions.PersistentState == nil || applyOptions.ManagedBlocksBucket == nil {
+		return nil
+	}
+
+	managedBlockTargetNames := ts.managedBlockTargetNames(applyOptions.Ignore)
+	var previousTargetNames []string
+	switch data, err := applyOptions.PersistentState.Get(applyOptions.ManagedBlocksBucket, []byte(ts.DestDir)); {
+	case err != nil:
+		return err
+	case data != nil:
+		if err := json.Unmarshal(data, &previousTargetNames); err != nil {
+			return err
+		}
+	}
+
+	var pendingTargetNames []string
+	for _, targetName := range previousTargetNames {
+		if _, ok := managedBlockTargetNames[targetName]; ok || applyOptions.Ignore(targetName) {
+			continue
+		}
+		if err := applyBlock(fs, mutator, follow, applyOptions, targetName, nil); err != nil {
+			if !applyOptions.KeepGoing {
+				return err
+			}
+			applyOptions.recordError(targetName, "", err, nil)
+			pendingTargetNames = append(pendingTargetNames, targetName)
+		}
+	}
+
+	if applyOptions.DryRun {
+		return nil
+	}
+	targetNames := pendingTargetNames
+	for targetName := range managedBlockTargetNames {
+		targetNames = append(targetNames, targetName)
+	}
+	sort.Strings(targetNames)
+	data, err := json.Marshal(targetNames)
+	if err != nil {
+		return err
+	}
+	return applyOptions.PersistentState.Set(applyOptions.ManagedBlocksBucket, []byte(ts.DestDir), data)
+}
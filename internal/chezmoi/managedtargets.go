@@ -0,0 +1,208 @@
+package chezmoi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// managedTargetNames returns the names of all targets in ts that are not
+// ignored. Targets that only contain blocks or merged documents are not
+// managed.
+func (ts *TargetState) managedTargetNames(ignore func(string) bool) map[string]struct{} {
+	managedTargetNames := make(map[string]struct{})
+	var addEntry func(Entry)
+	addEntry = func(entry Entry) {
+		switch entry.(type) {
+		case *Block, *Merge, *Script:
+			return
+		}
+		if ignore(entry.TargetName()) {
+			return
+		}
+		managedTargetNames[entry.TargetName()] = struct{}{}
+		if dir, ok := entry.(*Dir); ok {
+			for _, entry := range dir.Entries {
+				addEntry(entry)
+			}
+		}
+	}
+	for _, entry := range ts.Entries {
+		addEntry(entry)
+	}
+	return managedTargetNames
+}
+
+// removeTargets removes the targets matched by .chezmoiremove and the targets
+// that were managed by a previous apply but are no longer in ts, and records
+// the targets that are now managed. All removals are confirmed at once, before
+// any target is removed. Targets are only removed if applyOptions.Remove is set
+// and their removal is confirmed, otherwise unmanaged targets are remembered
+// until they are. Ignored targets, and directories that contain anything else,
+// are never removed.
+func (ts *TargetState) removeTargets(fs vfs.FS, mutator Mutator, applyOptions *ApplyOptions) error {
+	managedTargetNames := ts.managedTargetNames(applyOptions.Ignore)
+	unmanagedTargetNames, err := ts.findUnmanagedTargets(fs, applyOptions, managedTargetNames)
+	if err != nil {
+		return err
+	}
+	if !applyOptions.Remove {
+		return ts.recordManagedTargets(applyOptions, managedTargetNames, unmanagedTargetNames)
+	}
+
+	targetPaths, err := ts.findRemoveTargets(fs, managedTargetNames)
+	if err != nil {
+		return err
+	}
+	if err := applyOptions.checkMaxRemove(len(targetPaths), "targets matched by "+removeName); err != nil {
+		return err
+	}
+	if err := applyOptions.checkMaxRemove(len(unmanagedTargetNames), "targets no longer in the source state"); err != nil {
+		return err
+	}
+	removedTargetNames := make(map[string]struct{})
+	for _, targetPath := range targetPaths {
+		removedTargetNames[strings.TrimPrefix(targetPath, ts.DestDir+string(filepath.Separator))] = struct{}{}
+	}
+	removeUnmanagedTargetNames := make(map[string]struct{})
+	for _, targetName := range unmanagedTargetNames {
+		if _, ok := removedTargetNames[targetName]; ok {
+			// The target is already matched by .chezmoiremove.
+			removeUnmanagedTargetNames[targetName] = struct{}{}
+			continue
+		}
+		targetPath := filepath.Join(ts.DestDir, targetName)
+		info, err := fs.Lstat(targetPath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if empty, err := dirEmptyAfterRemove(fs, targetPath, targetName, removedTargetNames); err != nil {
+				return err
+			} else if !empty {
+				continue
+			}
+		}
+		removedTargetNames[targetName] = struct{}{}
+		removeUnmanagedTargetNames[targetName] = struct{}{}
+		targetPaths = append(targetPaths, targetPath)
+	}
+
+	var pendingTargetNames []string
+	if len(targetPaths) != 0 {
+		if ok, err := applyOptions.confirmRemove(targetPaths); err != nil {
+			return err
+		} else if !ok {
+			for _, targetName := range unmanagedTargetNames {
+				if _, ok := removeUnmanagedTargetNames[targetName]; ok {
+					pendingTargetNames = append(pendingTargetNames, targetName)
+				}
+			}
+			targetPaths = nil
+		}
+	}
+	for _, targetPath := range targetPaths {
+		if err := mutator.RemoveAll(targetPath); err != nil {
+			if !applyOptions.KeepGoing {
+				return err
+			}
+			targetName := strings.TrimPrefix(targetPath, ts.DestDir+string(filepath.Separator))
+			applyOptions.recordError(targetName, "", err, nil)
+			if _, ok := removeUnmanagedTargetNames[targetName]; ok {
+				pendingTargetNames = append(pendingTargetNames, targetName)
+			}
+		}
+	}
+	return ts.recordManagedTargets(applyOptions, managedTargetNames, pendingTargetNames)
+}
+
+// findUnmanagedTargets returns the names of the targets that were managed by a
+// previous apply but are not in managedTargetNames and still exist, in reverse
+// order so that children come before their parents.
+func (ts *TargetState) findUnmanagedTargets(fs vfs.FS, applyOptions *ApplyOptions, managedTargetNames map[string]struct{}) ([]string, error) {
+	if applyOptions.PersistentState == nil || applyOptions.ManagedTargetsBucket == nil {
+		return nil, nil
+	}
+
+	var previousTargetNames []string
+	switch data, err := applyOptions.PersistentState.Get(applyOptions.ManagedTargetsBucket, []byte(ts.DestDir)); {
+	case err != nil:
+		return nil, err
+	case data != nil:
+		if err := json.Unmarshal(data, &previousTargetNames); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(previousTargetNames)))
+	var unmanagedTargetNames []string
+	for _, targetName := range previousTargetNames {
+		if _, ok := managedTargetNames[targetName]; ok || applyOptions.Ignore(targetName) {
+			continue
+		}
+		switch _, err := fs.Lstat(filepath.Join(ts.DestDir, targetName)); {
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		unmanagedTargetNames = append(unmanagedTargetNames, targetName)
+	}
+	return unmanagedTargetNames, nil
+}
+
+// recordManagedTargets records managedTargetNames and pendingTargetNames, the
+// targets that should have been removed but were not, as the targets managed
+// in ts.DestDir.
+func (ts *TargetState) recordManagedTargets(applyOptions *ApplyOptions, managedTargetNames map[string]struct{}, pendingTargetNames []string) error {
+	if applyOptions.DryRun || applyOptions.PersistentState == nil || applyOptions.ManagedTargetsBucket == nil {
+		return nil
+	}
+	targetNames := append([]string(nil), pendingTargetNames...)
+	for targetName := range managedTargetNames {
+		targetNames = append(targetNames, targetName)
+	}
+	sort.Strings(targetNames)
+	data, err := json.Marshal(targetNames)
+	if err != nil {
+		return err
+	}
+	return applyOptions.PersistentState.Set(applyOptions.ManagedTargetsBucket, []byte(ts.DestDir), data)
+}
+
+// dirEmptyAfterRemove returns true if the directory targetName at targetPath
+// in fs contains nothing but removedTargetNames.
+func dirEmptyAfterRemove(fs vfs.FS, targetPath, targetName string, removedTargetNames map[string]struct{}) (bool, error) {
+	infos, err := fs.ReadDir(targetPath)
+	if err != nil {
+		return false, err
+	}
+	for _, info := range infos {
+		if _, ok := removedTargetNames[filepath.Join(targetName, info.Name())]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// checkMaxRemove returns an error if removing count targets, described by
+// what, would exceed o.MaxRemove.
+func (o *ApplyOptions) checkMaxRemove(count int, what string) error {
+	if o.MaxRemove > 0 && count > o.MaxRemove {
+		return fmt.Errorf("refusing to remove %d %s, more than the maximum of %d", count, what, o.MaxRemove)
+	}
+	return nil
+}
+
+// confirmRemove returns true if targetPaths should be removed.
+func (o *ApplyOptions) confirmRemove(targetPaths []string) (bool, error) {
+	if o.ConfirmRemove == nil {
+		return true, nil
+	}
+	return o.ConfirmRemove(targetPaths)
+}
@@ -0,0 +1,149 @@
+package chezmoi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestRemoveUnmanagedTargets(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.config/chezmoi": &vfst.Dir{Perm: 0755},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoiignore":   ".ignored\n",
+			"dot_dir/foo":      "# contents of .dir/foo\n",
+			"dot_ignored":      "# contents of .ignored\n",
+			"dot_keep":         "# contents of .keep\n",
+			"dot_mixed/foo":    "# contents of .mixed/foo\n",
+			"dot_removed":      "# contents of .removed\n",
+			"dot_unmodified/x": "# contents of .unmodified/x\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	persistentState, err := NewBoltPersistentState(fs, "/home/user/.config/chezmoi/chezmoistate.boltdb", vfst.DefaultUmask, nil)
+	require.NoError(t, err)
+	defer persistentState.Close()
+
+	var confirmedTargetPaths []string
+	apply := func(remove, confirm bool) {
+		ts := NewTargetState(
+			WithDestDir("/home/user"),
+			WithSourceDir("/home/user/.local/share/chezmoi"),
+		)
+		require.NoError(t, ts.Populate(fs, nil))
+		require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+			ConfirmRemove: func(targetPaths []string) (bool, error) {
+				confirmedTargetPaths = targetPaths
+				return confirm, nil
+			},
+			DestDir:              ts.DestDir,
+			Ignore:               ts.TargetIgnore.Match,
+			ManagedTargetsBucket: []byte("managedTargets"),
+			PersistentState:      persistentState,
+			Remove:               remove,
+			Umask:                022,
+		}))
+	}
+	getTargetNames := func() []string {
+		data, err := persistentState.Get([]byte("managedTargets"), []byte("/home/user"))
+		require.NoError(t, err)
+		var targetNames []string
+		require.NoError(t, json.Unmarshal(data, &targetNames))
+		return targetNames
+	}
+
+	apply(false, false)
+	require.NoError(t, fs.WriteFile("/home/user/.mixed/bar", []byte("# contents of .mixed/bar\n"), 0644))
+	require.NoError(t, fs.WriteFile("/home/user/.local/share/chezmoi/.chezmoiignore", []byte(".ignored\n.keep\n"), 0644))
+	for _, sourceName := range []string{"dot_dir", "dot_keep", "dot_mixed", "dot_removed"} {
+		require.NoError(t, fs.RemoveAll("/home/user/.local/share/chezmoi/"+sourceName))
+	}
+
+	// Without remove, no targets are removed but they are remembered.
+	apply(false, false)
+	assert.Nil(t, confirmedTargetPaths)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.removed",
+			vfst.TestContentsString("# contents of .removed\n"),
+		),
+	)
+
+	// If the removals are not confirmed, no targets are removed but they are
+	// remembered.
+	apply(true, false)
+	expectedTargetPaths := []string{
+		"/home/user/.removed",
+		"/home/user/.mixed/foo",
+		"/home/user/.dir/foo",
+		"/home/user/.dir",
+	}
+	assert.Equal(t, expectedTargetPaths, confirmedTargetPaths)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.removed",
+			vfst.TestContentsString("# contents of .removed\n"),
+		),
+	)
+	assert.Equal(t, []string{".dir", ".dir/foo", ".mixed/foo", ".removed", ".unmodified", ".unmodified/x"}, getTargetNames())
+
+	apply(true, true)
+	assert.Equal(t, expectedTargetPaths, confirmedTargetPaths)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.dir",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.ignored",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.keep",
+			vfst.TestContentsString("# contents of .keep\n"),
+		),
+		vfst.TestPath("/home/user/.mixed/bar",
+			vfst.TestContentsString("# contents of .mixed/bar\n"),
+		),
+		vfst.TestPath("/home/user/.mixed/foo",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.removed",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.unmodified/x",
+			vfst.TestContentsString("# contents of .unmodified/x\n"),
+		),
+	)
+	assert.Equal(t, []string{".unmodified", ".unmodified/x"}, getTargetNames())
+}
+
+func TestTargetStateApplyRemoveManaged(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.config/foo": "# contents of .config/foo\n",
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoiremove": ".config\n",
+			"dot_config/bar": "# contents of .config/bar\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	err = ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.TargetIgnore.Match,
+		Remove:  true,
+		Umask:   022,
+	})
+	assert.Error(t, err)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.config/foo",
+			vfst.TestContentsString("# contents of .config/foo\n"),
+		),
+	)
+}
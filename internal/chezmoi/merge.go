@@ -0,0 +1,1167 @@
+package chezmoi
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	vfs "github.com/twpayne/go-vfs"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// MergeDeleteValue is the value that deletes a key from a target when merged.
+const MergeDeleteValue = "chezmoi:delete"
+
+var tomlBareKeyRegexp = regexp.MustCompile(`\A[A-Za-z0-9_-]+\z`)
+
+// A MergeAttributes holds attributes parsed from a source merge name.
+type MergeAttributes struct {
+	Name     string
+	Template bool
+}
+
+// A Merge represents the target state of a JSON, TOML, or YAML document that
+// is merged into a file that is otherwise not managed by chezmoi. The format
+// is determined by the target name's extension.
+type Merge struct {
+	sourceName       string
+	targetName       string
+	Template         bool
+	contentsMutex    sync.Mutex
+	contents         []byte
+	contentsErr      error
+	evaluateContents func() ([]byte, error)
+}
+
+type mergeConcreteValue struct {
+	Type       string `json:"type" yaml:"type"`
+	SourcePath string `json:"sourcePath" yaml:"sourcePath"`
+	TargetPath string `json:"targetPath" yaml:"targetPath"`
+	Template   bool   `json:"template" yaml:"template"`
+	Contents   string `json:"contents" yaml:"contents"`
+}
+
+// A mergeFormat decodes and encodes documents in a format. Documents are
+// represented as yaml.MapSlices so that the order of keys is preserved. encode
+// is passed the existing contents of the target so that it can preserve its
+// style and comments.
+type mergeFormat struct {
+	decode func(data []byte) (yaml.MapSlice, error)
+	encode func(value yaml.MapSlice, prevData []byte) ([]byte, error)
+}
+
+// A mergeComment holds the comments before, on the same line as, and at the end
+// of a value in a document.
+type mergeComment struct {
+	head []string
+	line string
+	foot []string
+}
+
+// mergeComments maps the paths of values in a document to their comments.
+type mergeComments map[string]*mergeComment
+
+var mergeFormats = map[string]mergeFormat{
+	".json": {
+		decode: decodeJSONMergeDocument,
+		encode: encodeJSONMergeDocument,
+	},
+	".toml": {
+		decode: decodeTOMLMergeDocument,
+		encode: encodeTOMLMergeDocument,
+	},
+	".yaml": {
+		decode: decodeYAMLMergeDocument,
+		encode: encodeYAMLMergeDocument,
+	},
+	".yml": {
+		decode: decodeYAMLMergeDocument,
+		encode: encodeYAMLMergeDocument,
+	},
+}
+
+// ParseMergeAttributes parses a source merge name.
+func ParseMergeAttributes(sourceName string) MergeAttributes {
+	name := strings.TrimPrefix(sourceName, mergePrefix)
+	template := false
+	if strings.HasPrefix(name, dotPrefix) {
+		name = "." + strings.TrimPrefix(name, dotPrefix)
+	}
+	if strings.HasSuffix(name, TemplateSuffix) {
+		name = strings.TrimSuffix(name, TemplateSuffix)
+		template = true
+	}
+	return MergeAttributes{
+		Name:     name,
+		Template: template,
+	}
+}
+
+// SourceName returns ma's source name.
+func (ma MergeAttributes) SourceName() string {
+	sourceName := mergePrefix
+	if strings.HasPrefix(ma.Name, ".") {
+		sourceName += dotPrefix + strings.TrimPrefix(ma.Name, ".")
+	} else {
+		sourceName += ma.Name
+	}
+	if ma.Template {
+		sourceName += TemplateSuffix
+	}
+	return sourceName
+}
+
+// MergeDocuments returns the result of merging src into dst. Maps are merged
+// recursively and all other values in src replace the values in dst. Keys in
+// src whose value is MergeDeleteValue are removed from the result. The order
+// of keys in dst is preserved and new keys are appended. Neither dst nor src
+// are modified.
+func MergeDocuments(dst, src yaml.MapSlice) yaml.MapSlice {
+	result := make(yaml.MapSlice, len(dst), len(dst)+len(src))
+	copy(result, dst)
+	for _, item := range src {
+		index := -1
+		for i, resultItem := range result {
+			if resultItem.Key == item.Key {
+				index = i
+				break
+			}
+		}
+		if item.Value == MergeDeleteValue {
+			if index != -1 {
+				result = append(result[:index], result[index+1:]...)
+			}
+			continue
+		}
+		value := item.Value
+		if srcMap, ok := item.Value.(yaml.MapSlice); ok {
+			var dstMap yaml.MapSlice
+			if index != -1 {
+				dstMap, _ = result[index].Value.(yaml.MapSlice)
+			}
+			value = MergeDocuments(dstMap, srcMap)
+		}
+		if index == -1 {
+			result = append(result, yaml.MapItem{Key: item.Key, Value: value})
+		} else {
+			result[index].Value = value
+		}
+	}
+	return result
+}
+
+// AppendAllEntries appends m to allEntries.
+func (m *Merge) AppendAllEntries(allEntries []Entry) []Entry {
+	return append(allEntries, m)
+}
+
+// Apply merges m into targetPath in fs. targetPath is only written if its
+// contents change after merging, regardless of formatting.
+func (m *Merge) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
+	if applyOptions.Ignore(m.targetName) {
+		return nil
+	}
+	format, src, err := m.document()
+	if err != nil {
+		return err
+	}
+	targetPath := filepath.Join(applyOptions.DestDir, m.targetName)
+	stat := fs.Lstat
+	if follow {
+		stat = fs.Stat
+	}
+	var currData []byte
+	exists := false
+	perm := 0666 &^ applyOptions.Umask
+	switch info, err := stat(targetPath); {
+	case err == nil && info.Mode().IsRegular():
+		currData, err = fs.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		exists = true
+		perm = info.Mode().Perm()
+	case err == nil:
+		return fmt.Errorf("%s: not a regular file", targetPath)
+	case os.IsNotExist(err):
+	default:
+		return err
+	}
+	dst, err := format.decode(currData)
+	if err != nil {
+		return fmt.Errorf("%s: %w", targetPath, err)
+	}
+	merged := MergeDocuments(dst, src)
+	if (exists || len(merged) == 0) && reflect.DeepEqual(normalizeMergeValue(merged), normalizeMergeValue(dst)) {
+		return nil
+	}
+	newData, err := format.encode(merged, currData)
+	if err != nil {
+		return fmt.Errorf("%s: %w", targetPath, err)
+	}
+	return mutator.WriteFile(targetPath, newData, perm, currData)
+}
+
+// ConcreteValue implements Entry.ConcreteValue.
+func (m *Merge) ConcreteValue(ignore func(string) bool, sourceDir func(string) string, umask os.FileMode, recursive bool) (interface{}, error) {
+	if ignore(m.targetName) {
+		return nil, nil
+	}
+	contents, err := m.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return &mergeConcreteValue{
+		Type:       "merge",
+		SourcePath: filepath.Join(sourceDir(m.targetName), m.SourceName()),
+		TargetPath: m.TargetName(),
+		Template:   m.Template,
+		Contents:   string(contents),
+	}, nil
+}
+
+// Contents returns m's contents. It is safe to call Contents concurrently.
+func (m *Merge) Contents() ([]byte, error) {
+	m.contentsMutex.Lock()
+	defer m.contentsMutex.Unlock()
+	if m.evaluateContents != nil {
+		m.contents, m.contentsErr = m.evaluateContents()
+		m.evaluateContents = nil
+	}
+	return m.contents, m.contentsErr
+}
+
+// Evaluate evaluates m's contents.
+func (m *Merge) Evaluate(ignore func(string) bool) error {
+	if ignore(m.targetName) {
+		return nil
+	}
+	_, _, err := m.document()
+	return err
+}
+
+// SourceName implements Entry.SourceName.
+func (m *Merge) SourceName() string {
+	return m.sourceName
+}
+
+// TargetName implements Entry.TargetName.
+func (m *Merge) TargetName() string {
+	return m.targetName
+}
+
+// archive writes m to w as the document that m would create in a destination
+// directory that does not contain its target.
+func (m *Merge) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
+	if ignore(m.targetName) {
+		return nil
+	}
+	format, src, err := m.document()
+	if err != nil {
+		return err
+	}
+	merged := MergeDocuments(nil, src)
+	if len(merged) == 0 {
+		return nil
+	}
+	data, err := format.encode(merged, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.targetName, err)
+	}
+	header := *headerTemplate
+	header.Typeflag = tar.TypeReg
+	header.Name = m.targetName
+	header.Size = int64(len(data))
+	header.Mode = int64(0666 &^ umask)
+	if err := w.WriteHeader(&header); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// document returns m's format and its decoded contents.
+func (m *Merge) document() (mergeFormat, yaml.MapSlice, error) {
+	format, ok := mergeFormats[strings.ToLower(filepath.Ext(m.targetName))]
+	if !ok {
+		return mergeFormat{}, nil, fmt.Errorf("%s: unsupported format", m.sourceName)
+	}
+	contents, err := m.Contents()
+	if err != nil {
+		return mergeFormat{}, nil, err
+	}
+	src, err := format.decode(contents)
+	if err != nil {
+		return mergeFormat{}, nil, fmt.Errorf("%s: %w", m.sourceName, err)
+	}
+	return format, src, nil
+}
+
+// decodeJSONMergeDocument decodes a JSON object from data. Comments and
+// trailing commas are allowed, as in JSONC.
+func decodeJSONMergeDocument(data []byte) (yaml.MapSlice, error) {
+	data, _ = scanJSONCMergeDocument(data)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return yaml.MapSlice{}, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	value, err := decodeJSONMergeValue(d)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON object")
+	}
+	document, ok := value.(yaml.MapSlice)
+	if !ok {
+		return nil, errors.New("not a JSON object")
+	}
+	return document, nil
+}
+
+// decodeJSONMergeValue decodes the next JSON value from d, preserving the
+// order of keys in objects.
+func decodeJSONMergeValue(d *json.Decoder) (interface{}, error) {
+	token, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := yaml.MapSlice{}
+		for d.More() {
+			key, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONMergeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, yaml.MapItem{Key: key, Value: value})
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return object, nil
+	case json.Delim('['):
+		array := []interface{}{}
+		for d.More() {
+			value, err := decodeJSONMergeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return array, nil
+	default:
+		return token, nil
+	}
+}
+
+// scanJSONCMergeDocument returns data with its comments and trailing commas
+// replaced by spaces, so that it can be decoded as JSON, and its comments.
+// Comments are assigned to the value that follows them, or to the value that
+// precedes them on the same line for line comments, or to the end of the
+// enclosing object or array.
+func scanJSONCMergeDocument(data []byte) ([]byte, mergeComments) {
+	type frame struct {
+		path      []string
+		object    bool
+		index     int
+		expectKey bool
+	}
+	result := append([]byte{}, data...)
+	comments := make(mergeComments)
+	var stack []*frame
+	var pending []string
+	var keyPath, lastPath []string
+	sameLine := false
+	beginValue := func() []string {
+		var path []string
+		switch {
+		case len(stack) == 0:
+		case stack[len(stack)-1].object:
+			path = keyPath
+		default:
+			top := stack[len(stack)-1]
+			top.index++
+			path = appendMergePath(top.path, strconv.Itoa(top.index))
+		}
+		if len(pending) != 0 {
+			comments.at(path).head = append(comments.at(path).head, pending...)
+			pending = nil
+		}
+		return path
+	}
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '\n':
+			sameLine = false
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == ':':
+			i++
+		case c == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			j := scanJSONCComment(data, i)
+			for k := i; k < j; k++ {
+				if result[k] != '\n' {
+					result[k] = ' '
+				}
+			}
+			if comment := string(data[i:j]); sameLine && lastPath != nil && data[i+1] == '/' {
+				comments.at(lastPath).line = comment
+			} else {
+				pending = append(pending, comment)
+			}
+			i = j
+		case c == ',':
+			if j := skipJSONCSpace(data, i+1); j < len(data) && (data[j] == '}' || data[j] == ']') {
+				result[i] = ' '
+			}
+			if len(stack) != 0 && stack[len(stack)-1].object {
+				stack[len(stack)-1].expectKey = true
+			}
+			i++
+		case c == '{' || c == '[':
+			stack = append(stack, &frame{
+				path:      beginValue(),
+				object:    c == '{',
+				index:     -1,
+				expectKey: c == '{',
+			})
+			lastPath = nil
+			i++
+		case c == '}' || c == ']':
+			if len(stack) != 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if len(pending) != 0 {
+					comments.at(top.path).foot = append(comments.at(top.path).foot, pending...)
+					pending = nil
+				}
+				lastPath = top.path
+				sameLine = true
+			}
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(data) && data[j] != '"' {
+				if data[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(data) {
+				j++
+			}
+			if len(stack) != 0 && stack[len(stack)-1].expectKey {
+				top := stack[len(stack)-1]
+				top.expectKey = false
+				var key string
+				_ = json.Unmarshal(data[i:j], &key)
+				keyPath = appendMergePath(top.path, key)
+				if len(pending) != 0 {
+					comments.at(keyPath).head = append(comments.at(keyPath).head, pending...)
+					pending = nil
+				}
+			} else {
+				lastPath = beginValue()
+				sameLine = true
+			}
+			i = j
+		default:
+			j := i + 1
+			for j < len(data) && !strings.ContainsRune(" \t\r\n,:[]{}\"/", rune(data[j])) {
+				j++
+			}
+			lastPath = beginValue()
+			sameLine = true
+			i = j
+		}
+	}
+	if len(pending) != 0 {
+		comments.at(nil).foot = append(comments.at(nil).foot, pending...)
+	}
+	return result, comments
+}
+
+// scanJSONCComment returns the index of the end of the comment that starts at
+// index i in data.
+func scanJSONCComment(data []byte, i int) int {
+	if data[i+1] == '/' {
+		if j := bytes.IndexByte(data[i:], '\n'); j != -1 {
+			return i + j
+		}
+		return len(data)
+	}
+	if j := bytes.Index(data[i+2:], []byte("*/")); j != -1 {
+		return i + 2 + j + 2
+	}
+	return len(data)
+}
+
+// skipJSONCSpace returns the index of the first byte in data at or after i
+// that is not whitespace or part of a comment.
+func skipJSONCSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch {
+		case data[i] == ' ' || data[i] == '\t' || data[i] == '\r' || data[i] == '\n':
+			i++
+		case data[i] == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			i = scanJSONCComment(data, i)
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// encodeJSONMergeDocument encodes value as JSON, using the same indentation and
+// keeping the comments of the values that remain from prevData.
+func encodeJSONMergeDocument(value yaml.MapSlice, prevData []byte) ([]byte, error) {
+	indent := "  "
+	for _, line := range strings.Split(string(prevData), "\n")[1:] {
+		if trimmedLine := strings.TrimLeft(line, " \t"); trimmedLine != line && trimmedLine != "" {
+			indent = line[:len(line)-len(trimmedLine)]
+			break
+		}
+	}
+	_, comments := scanJSONCMergeDocument(prevData)
+	b := &bytes.Buffer{}
+	writeMergeCommentLines(b, comments.get(nil).head, "")
+	if err := encodeJSONMergeValue(b, comments, nil, value, "", indent); err != nil {
+		return nil, err
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// encodeJSONMergeValue writes value, whose path is path, to b as JSON with
+// comments.
+func encodeJSONMergeValue(b *bytes.Buffer, comments mergeComments, path []string, value interface{}, prefix, indent string) error {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		foot := comments.get(path).foot
+		if len(value) == 0 && len(foot) == 0 {
+			b.WriteString("{}")
+			return nil
+		}
+		b.WriteString("{\n")
+		for i, item := range value {
+			itemPath := appendMergePath(path, fmt.Sprint(item.Key))
+			comment := comments.get(itemPath)
+			writeMergeCommentLines(b, comment.head, prefix+indent)
+			b.WriteString(prefix + indent + quoteMergeString(fmt.Sprint(item.Key)) + ": ")
+			if err := encodeJSONMergeValue(b, comments, itemPath, item.Value, prefix+indent, indent); err != nil {
+				return err
+			}
+			if i != len(value)-1 {
+				b.WriteByte(',')
+			}
+			if comment.line != "" {
+				b.WriteString(" " + comment.line)
+			}
+			b.WriteByte('\n')
+		}
+		writeMergeCommentLines(b, foot, prefix+indent)
+		b.WriteString(prefix + "}")
+	case []interface{}:
+		foot := comments.get(path).foot
+		if len(value) == 0 && len(foot) == 0 {
+			b.WriteString("[]")
+			return nil
+		}
+		b.WriteString("[\n")
+		for i, element := range value {
+			elementPath := appendMergePath(path, strconv.Itoa(i))
+			comment := comments.get(elementPath)
+			writeMergeCommentLines(b, comment.head, prefix+indent)
+			b.WriteString(prefix + indent)
+			if err := encodeJSONMergeValue(b, comments, elementPath, element, prefix+indent, indent); err != nil {
+				return err
+			}
+			if i != len(value)-1 {
+				b.WriteByte(',')
+			}
+			if comment.line != "" {
+				b.WriteString(" " + comment.line)
+			}
+			b.WriteByte('\n')
+		}
+		writeMergeCommentLines(b, foot, prefix+indent)
+		b.WriteString(prefix + "]")
+	case string:
+		b.WriteString(quoteMergeString(value))
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+	}
+	return nil
+}
+
+// decodeTOMLMergeDocument decodes a TOML document from data.
+func decodeTOMLMergeDocument(data []byte) (yaml.MapSlice, error) {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return tomlTreeToMapSlice(tree), nil
+}
+
+// tomlTreeToMapSlice converts tree to a yaml.MapSlice with keys in the order
+// that they appear in the document.
+func tomlTreeToMapSlice(tree *toml.Tree) yaml.MapSlice {
+	keys := tree.Keys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		pi := tree.GetPositionPath([]string{keys[i]})
+		pj := tree.GetPositionPath([]string{keys[j]})
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		return pi.Col < pj.Col
+	})
+	document := make(yaml.MapSlice, 0, len(keys))
+	for _, key := range keys {
+		document = append(document, yaml.MapItem{
+			Key:   key,
+			Value: tomlValueToMergeValue(tree.GetPath([]string{key})),
+		})
+	}
+	return document
+}
+
+// tomlValueToMergeValue converts a value returned by toml.Tree.GetPath.
+func tomlValueToMergeValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case *toml.Tree:
+		return tomlTreeToMapSlice(value)
+	case []*toml.Tree:
+		array := make([]interface{}, len(value))
+		for i, tree := range value {
+			array[i] = tomlTreeToMapSlice(tree)
+		}
+		return array
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, element := range value {
+			array[i] = tomlValueToMergeValue(element)
+		}
+		return array
+	default:
+		return value
+	}
+}
+
+// tomlMergeComments returns the comments in the TOML document data. Comments
+// are assigned to the key or table header that follows them or that they are
+// on the same line as, or to the end of the document. go-toml does not keep
+// comments, so they are found from the positions of keys and table headers.
+func tomlMergeComments(data []byte) mergeComments {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return nil
+	}
+	paths := make(map[int][]string)
+	var walk func(*toml.Tree, []string)
+	walk = func(tree *toml.Tree, path []string) {
+		for _, key := range tree.Keys() {
+			keyPath := appendMergePath(path, key)
+			if elements, ok := tree.GetPath([]string{key}).([]*toml.Tree); ok {
+				for i, element := range elements {
+					elementPath := appendMergePath(keyPath, strconv.Itoa(i))
+					if line := element.Position().Line; line > 0 {
+						paths[line-1] = elementPath
+					}
+					walk(element, elementPath)
+				}
+				continue
+			}
+			// Set the paths of tables before the paths of their keys, so that
+			// an implicit table shares its line with its innermost table.
+			if line := tree.GetPositionPath([]string{key}).Line; line > 0 {
+				paths[line-1] = keyPath
+			}
+			if subtree, ok := tree.GetPath([]string{key}).(*toml.Tree); ok {
+				walk(subtree, keyPath)
+			}
+		}
+	}
+	walk(tree, nil)
+
+	comments := make(mergeComments)
+	lines := strings.Split(string(data), "\n")
+	prevContentLine := -1
+	for i, line := range lines {
+		if trimmedLine := strings.TrimSpace(line); trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
+			continue
+		}
+		if path, ok := paths[i]; ok {
+			comment := comments.at(path)
+			comment.head = trimMergeCommentLines(lines[prevContentLine+1 : i])
+			comment.line = tomlLineComment(line)
+		}
+		prevContentLine = i
+	}
+	if foot := trimMergeCommentLines(lines[prevContentLine+1:]); len(foot) != 0 {
+		comments.at(nil).foot = foot
+	}
+	return comments
+}
+
+// tomlLineComment returns the comment at the end of line, if any.
+func tomlLineComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return strings.TrimSpace(line[i:])
+		}
+	}
+	return ""
+}
+
+// encodeTOMLMergeDocument encodes value as TOML, keeping the comments of the
+// keys and tables that remain from prevData. go-toml does not preserve the
+// order of keys when encoding, so value is encoded directly.
+func encodeTOMLMergeDocument(value yaml.MapSlice, prevData []byte) ([]byte, error) {
+	comments := tomlMergeComments(prevData)
+	b := &bytes.Buffer{}
+	if err := encodeTOMLMergeTable(b, comments, nil, nil, value, false); err != nil {
+		return nil, err
+	}
+	writeMergeCommentLines(b, comments.get(nil).foot, "")
+	return b.Bytes(), nil
+}
+
+// encodeTOMLMergeTable writes table to b. Simple values are written first,
+// followed by tables and arrays of tables. path is the table's key and
+// commentPath is its path in comments, which also includes the indexes of
+// arrays of tables.
+func encodeTOMLMergeTable(b *bytes.Buffer, comments mergeComments, path, commentPath []string, table yaml.MapSlice, arrayElement bool) error {
+	var simpleItems, tableItems yaml.MapSlice
+	for _, item := range table {
+		if isTOMLTableValue(item.Value) {
+			tableItems = append(tableItems, item)
+		} else {
+			simpleItems = append(simpleItems, item)
+		}
+	}
+	if len(path) != 0 && (arrayElement || len(simpleItems) != 0 || len(tableItems) == 0) {
+		if b.Len() != 0 {
+			b.WriteByte('\n')
+		}
+		comment := comments.get(commentPath)
+		writeMergeCommentLines(b, comment.head, "")
+		header := encodeTOMLMergeKeys(path)
+		if arrayElement {
+			header = "[" + header + "]"
+		}
+		b.WriteString("[" + header + "]")
+		if comment.line != "" {
+			b.WriteString(" " + comment.line)
+		}
+		b.WriteByte('\n')
+	}
+	for _, item := range simpleItems {
+		comment := comments.get(appendMergePath(commentPath, fmt.Sprint(item.Key)))
+		writeMergeCommentLines(b, comment.head, "")
+		b.WriteString(encodeTOMLMergeKeys([]string{fmt.Sprint(item.Key)}) + " = ")
+		if err := encodeTOMLMergeValue(b, item.Value); err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(append(path, fmt.Sprint(item.Key)), "."), err)
+		}
+		if comment.line != "" {
+			b.WriteString(" " + comment.line)
+		}
+		b.WriteByte('\n')
+	}
+	for _, item := range tableItems {
+		itemPath := appendMergePath(path, fmt.Sprint(item.Key))
+		itemCommentPath := appendMergePath(commentPath, fmt.Sprint(item.Key))
+		switch value := item.Value.(type) {
+		case yaml.MapSlice:
+			if err := encodeTOMLMergeTable(b, comments, itemPath, itemCommentPath, value, false); err != nil {
+				return err
+			}
+		case []interface{}:
+			for i, element := range value {
+				if err := encodeTOMLMergeTable(b, comments, itemPath, appendMergePath(itemCommentPath, strconv.Itoa(i)), element.(yaml.MapSlice), true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// encodeTOMLMergeKeys returns keys as a dotted TOML key.
+func encodeTOMLMergeKeys(keys []string) string {
+	encodedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		if tomlBareKeyRegexp.MatchString(key) {
+			encodedKeys[i] = key
+		} else {
+			encodedKeys[i] = quoteMergeString(key)
+		}
+	}
+	return strings.Join(encodedKeys, ".")
+}
+
+// encodeTOMLMergeValue writes value to b as an inline TOML value.
+func encodeTOMLMergeValue(b *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		return errors.New("TOML does not support null values")
+	case bool:
+		b.WriteString(strconv.FormatBool(value))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		fmt.Fprintf(b, "%d", value)
+	case float32:
+		b.WriteString(formatTOMLFloat(float64(value)))
+	case float64:
+		b.WriteString(formatTOMLFloat(value))
+	case string:
+		b.WriteString(quoteMergeString(value))
+	case time.Time:
+		b.WriteString(value.Format(time.RFC3339Nano))
+	case fmt.Stringer:
+		// go-toml's local dates and times format themselves.
+		b.WriteString(value.String())
+	case []interface{}:
+		b.WriteByte('[')
+		for i, element := range value {
+			if i != 0 {
+				b.WriteString(", ")
+			}
+			if err := encodeTOMLMergeValue(b, element); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case yaml.MapSlice:
+		b.WriteByte('{')
+		for i, item := range value {
+			if i != 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(" " + encodeTOMLMergeKeys([]string{fmt.Sprint(item.Key)}) + " = ")
+			if err := encodeTOMLMergeValue(b, item.Value); err != nil {
+				return err
+			}
+		}
+		if len(value) != 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteByte('}')
+	default:
+		return fmt.Errorf("%v: unsupported TOML value", value)
+	}
+	return nil
+}
+
+// formatTOMLFloat returns f formatted as a TOML float.
+func formatTOMLFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// isTOMLTableValue returns true if value is encoded as a table or an array of
+// tables.
+func isTOMLTableValue(value interface{}) bool {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		return true
+	case []interface{}:
+		if len(value) == 0 {
+			return false
+		}
+		for _, element := range value {
+			if _, ok := element.(yaml.MapSlice); !ok {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// decodeYAMLMergeDocument decodes a YAML document from data.
+func decodeYAMLMergeDocument(data []byte) (yaml.MapSlice, error) {
+	document := yaml.MapSlice{}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	if err := d.Decode(&document); err != nil && err != io.EOF {
+		return nil, err
+	}
+	// Only the first document is kept when encoding, so refuse to silently
+	// drop any others that are not empty.
+	for {
+		var next interface{}
+		switch err := d.Decode(&next); {
+		case err == io.EOF:
+			return document, nil
+		case err != nil:
+			return nil, err
+		case next != nil:
+			return nil, errors.New("multiple YAML documents are not supported")
+		}
+	}
+}
+
+// encodeYAMLMergeDocument encodes value as YAML. The nodes of prevData are
+// updated with value, so that the comments and style of the values that
+// remain from prevData are kept.
+func encodeYAMLMergeDocument(value yaml.MapSlice, prevData []byte) ([]byte, error) {
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(prevData, &document); err != nil {
+		return nil, err
+	}
+	var prevNode *yamlv3.Node
+	if document.Kind == yamlv3.DocumentNode && len(document.Content) != 0 {
+		prevNode = document.Content[0]
+	} else {
+		document = yamlv3.Node{
+			Kind: yamlv3.DocumentNode,
+		}
+	}
+	node, err := updateYAMLMergeNode(prevNode, value)
+	if err != nil {
+		return nil, err
+	}
+	document.Content = []*yamlv3.Node{node}
+	b := &bytes.Buffer{}
+	e := yamlv3.NewEncoder(b)
+	e.SetIndent(2)
+	if err := e.Encode(&document); err != nil {
+		return nil, err
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// updateYAMLMergeNode returns prevNode updated with value. Maps are updated
+// recursively. Other values keep prevNode if it has the same value, otherwise
+// they are replaced by a new node with prevNode's comments.
+func updateYAMLMergeNode(prevNode *yamlv3.Node, value interface{}) (*yamlv3.Node, error) {
+	if prevNode == nil {
+		return newYAMLMergeNode(value)
+	}
+	if mapSlice, ok := value.(yaml.MapSlice); ok && prevNode.Kind == yamlv3.MappingNode {
+		node := *prevNode
+		node.Content = nil
+	ITEM:
+		for _, item := range mapSlice {
+			key := fmt.Sprint(item.Key)
+			for i := 0; i+1 < len(prevNode.Content); i += 2 {
+				if prevNode.Content[i].Value != key {
+					continue
+				}
+				valueNode, err := updateYAMLMergeNode(prevNode.Content[i+1], item.Value)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, prevNode.Content[i], valueNode)
+				continue ITEM
+			}
+			keyNode, err := newYAMLMergeNode(item.Key)
+			if err != nil {
+				return nil, err
+			}
+			valueNode, err := newYAMLMergeNode(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+		return &node, nil
+	}
+	node, err := newYAMLMergeNode(value)
+	if err != nil {
+		return nil, err
+	}
+	var prevValue, newValue interface{}
+	if prevNode.Decode(&prevValue) == nil && node.Decode(&newValue) == nil && reflect.DeepEqual(prevValue, newValue) {
+		return prevNode, nil
+	}
+	node.HeadComment = prevNode.HeadComment
+	node.LineComment = prevNode.LineComment
+	node.FootComment = prevNode.FootComment
+	return node, nil
+}
+
+// newYAMLMergeNode returns a new node for value.
+func newYAMLMergeNode(value interface{}) (*yamlv3.Node, error) {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		node := &yamlv3.Node{
+			Kind: yamlv3.MappingNode,
+			Tag:  "!!map",
+		}
+		for _, item := range value {
+			keyNode, err := newYAMLMergeNode(item.Key)
+			if err != nil {
+				return nil, err
+			}
+			valueNode, err := newYAMLMergeNode(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+		return node, nil
+	case []interface{}:
+		node := &yamlv3.Node{
+			Kind: yamlv3.SequenceNode,
+			Tag:  "!!seq",
+		}
+		for _, element := range value {
+			elementNode, err := newYAMLMergeNode(element)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, elementNode)
+		}
+		return node, nil
+	default:
+		node := &yamlv3.Node{}
+		if err := node.Encode(value); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+}
+
+// appendMergePath returns a new path with key appended to path.
+func appendMergePath(path []string, key string) []string {
+	return append(append([]string{}, path...), key)
+}
+
+// at returns the comments of the value at path, adding them if needed.
+func (mc mergeComments) at(path []string) *mergeComment {
+	key := mergeCommentsKey(path)
+	comment, ok := mc[key]
+	if !ok {
+		comment = &mergeComment{}
+		mc[key] = comment
+	}
+	return comment
+}
+
+// get returns the comments of the value at path.
+func (mc mergeComments) get(path []string) mergeComment {
+	if comment, ok := mc[mergeCommentsKey(path)]; ok {
+		return *comment
+	}
+	return mergeComment{}
+}
+
+// mergeCommentsKey returns the key of path in a mergeComments.
+func mergeCommentsKey(path []string) string {
+	key := ""
+	for _, component := range path {
+		key += "\x00" + component
+	}
+	return key
+}
+
+// trimMergeCommentLines returns the trimmed lines, without leading and
+// trailing blank lines.
+func trimMergeCommentLines(lines []string) []string {
+	var result []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" || len(result) != 0 {
+			result = append(result, line)
+		}
+	}
+	for len(result) != 0 && result[len(result)-1] == "" {
+		result = result[:len(result)-1]
+	}
+	return result
+}
+
+// writeMergeCommentLines writes the comment lines to b, each preceded by
+// prefix.
+func writeMergeCommentLines(b *bytes.Buffer, lines []string, prefix string) {
+	for _, line := range lines {
+		if line != "" {
+			b.WriteString(prefix + line)
+		}
+		b.WriteByte('\n')
+	}
+}
+
+// quoteMergeString returns s as a quoted string, which is valid in both JSON
+// and TOML.
+func quoteMergeString(s string) string {
+	b := &bytes.Buffer{}
+	e := json.NewEncoder(b)
+	e.SetEscapeHTML(false)
+	_ = e.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// normalizeMergeValue returns value with yaml.MapSlices replaced by maps, so
+// that values can be compared regardless of the order of their keys.
+func normalizeMergeValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		m := make(map[interface{}]interface{}, len(value))
+		for _, item := range value {
+			m[item.Key] = normalizeMergeValue(item.Value)
+		}
+		return m
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, element := range value {
+			array[i] = normalizeMergeValue(element)
+		}
+		return array
+	default:
+		return value
+	}
+}
@@ -0,0 +1,338 @@
+package chezmoi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMergeAttributes(t *testing.T) {
+	for sourceName, ma := range map[string]MergeAttributes{
+		"merge_settings.json":           {Name: "settings.json"},
+		"merge_dot_config.yaml":         {Name: ".config.yaml"},
+		"merge_dot_config.toml.tmpl":    {Name: ".config.toml", Template: true},
+		"merge_settings.json.tmpl.tmpl": {Name: "settings.json.tmpl", Template: true},
+	} {
+		assert.Equal(t, ma, ParseMergeAttributes(sourceName))
+		assert.Equal(t, sourceName, ma.SourceName())
+	}
+}
+
+func TestMergeDocuments(t *testing.T) {
+	dst := yaml.MapSlice{
+		{Key: "a", Value: 1},
+		{Key: "b", Value: yaml.MapSlice{
+			{Key: "c", Value: 2},
+			{Key: "d", Value: 3},
+		}},
+		{Key: "e", Value: []interface{}{4, 5}},
+		{Key: "f", Value: 6},
+	}
+	src := yaml.MapSlice{
+		{Key: "g", Value: yaml.MapSlice{
+			{Key: "h", Value: 7},
+			{Key: "i", Value: MergeDeleteValue},
+		}},
+		{Key: "b", Value: yaml.MapSlice{
+			{Key: "d", Value: MergeDeleteValue},
+			{Key: "j", Value: 8},
+		}},
+		{Key: "e", Value: []interface{}{9}},
+		{Key: "a", Value: MergeDeleteValue},
+		{Key: "k", Value: MergeDeleteValue},
+	}
+	assert.Equal(t, yaml.MapSlice{
+		{Key: "b", Value: yaml.MapSlice{
+			{Key: "c", Value: 2},
+			{Key: "j", Value: 8},
+		}},
+		{Key: "e", Value: []interface{}{9}},
+		{Key: "f", Value: 6},
+		{Key: "g", Value: yaml.MapSlice{
+			{Key: "h", Value: 7},
+		}},
+	}, MergeDocuments(dst, src))
+	assert.Equal(t, 4, len(dst))
+	assert.Equal(t, 2, len(dst[1].Value.(yaml.MapSlice)))
+}
+
+func TestMergeFormats(t *testing.T) {
+	for _, tc := range []struct {
+		ext      string
+		data     string
+		expected string
+	}{
+		{
+			ext:      ".json",
+			data:     "{\n\t\"z\": 1.50,\n\t\"a\": [true, null, \"<x>\"],\n\t\"m\": {}\n}",
+			expected: "{\n\t\"z\": 1.50,\n\t\"a\": [\n\t\ttrue,\n\t\tnull,\n\t\t\"<x>\"\n\t],\n\t\"m\": {}\n}\n",
+		},
+		{
+			ext:      ".toml",
+			data:     "z = 1\ny = 1.0\n\n[b]\nc = \"d\"\n\n[[a]]\nx = [1, 2]\n\n[[a]]\n\"key with spaces\" = { k = false }\n",
+			expected: "z = 1\ny = 1.0\n\n[b]\nc = \"d\"\n\n[[a]]\nx = [1, 2]\n\n[[a]]\n\n[a.\"key with spaces\"]\nk = false\n",
+		},
+		{
+			ext:      ".yaml",
+			data:     "z: 1\na:\n  c: d\n  b: [1, 2]\n",
+			expected: "z: 1\na:\n  c: d\n  b: [1, 2]\n",
+		},
+	} {
+		t.Run(tc.ext, func(t *testing.T) {
+			format := mergeFormats[tc.ext]
+			document, err := format.decode([]byte(tc.data))
+			require.NoError(t, err)
+			data, err := format.encode(document, []byte(tc.data))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(data))
+		})
+	}
+
+	for _, ext := range []string{".json", ".toml", ".yaml"} {
+		document, err := mergeFormats[ext].decode(nil)
+		require.NoError(t, err)
+		assert.Empty(t, document)
+	}
+	_, err := mergeFormats[".json"].decode([]byte("[]"))
+	assert.Error(t, err)
+	_, err = mergeFormats[".toml"].encode(yaml.MapSlice{{Key: "a", Value: nil}}, nil)
+	assert.Error(t, err)
+}
+
+func TestMergeFormatsComments(t *testing.T) {
+	for _, tc := range []struct {
+		ext      string
+		dst      string
+		src      string
+		expected string
+	}{
+		{
+			ext: ".json",
+			dst: strings.Join([]string{
+				"// settings",
+				"{",
+				"    // zoom",
+				"    \"window.zoomLevel\": 1, // line",
+				"    /* font */",
+				"    \"editor.fontSize\": 12,",
+				"    \"removed\": true, // removed",
+				"    \"files.exclude\": {",
+				"        \"**/.git\": true, // git",
+				"        // end of files.exclude",
+				"    },",
+				"    \"list\": [",
+				"        // first",
+				"        1,",
+				"        2,",
+				"    ],",
+				"    // end",
+				"}",
+				"",
+			}, "\n"),
+			src: `{"editor.fontSize": 14, "removed": "chezmoi:delete", "new": "value"}`,
+			expected: strings.Join([]string{
+				"// settings",
+				"{",
+				"    // zoom",
+				"    \"window.zoomLevel\": 1, // line",
+				"    /* font */",
+				"    \"editor.fontSize\": 14,",
+				"    \"files.exclude\": {",
+				"        \"**/.git\": true // git",
+				"        // end of files.exclude",
+				"    },",
+				"    \"list\": [",
+				"        // first",
+				"        1,",
+				"        2",
+				"    ],",
+				"    \"new\": \"value\"",
+				"    // end",
+				"}",
+				"",
+			}, "\n"),
+		},
+		{
+			ext: ".toml",
+			dst: strings.Join([]string{
+				"# header",
+				"",
+				"# z",
+				"z = 1 # line",
+				"removed = true # removed",
+				"",
+				"# core",
+				"[core] # core line",
+				"editor = \"vi\" # \"# not a comment\"",
+				"",
+				"# first a",
+				"[[a]]",
+				"x = \"#1\" # x",
+				"",
+				"# footer",
+				"",
+			}, "\n"),
+			src: "removed = \"chezmoi:delete\"\n[core]\neditor = \"vim\"\npager = \"less\"\n",
+			expected: strings.Join([]string{
+				"# header",
+				"",
+				"# z",
+				"z = 1 # line",
+				"",
+				"# core",
+				"[core] # core line",
+				"editor = \"vim\" # \"# not a comment\"",
+				"pager = \"less\"",
+				"",
+				"# first a",
+				"[[a]]",
+				"x = \"#1\" # x",
+				"# footer",
+				"",
+			}, "\n"),
+		},
+		{
+			ext: ".yaml",
+			dst: strings.Join([]string{
+				"# header",
+				"",
+				"# z",
+				"z: 1 # line",
+				"removed: true",
+				"core:",
+				"  # editor",
+				"  editor: vi # editor line",
+				"  list: [1, 2] # list",
+				"# footer",
+				"",
+			}, "\n"),
+			src: "removed: chezmoi:delete\ncore:\n  editor: vim\n  pager: less\n",
+			expected: strings.Join([]string{
+				"# header",
+				"",
+				"# z",
+				"z: 1 # line",
+				"core:",
+				"  # editor",
+				"  editor: vim # editor line",
+				"  list: [1, 2] # list",
+				"  pager: less",
+				"# footer",
+				"",
+			}, "\n"),
+		},
+	} {
+		t.Run(tc.ext, func(t *testing.T) {
+			format := mergeFormats[tc.ext]
+			dst, err := format.decode([]byte(tc.dst))
+			require.NoError(t, err)
+			src, err := format.decode([]byte(tc.src))
+			require.NoError(t, err)
+			data, err := format.encode(MergeDocuments(dst, src), []byte(tc.dst))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestTargetStateApplyMerge(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".config": map[string]interface{}{
+				"Code/User/settings.json": &vfst.File{
+					Perm: 0600,
+					Contents: []byte(`{
+    "window.zoomLevel": 1,
+    "editor.fontSize": 12,
+    "telemetry.enableTelemetry": true
+}
+`),
+				},
+				"app/config.yaml": "# comment\nname: app\nlist: [1, 2]\n",
+			},
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_config": map[string]interface{}{
+					"Code/User/merge_settings.json.tmpl": `{
+  "editor.fontSize": {{ 14 }},
+  "telemetry.enableTelemetry": "chezmoi:delete",
+  "files.exclude": {"**/.git": true}
+}
+`,
+					"app/merge_config.yaml": "list:\n- 1\n- 2\nname: app\n",
+					"merge_tool.toml":       "[core]\neditor = \"vim\"\n",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.TargetIgnore.Match,
+		Umask:   022,
+	}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.config/Code/User/settings.json",
+			vfst.TestModePerm(0600),
+			vfst.TestContentsString(`{
+    "window.zoomLevel": 1,
+    "editor.fontSize": 14,
+    "files.exclude": {
+        "**/.git": true
+    }
+}
+`),
+		),
+		vfst.TestPath("/home/user/.config/app/config.yaml",
+			vfst.TestContentsString("# comment\nname: app\nlist: [1, 2]\n"),
+		),
+		vfst.TestPath("/home/user/.config/tool.toml",
+			vfst.TestModePerm(0644),
+			vfst.TestContentsString("[core]\neditor = \"vim\"\n"),
+		),
+	)
+
+	assert.Equal(t, map[string]struct{}{
+		".config":           {},
+		".config/Code":      {},
+		".config/Code/User": {},
+		".config/app":       {},
+	}, ts.managedTargetNames(ts.TargetIgnore.Match))
+}
+
+func TestTargetStateApplyMergeYAMLMultipleDocuments(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			"config.yaml":                            "a: 1\n---\nb: 2\n",
+			".local/share/chezmoi/merge_config.yaml": "c: 3\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	assert.EqualError(t, ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.TargetIgnore.Match,
+		Umask:   022,
+	}), "/home/user/config.yaml: multiple YAML documents are not supported")
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/config.yaml",
+			vfst.TestContentsString("a: 1\n---\nb: 2\n"),
+		),
+	)
+}
@@ -1,13 +1,54 @@
 package chezmoi
 
 import (
+	"path/filepath"
+	"strings"
+
 	"github.com/bmatcuk/doublestar"
+	"github.com/coreos/go-semver/semver"
 )
 
+// OrderedPatternsMinVersion is the minimum version in .chezmoiversion for
+// which .chezmoiignore and .chezmoiremove patterns are evaluated in order, like
+// .gitignore patterns.
+var OrderedPatternsMinVersion = semver.Version{Major: 1, Minor: 9, Patch: 0}
+
 // An PatternSet is a set of patterns.
+//
+// By default, a name matches if it matches any include and no exclude. Once
+// Compile has been called, patterns are instead evaluated in the order in which
+// they were added, with the last matching pattern winning, as in .gitignore
+// files.
 type PatternSet struct {
 	includes map[string]struct{}
 	excludes map[string]struct{}
+	patterns []*patternSetEntry
+	matcher  *patternMatcher
+}
+
+// A patternSetEntry is a pattern in the order in which it was added.
+type patternSetEntry struct {
+	dir     string
+	pattern string
+	include bool
+}
+
+// A compiledPattern is a patternSetEntry prepared for matching.
+type compiledPattern struct {
+	index    int
+	dir      string
+	pattern  string
+	anchored bool
+	dirOnly  bool
+	include  bool
+}
+
+// A patternMatcher finds the last pattern that matches a name. Patterns
+// without wildcards are looked up directly.
+type patternMatcher struct {
+	exact map[string][]*compiledPattern // Anchored literal patterns by path.
+	base  map[string][]*compiledPattern // Unanchored literal patterns by base name.
+	globs []*compiledPattern
 }
 
 // NewPatternSet returns a new PatternSet.
@@ -20,28 +61,166 @@ func NewPatternSet() *PatternSet {
 
 // Add adds a pattern to ps.
 func (ps *PatternSet) Add(pattern string, include bool) error {
-	if _, err := doublestar.PathMatch(pattern, ""); err != nil {
+	return ps.AddRelative(".", pattern, include)
+}
+
+// AddRelative adds a pattern that is relative to dir to ps. If ps is compiled,
+// patterns that contain a slash, other than a trailing slash, only match
+// relative to dir, other patterns match at any depth below dir, and patterns
+// with a trailing slash only match directories.
+func (ps *PatternSet) AddRelative(dir, pattern string, include bool) error {
+	joinedPattern := filepath.Join(dir, pattern)
+	if _, err := doublestar.PathMatch(joinedPattern, ""); err != nil {
 		return nil
 	}
 	if include {
-		ps.includes[pattern] = struct{}{}
+		ps.includes[joinedPattern] = struct{}{}
 	} else {
-		ps.excludes[pattern] = struct{}{}
+		ps.excludes[joinedPattern] = struct{}{}
 	}
+	ps.patterns = append(ps.patterns, &patternSetEntry{
+		dir:     dir,
+		pattern: pattern,
+		include: include,
+	})
 	return nil
 }
 
-// Match returns if name matches any pattern in ps.
+// Compile switches ps to ordered matching.
+func (ps *PatternSet) Compile() {
+	matcher := &patternMatcher{
+		exact: make(map[string][]*compiledPattern),
+		base:  make(map[string][]*compiledPattern),
+	}
+	for index, entry := range ps.patterns {
+		pattern := filepath.ToSlash(entry.pattern)
+		cp := &compiledPattern{
+			index:   index,
+			dir:     entry.dir,
+			dirOnly: strings.HasSuffix(pattern, "/"),
+			include: entry.include,
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+		cp.anchored = strings.Contains(pattern, "/")
+		pattern = filepath.FromSlash(strings.TrimPrefix(pattern, "/"))
+		literal := !strings.ContainsAny(pattern, `*?[{\`)
+		switch {
+		case cp.anchored && literal:
+			cp.pattern = filepath.Join(entry.dir, pattern)
+			matcher.exact[cp.pattern] = append(matcher.exact[cp.pattern], cp)
+		case literal:
+			cp.pattern = pattern
+			matcher.base[pattern] = append(matcher.base[pattern], cp)
+		case cp.anchored:
+			cp.pattern = filepath.Join(entry.dir, pattern)
+			matcher.globs = append(matcher.globs, cp)
+		default:
+			cp.pattern = filepath.Join(entry.dir, "**", pattern)
+			matcher.globs = append(matcher.globs, cp)
+		}
+	}
+	ps.matcher = matcher
+}
+
+// Globs returns glob patterns that match everything that ps includes.
+func (ps *PatternSet) Globs() []string {
+	var globs []string
+	if ps.matcher == nil {
+		for include := range ps.includes {
+			globs = append(globs, include)
+		}
+		return globs
+	}
+	for _, cp := range ps.matcher.globs {
+		if cp.include {
+			globs = append(globs, cp.pattern)
+		}
+	}
+	for _, cps := range ps.matcher.exact {
+		for _, cp := range cps {
+			if cp.include {
+				globs = append(globs, cp.pattern)
+			}
+		}
+	}
+	for _, cps := range ps.matcher.base {
+		for _, cp := range cps {
+			if cp.include {
+				globs = append(globs, filepath.Join(cp.dir, "**", cp.pattern))
+			}
+		}
+	}
+	return globs
+}
+
+// Match returns if name, which is not a directory, matches ps.
 func (ps *PatternSet) Match(name string) bool {
-	for pattern := range ps.excludes {
-		if ok, _ := doublestar.PathMatch(pattern, name); ok {
-			return false
+	return ps.match(name, false)
+}
+
+// MatchDir returns if name, which is a directory, matches ps.
+func (ps *PatternSet) MatchDir(name string) bool {
+	return ps.match(name, true)
+}
+
+func (ps *PatternSet) match(name string, isDir bool) bool {
+	if ps.matcher == nil {
+		for pattern := range ps.excludes {
+			if ok, _ := doublestar.PathMatch(pattern, name); ok {
+				return false
+			}
+		}
+		for pattern := range ps.includes {
+			if ok, _ := doublestar.PathMatch(pattern, name); ok {
+				return true
+			}
 		}
+		return false
 	}
-	for pattern := range ps.includes {
-		if ok, _ := doublestar.PathMatch(pattern, name); ok {
+
+	// As in .gitignore files, nothing in a matching directory can be excluded.
+	for i := 0; i < len(name); i++ {
+		if name[i] == filepath.Separator && ps.matcher.match(name[:i], true) {
 			return true
 		}
 	}
-	return false
+	return ps.matcher.match(name, isDir)
+}
+
+// match returns whether the last pattern in m that matches name is an include.
+func (m *patternMatcher) match(name string, isDir bool) bool {
+	var last *compiledPattern
+	consider := func(cp *compiledPattern) {
+		if cp.dirOnly && !isDir {
+			return
+		}
+		if last != nil && cp.index < last.index {
+			return
+		}
+		last = cp
+	}
+	for _, cp := range m.exact[name] {
+		consider(cp)
+	}
+	for _, cp := range m.base[filepath.Base(name)] {
+		if cp.dir == "." || strings.HasPrefix(name, cp.dir+string(filepath.Separator)) {
+			consider(cp)
+		}
+	}
+	// Only globs added after the last matching literal can change the result,
+	// so search them from the end.
+	for i := len(m.globs) - 1; i >= 0; i-- {
+		cp := m.globs[i]
+		if last != nil && cp.index < last.index {
+			break
+		}
+		if cp.dirOnly && !isDir {
+			continue
+		}
+		if ok, _ := doublestar.PathMatch(cp.pattern, name); ok {
+			consider(cp)
+			break
+		}
+	}
+	return last != nil && last.include
 }
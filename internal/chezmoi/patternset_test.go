@@ -74,6 +74,107 @@ func TestPatternSet(t *testing.T) {
 	}
 }
 
+func TestPatternSetOrdered(t *testing.T) {
+	type pattern struct {
+		dir     string
+		pattern string
+		include bool
+	}
+	for _, tc := range []struct {
+		name             string
+		patterns         []pattern
+		expectMatches    map[string]bool
+		expectDirMatches map[string]bool
+	}{
+		{
+			name: "last_match_wins",
+			patterns: []pattern{
+				{".", ".config/*", true},
+				{".", ".config/foo", false},
+				{".", ".config/foo/cache", true},
+			},
+			expectMatches: map[string]bool{
+				".config":                                      false,
+				filepath.Join(".config", "bar"):                true,
+				filepath.Join(".config", "bar", "baz"):         true,
+				filepath.Join(".config", "foo"):                false,
+				filepath.Join(".config", "foo", "config"):      false,
+				filepath.Join(".config", "foo", "cache"):       true,
+				filepath.Join(".config", "foo", "cache", "db"): true,
+			},
+		},
+		{
+			name: "include_after_exclude",
+			patterns: []pattern{
+				{".", "foo", false},
+				{".", "f*", true},
+			},
+			expectMatches: map[string]bool{
+				"foo": true,
+			},
+		},
+		{
+			name: "unanchored",
+			patterns: []pattern{
+				{".", "*.txt", true},
+				{".", "README", true},
+				{"dir", "foo", true},
+			},
+			expectMatches: map[string]bool{
+				"a.txt":                           true,
+				filepath.Join("b", "a.txt"):       true,
+				"README":                          true,
+				filepath.Join("b", "c", "README"): true,
+				"foo":                             false,
+				filepath.Join("dir", "foo"):       true,
+				filepath.Join("dir", "b", "foo"):  true,
+			},
+		},
+		{
+			name: "anchored",
+			patterns: []pattern{
+				{".", "/README", true},
+				{".", "b/*.txt", true},
+			},
+			expectMatches: map[string]bool{
+				"README":                         true,
+				filepath.Join("b", "README"):     false,
+				filepath.Join("b", "a.txt"):      true,
+				filepath.Join("c", "b", "a.txt"): false,
+			},
+		},
+		{
+			name: "dir_only",
+			patterns: []pattern{
+				{".", "cache/", true},
+			},
+			expectMatches: map[string]bool{
+				"cache":                          false,
+				filepath.Join("cache", "foo"):    true,
+				filepath.Join("a", "cache", "b"): true,
+			},
+			expectDirMatches: map[string]bool{
+				"cache":                     true,
+				filepath.Join("a", "cache"): true,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ps := NewPatternSet()
+			for _, p := range tc.patterns {
+				require.NoError(t, ps.AddRelative(p.dir, p.pattern, p.include))
+			}
+			ps.Compile()
+			for name, expectMatch := range tc.expectMatches {
+				assert.Equal(t, expectMatch, ps.Match(name), name)
+			}
+			for name, expectMatch := range tc.expectDirMatches {
+				assert.Equal(t, expectMatch, ps.MatchDir(name), name)
+			}
+		})
+	}
+}
+
 func mustNewPatternSet(t *testing.T, patterns map[string]bool) *PatternSet {
 	ps := NewPatternSet()
 	for pattern, exclude := range patterns {
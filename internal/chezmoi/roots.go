@@ -0,0 +1,131 @@
+package chezmoi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// AllTargetStates returns ts followed by its roots, in order of name.
+func (ts *TargetState) AllTargetStates() []*TargetState {
+	targetStates := []*TargetState{ts}
+	for _, name := range ts.rootNames() {
+		targetStates = append(targetStates, ts.Roots[name])
+	}
+	return targetStates
+}
+
+// ApplyEntry applies entry, which is in ts or one of its roots, to the
+// destination directory that it belongs to.
+func (ts *TargetState) ApplyEntry(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions, entry Entry) error {
+	root := ts.EntryRoot(entry)
+	if root == ts {
+		return entry.Apply(fs, mutator, follow, applyOptions)
+	}
+	rootApplyOptions := root.rootApplyOptions(applyOptions)
+	err := entry.Apply(fs, mutator, follow, rootApplyOptions)
+	applyOptions.Errors = append(applyOptions.Errors, rootApplyOptions.Errors...)
+	return err
+}
+
+// EntryRoot returns the target state, ts or one of its roots, that contains
+// entry.
+func (ts *TargetState) EntryRoot(entry Entry) *TargetState {
+	for _, name := range ts.rootNames() {
+		root := ts.Roots[name]
+		if rootEntry, err := root.findEntry(entry.TargetName()); err == nil && rootEntry == entry {
+			return root
+		}
+	}
+	return ts
+}
+
+// Root returns the target state, ts or one of its roots, whose destination
+// directory contains targetPath. If several roots contain targetPath then the
+// one with the longest destination directory is returned.
+func (ts *TargetState) Root(fs vfs.Stater, targetPath string) (*TargetState, error) {
+	var root *TargetState
+	for _, r := range ts.AllTargetStates() {
+		contains, err := vfs.Contains(fs, targetPath, r.DestDir)
+		switch {
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		if contains && (root == nil || len(r.DestDir) > len(root.DestDir)) {
+			root = r
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("%s: outside target directory", targetPath)
+	}
+	return root, nil
+}
+
+// TargetPath returns the path of entry in the destination directory that it
+// belongs to.
+func (ts *TargetState) TargetPath(entry Entry) string {
+	return filepath.Join(ts.EntryRoot(entry).DestDir, entry.TargetName())
+}
+
+// applyRoots applies all of ts's roots.
+func (ts *TargetState) applyRoots(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
+	for _, name := range ts.rootNames() {
+		root := ts.Roots[name]
+		rootApplyOptions := root.rootApplyOptions(applyOptions)
+		if err := root.Apply(fs, mutator, follow, rootApplyOptions); err != nil && len(rootApplyOptions.Errors) == 0 {
+			return err
+		}
+		applyOptions.Errors = append(applyOptions.Errors, rootApplyOptions.Errors...)
+	}
+	return nil
+}
+
+// populateRoots populates all of ts's roots. Roots share ts's templates and
+// minimum version.
+func (ts *TargetState) populateRoots(fs vfs.FS, options *PopulateOptions) error {
+	for _, name := range ts.rootNames() {
+		root := ts.Roots[name]
+		if len(ts.Templates) != 0 {
+			if root.Templates == nil {
+				root.Templates = make(map[string]*template.Template)
+			}
+			for templateName, tmpl := range ts.Templates {
+				root.Templates[templateName] = tmpl
+			}
+		}
+		if root.MinVersion == nil {
+			root.MinVersion = ts.MinVersion
+		}
+		if err := root.Populate(fs, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rootApplyOptions returns a copy of applyOptions for applying ts as a root.
+func (ts *TargetState) rootApplyOptions(applyOptions *ApplyOptions) *ApplyOptions {
+	rootApplyOptions := *applyOptions
+	rootApplyOptions.DestDir = ts.DestDir
+	rootApplyOptions.Errors = nil
+	rootApplyOptions.Ignore = ts.Ignore
+	rootApplyOptions.SourceDir = ts.EntrySourceDir
+	rootApplyOptions.Umask = ts.Umask
+	return &rootApplyOptions
+}
+
+// rootNames returns the names of ts's roots in order.
+func (ts *TargetState) rootNames() []string {
+	rootNames := make([]string, 0, len(ts.Roots))
+	for name := range ts.Roots {
+		rootNames = append(rootNames, name)
+	}
+	sort.Strings(rootNames)
+	return rootNames
+}
@@ -0,0 +1,126 @@
+package chezmoi
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestTargetStateRoots(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/etc":           &vfst.Dir{Perm: 0755},
+		"/home/user":     &vfst.Dir{Perm: 0755},
+		"/usr/local/bin": map[string]interface{}{"tool": &vfst.File{Perm: 0755, Contents: []byte("#!/bin/sh\n")}},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoitemplates/hostname": "example",
+			"dot_bashrc":                 "# contents of .bashrc\n",
+			"system/etc/hosts.tmpl":      "127.0.0.1 {{ template \"hostname\" }}\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	newTargetState := func() *TargetState {
+		ts := NewTargetState(
+			WithDestDir("/home/user"),
+			WithRoots(map[string]*TargetState{
+				"system": NewTargetState(
+					WithDestDir("/"),
+					WithSourceDir("/home/user/.local/share/chezmoi/system"),
+					WithUmask(022),
+				),
+			}),
+			WithSourceDir("/home/user/.local/share/chezmoi"),
+			WithUmask(077),
+		)
+		require.NoError(t, ts.Populate(fs, nil))
+		return ts
+	}
+
+	ts := newTargetState()
+	assert.NotContains(t, ts.Entries, "system")
+	hosts, err := ts.Get(fs, "/etc/hosts")
+	require.NoError(t, err)
+	assert.Equal(t, ts.Roots["system"], ts.EntryRoot(hosts))
+	assert.Equal(t, "/etc/hosts", ts.TargetPath(hosts))
+	assert.Equal(t, "/home/user/.local/share/chezmoi/system/etc/hosts.tmpl", ts.SourcePath(hosts))
+	bashrc, err := ts.Get(fs, "/home/user/.bashrc")
+	require.NoError(t, err)
+	assert.Equal(t, ts, ts.EntryRoot(bashrc))
+	assert.Equal(t, "/home/user/.bashrc", ts.TargetPath(bashrc))
+
+	require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.Ignore,
+		Umask:   ts.Umask,
+	}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/etc/hosts",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0644),
+			vfst.TestContentsString("127.0.0.1 example\n"),
+		),
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0600),
+			vfst.TestContentsString("# contents of .bashrc\n"),
+		),
+	)
+
+	require.NoError(t, ts.Add(fs, AddOptions{}, "/usr/local/bin/tool", nil, false, NewFSMutator(fs)))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi/system/usr/local/bin/executable_tool",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("#!/bin/sh\n"),
+		),
+	)
+
+	_, err = newTargetState().Get(fs, "/nonexistent/file")
+	assert.True(t, os.IsNotExist(err))
+	_, err = NewTargetState(WithDestDir("/home/user")).Get(fs, "/etc/hosts")
+	assert.Error(t, err)
+	assert.False(t, os.IsNotExist(err))
+}
+
+func TestTargetStateRootNested(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/work": &vfst.Dir{Perm: 0755},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc":         "# contents of .bashrc\n",
+			"work/dot_gitconfig": "# contents of work/.gitconfig\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithRoots(map[string]*TargetState{
+			"work": NewTargetState(
+				WithDestDir("/home/user/work"),
+				WithSourceDir("/home/user/.local/share/chezmoi/work"),
+			),
+		}),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+
+	for targetPath, expectedRoot := range map[string]*TargetState{
+		"/home/user/.bashrc":         ts,
+		"/home/user/work":            ts.Roots["work"],
+		"/home/user/work/.gitconfig": ts.Roots["work"],
+	} {
+		root, err := ts.Root(fs, targetPath)
+		require.NoError(t, err)
+		assert.Equal(t, expectedRoot.DestDir, root.DestDir, targetPath)
+	}
+	gitconfig, err := ts.Get(fs, "/home/user/work/.gitconfig")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/user/work/.gitconfig", ts.TargetPath(gitconfig))
+
+	_, err = ts.Root(fs, "/etc/hosts")
+	assert.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	vfs "github.com/twpayne/go-vfs"
@@ -38,6 +39,7 @@ type Script struct {
 	targetName       string
 	Once             bool
 	Template         bool
+	contentsMutex    sync.Mutex
 	contents         []byte
 	contentsErr      error
 	evaluateContents func() ([]byte, error)
@@ -152,7 +154,21 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
 	c.Stdin = os.Stdin
-	if err := c.Run(); err != nil {
+	stopTiming := applyOptions.Timings.Start("script", s.targetName)
+	err = c.Run()
+	stopTiming()
+	entry := &AuditLogEntry{
+		Operation: "script",
+		Target:    filepath.Join(applyOptions.DestDir, s.targetName),
+		NewSHA256: sha256Sum(contents),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if recordErr := applyOptions.AuditLog.Record(entry); err == nil {
+		err = recordErr
+	}
+	if err != nil {
 		return err
 	}
 
@@ -174,7 +190,7 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir func(string) string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(s.targetName) {
 		return nil, nil
 	}
@@ -184,7 +200,7 @@ func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir string, umask
 	}
 	return &scriptConcreteValue{
 		Type:       "script",
-		SourcePath: filepath.Join(sourceDir, s.SourceName()),
+		SourcePath: filepath.Join(sourceDir(s.targetName), s.SourceName()),
 		TargetPath: s.TargetName(),
 		Once:       s.Once,
 		Template:   s.Template,
@@ -192,8 +208,10 @@ func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir string, umask
 	}, nil
 }
 
-// Contents returns s's contents.
+// Contents returns s's contents. It is safe to call Contents concurrently.
 func (s *Script) Contents() ([]byte, error) {
+	s.contentsMutex.Lock()
+	defer s.contentsMutex.Unlock()
 	if s.evaluateContents != nil {
 		s.contents, s.contentsErr = s.evaluateContents()
 		s.evaluateContents = nil
@@ -0,0 +1,196 @@
+package chezmoi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// EntrySourceDir returns the source directory that the entry for targetName
+// comes from.
+func (ts *TargetState) EntrySourceDir(targetName string) string {
+	if sourceDir, ok := ts.entrySourceDirs[targetName]; ok {
+		return sourceDir
+	}
+	return ts.SourceDir
+}
+
+// SourcePath returns the path of entry, which is in ts or one of its roots, in
+// the source directory that it comes from.
+func (ts *TargetState) SourcePath(entry Entry) string {
+	root := ts.EntryRoot(entry)
+	return filepath.Join(root.EntrySourceDir(entry.TargetName()), entry.SourceName())
+}
+
+// CopyToSourceDir copies entry to ts.SourceDir, if it comes from another source
+// directory, so that it can be modified there. Only the directory itself is
+// copied for directories, not their entries. The copy is placed in the
+// directory in ts.SourceDir with the same target name, if there is one, and
+// its source name includes the attributes that the other source directory's
+// attribute manifest assigns to entry.
+func (ts *TargetState) CopyToSourceDir(fs vfs.FS, entry Entry, mutator Mutator) error {
+	if root := ts.EntryRoot(entry); root != ts {
+		return root.CopyToSourceDir(fs, entry, mutator)
+	}
+	if dir, ok := entry.(*Dir); ok {
+		return ts.copyDirToSourceDir(fs, dir, mutator)
+	}
+	sourceDir := ts.EntrySourceDir(entry.TargetName())
+	if sourceDir == ts.SourceDir {
+		return nil
+	}
+	var sourceName *string
+	var baseName string
+	name := filepath.Base(entry.TargetName())
+	switch entry := entry.(type) {
+	case *Block:
+		sourceName = &entry.sourceName
+		baseName = BlockAttributes{
+			Name:     name,
+			Template: entry.Template,
+		}.SourceName()
+	case *File:
+		sourceName = &entry.sourceName
+		baseName = FileAttributes{
+			Name:      name,
+			Mode:      entry.Perm,
+			Empty:     entry.Empty,
+			Encrypted: entry.Encrypted,
+			Template:  entry.Template,
+		}.SourceName()
+	case *Merge:
+		sourceName = &entry.sourceName
+		baseName = MergeAttributes{
+			Name:     name,
+			Template: entry.Template,
+		}.SourceName()
+	case *Script:
+		sourceName = &entry.sourceName
+		baseName = ScriptAttributes{
+			Name:     name,
+			Once:     entry.Once,
+			Template: entry.Template,
+		}.SourceName()
+	case *Symlink:
+		sourceName = &entry.sourceName
+		baseName = FileAttributes{
+			Name:     name,
+			Mode:     os.ModeSymlink,
+			Template: entry.Template,
+		}.SourceName()
+	default:
+		return fmt.Errorf("%s: unsupported entry type", entry.TargetName())
+	}
+	data, err := fs.ReadFile(filepath.Join(sourceDir, entry.SourceName()))
+	if err != nil {
+		return err
+	}
+	newSourceName := baseName
+	if parentDirName := filepath.Dir(entry.TargetName()); parentDirName != "." {
+		parentEntry, err := ts.findEntry(parentDirName)
+		if err != nil {
+			return err
+		}
+		parentDir, ok := parentEntry.(*Dir)
+		if !ok {
+			return fmt.Errorf("%s: not a directory", parentDirName)
+		}
+		if err := ts.copyDirToSourceDir(fs, parentDir, mutator); err != nil {
+			return err
+		}
+		newSourceName = filepath.Join(parentDir.sourceName, baseName)
+	}
+	if err := mutator.WriteFile(filepath.Join(ts.SourceDir, newSourceName), data, 0666&^ts.Umask, nil); err != nil {
+		return err
+	}
+	*sourceName = newSourceName
+	ts.setEntrySourceDir(entry.TargetName(), ts.SourceDir)
+	return nil
+}
+
+// copyDirToSourceDir creates dir, and its parent directories, in ts.SourceDir
+// if it comes from another source directory. If ts.SourceDir already contains
+// a directory with the same target name, possibly with different attributes,
+// then that directory is used.
+func (ts *TargetState) copyDirToSourceDir(fs vfs.FS, dir *Dir, mutator Mutator) error {
+	if ts.EntrySourceDir(dir.targetName) == ts.SourceDir {
+		return nil
+	}
+	parentDirSourceName := ""
+	if parentDirName := filepath.Dir(dir.targetName); parentDirName != "." {
+		parentEntry, err := ts.findEntry(parentDirName)
+		if err != nil {
+			return err
+		}
+		parentDir, ok := parentEntry.(*Dir)
+		if !ok {
+			return fmt.Errorf("%s: not a directory", parentDirName)
+		}
+		if err := ts.copyDirToSourceDir(fs, parentDir, mutator); err != nil {
+			return err
+		}
+		parentDirSourceName = parentDir.sourceName
+	}
+	infos, err := fs.ReadDir(filepath.Join(ts.SourceDir, parentDirSourceName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	sourceName := ""
+	for _, info := range infos {
+		if info.IsDir() && !strings.HasPrefix(info.Name(), ".") && ParseDirAttributes(info.Name()).Name == filepath.Base(dir.targetName) {
+			sourceName = filepath.Join(parentDirSourceName, info.Name())
+			break
+		}
+	}
+	if sourceName == "" {
+		sourceName = filepath.Join(parentDirSourceName, DirAttributes{
+			Name:  filepath.Base(dir.targetName),
+			Exact: dir.Exact,
+			Perm:  dir.Perm,
+		}.SourceName())
+		if err := vfs.MkdirAll(mutator, filepath.Join(ts.SourceDir, sourceName), 0777&^ts.Umask); err != nil {
+			return err
+		}
+	}
+	dir.sourceName = sourceName
+	ts.setEntrySourceDir(dir.targetName, ts.SourceDir)
+	return nil
+}
+
+// setEntry sets entries[name] to entry, which comes from sourceDir, replacing
+// any existing entry and everything in it.
+func (ts *TargetState) setEntry(entries map[string]Entry, name string, entry Entry, sourceDir string) {
+	if _, ok := entries[name].(*Dir); ok {
+		prefix := entry.TargetName() + string(filepath.Separator)
+		for targetName := range ts.entrySourceDirs {
+			if strings.HasPrefix(targetName, prefix) {
+				delete(ts.entrySourceDirs, targetName)
+			}
+		}
+	}
+	entries[name] = entry
+	ts.setEntrySourceDir(entry.TargetName(), sourceDir)
+}
+
+func (ts *TargetState) setEntrySourceDir(targetName, sourceDir string) {
+	if sourceDir == ts.SourceDir {
+		delete(ts.entrySourceDirs, targetName)
+		return
+	}
+	if ts.entrySourceDirs == nil {
+		ts.entrySourceDirs = make(map[string]string)
+	}
+	ts.entrySourceDirs[targetName] = sourceDir
+}
+
+// sourceDirs returns ts's source directories, from lowest to highest
+// precedence.
+func (ts *TargetState) sourceDirs() []string {
+	if len(ts.SourceDirs) == 0 {
+		return []string{ts.SourceDir}
+	}
+	return ts.SourceDirs
+}
@@ -0,0 +1,190 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestTargetStatePopulateSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi-team": map[string]interface{}{
+			".chezmoiignore":              "team-ignored\npersonal-ignored\n",
+			".chezmoitemplates/greeting":  "hello from team",
+			".chezmoitemplates/signature": "team",
+			".chezmoiversion":             "1.9.0\n",
+			"dot_bashrc":                  "# team .bashrc\n",
+			"dot_config/team":             "# team .config/team\n",
+			"dot_gitconfig.tmpl":          "{{ template \"greeting\" }}\n",
+			"team-ignored":                "# team-ignored\n",
+			"personal-ignored":            "# personal-ignored\n",
+		},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoiignore":             "!personal-ignored\n",
+			".chezmoitemplates/greeting": "hello from personal",
+			"dot_bashrc":                 "# personal .bashrc\n",
+			"private_dot_config/mine":    "# personal .config/mine\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDirs([]string{
+			"/home/user/.local/share/chezmoi-team",
+			"/home/user/.local/share/chezmoi",
+		}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	assert.Equal(t, "/home/user/.local/share/chezmoi", ts.SourceDir)
+
+	for targetName, expectedSourceDir := range map[string]string{
+		".bashrc":      "/home/user/.local/share/chezmoi",
+		".config":      "/home/user/.local/share/chezmoi",
+		".config/mine": "/home/user/.local/share/chezmoi",
+		".config/team": "/home/user/.local/share/chezmoi-team",
+		".gitconfig":   "/home/user/.local/share/chezmoi-team",
+		"team-ignored": "/home/user/.local/share/chezmoi-team",
+		"nonexistent":  "/home/user/.local/share/chezmoi",
+	} {
+		assert.Equal(t, expectedSourceDir, ts.EntrySourceDir(targetName), targetName)
+	}
+
+	bashrc, err := ts.findEntry(".bashrc")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/user/.local/share/chezmoi/dot_bashrc", ts.SourcePath(bashrc))
+	contents, err := bashrc.(*File).Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "# personal .bashrc\n", string(contents))
+
+	config, err := ts.findEntry(".config")
+	require.NoError(t, err)
+	assert.Equal(t, "private_dot_config", config.SourceName())
+	assert.True(t, config.(*Dir).Private())
+	assert.Len(t, config.(*Dir).Entries, 2)
+
+	gitconfig, err := ts.findEntry(".gitconfig")
+	require.NoError(t, err)
+	contents, err = gitconfig.(*File).Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "hello from personal\n", string(contents))
+
+	assert.True(t, ts.Ignore("team-ignored"))
+	assert.False(t, ts.Ignore("personal-ignored"))
+}
+
+func TestTargetStateAddSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc":       "# modified .bashrc\n",
+			".config/team":  "# team .config/team\n",
+			".config/added": "# added .config/added\n",
+		},
+		"/home/user/.local/share/chezmoi-team": map[string]interface{}{
+			"dot_bashrc":      "# team .bashrc\n",
+			"dot_config/team": "# team .config/team\n",
+		},
+		"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDirs([]string{
+			"/home/user/.local/share/chezmoi-team",
+			"/home/user/.local/share/chezmoi",
+		}),
+		WithUmask(022),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	mutator := NewFSMutator(fs)
+	for _, targetPath := range []string{
+		"/home/user/.bashrc",
+		"/home/user/.config/added",
+	} {
+		require.NoError(t, ts.Add(fs, AddOptions{}, targetPath, nil, false, mutator))
+	}
+	config, err := ts.findEntry(".config")
+	require.NoError(t, err)
+	require.NoError(t, ts.CopyToSourceDir(fs, config.(*Dir).Entries["team"], mutator))
+
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi-team/dot_bashrc",
+			vfst.TestContentsString("# team .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi-team/dot_config/added",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_bashrc",
+			vfst.TestContentsString("# modified .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_config/added",
+			vfst.TestContentsString("# added .config/added\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_config/team",
+			vfst.TestContentsString("# team .config/team\n"),
+		),
+	)
+	for _, targetName := range []string{".bashrc", ".config", ".config/added", ".config/team"} {
+		assert.Equal(t, "/home/user/.local/share/chezmoi", ts.EntrySourceDir(targetName), targetName)
+	}
+}
+
+func TestTargetStateCopyToSourceDir(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi-team": map[string]interface{}{
+			".chezmoiattributes":     "team executable\nrun_setup.sh once\n",
+			"dot_config/nested/file": "# team .config/nested/file\n",
+			"dot_config/team":        "# team .config/team\n",
+			"run_setup.sh":           "#!/bin/sh\n",
+		},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"private_dot_config/mine": "# personal .config/mine\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDirs([]string{
+			"/home/user/.local/share/chezmoi-team",
+			"/home/user/.local/share/chezmoi",
+		}),
+		WithUmask(022),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	mutator := NewFSMutator(fs)
+	for _, targetName := range []string{".config/nested/file", ".config/team", "setup.sh"} {
+		entry, err := ts.findEntry(targetName)
+		require.NoError(t, err)
+		require.NoError(t, ts.CopyToSourceDir(fs, entry, mutator))
+		assert.Equal(t, "/home/user/.local/share/chezmoi", ts.EntrySourceDir(targetName), targetName)
+	}
+
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_config",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/private_dot_config/executable_team",
+			vfst.TestContentsString("# team .config/team\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/private_dot_config/nested/file",
+			vfst.TestContentsString("# team .config/nested/file\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/run_once_setup.sh",
+			vfst.TestContentsString("#!/bin/sh\n"),
+		),
+	)
+
+	team, err := ts.findEntry(".config/team")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/user/.local/share/chezmoi/private_dot_config/executable_team", ts.SourcePath(team))
+	nested, err := ts.findEntry(".config/nested")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/user/.local/share/chezmoi/private_dot_config/nested", ts.SourcePath(nested))
+}
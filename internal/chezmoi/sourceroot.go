@@ -0,0 +1,29 @@
+package chezmoi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// SourceRootDir returns the directory that contains the source state in
+// sourceDir. This is the subdirectory named in sourceDir's .chezmoiroot file,
+// if it exists, or sourceDir itself.
+func SourceRootDir(fs vfs.FS, sourceDir string) (string, error) {
+	path := filepath.Join(sourceDir, rootName)
+	data, err := fs.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return sourceDir, nil
+	case err != nil:
+		return "", err
+	}
+	root := filepath.Clean(filepath.FromSlash(strings.TrimSpace(string(data))))
+	if filepath.IsAbs(root) || root == ".." || strings.HasPrefix(root, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: %s: outside source directory", path, root)
+	}
+	return filepath.Join(sourceDir, root), nil
+}
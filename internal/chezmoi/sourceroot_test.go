@@ -0,0 +1,68 @@
+package chezmoi
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestSourceRootDir(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		root        interface{}
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name:     "no_root",
+			expected: "/home/user/.local/share/chezmoi",
+		},
+		{
+			name:     "subdir",
+			root:     "home\n",
+			expected: "/home/user/.local/share/chezmoi/home",
+		},
+		{
+			name:     "nested_subdir",
+			root:     "dotfiles/home/",
+			expected: "/home/user/.local/share/chezmoi/dotfiles/home",
+		},
+		{
+			name:     "dot",
+			root:     ".",
+			expected: "/home/user/.local/share/chezmoi",
+		},
+		{
+			name:        "absolute",
+			root:        "/home/user",
+			expectedErr: true,
+		},
+		{
+			name:        "parent",
+			root:        "../other",
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := map[string]interface{}{
+				"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+			}
+			if tc.root != nil {
+				root["/home/user/.local/share/chezmoi/.chezmoiroot"] = tc.root
+			}
+			fs, cleanup, err := vfst.NewTestFS(root)
+			require.NoError(t, err)
+			defer cleanup()
+			actual, err := SourceRootDir(fs, "/home/user/.local/share/chezmoi")
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.FromSlash(tc.expected), actual)
+		})
+	}
+}
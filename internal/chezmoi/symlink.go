@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	vfs "github.com/twpayne/go-vfs"
 )
@@ -14,6 +15,7 @@ type Symlink struct {
 	sourceName       string
 	targetName       string
 	Template         bool
+	linknameMutex    sync.Mutex
 	linkname         string
 	linknameErr      error
 	evaluateLinkname func() (string, error)
@@ -70,7 +72,7 @@ func (s *Symlink) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *A
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (s *Symlink) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (s *Symlink) ConcreteValue(ignore func(string) bool, sourceDir func(string) string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(s.targetName) {
 		return nil, nil
 	}
@@ -80,7 +82,7 @@ func (s *Symlink) ConcreteValue(ignore func(string) bool, sourceDir string, umas
 	}
 	return &symlinkConcreteValue{
 		Type:       "symlink",
-		SourcePath: filepath.Join(sourceDir, s.SourceName()),
+		SourcePath: filepath.Join(sourceDir(s.targetName), s.SourceName()),
 		TargetPath: s.TargetName(),
 		Template:   s.Template,
 		Linkname:   linkname,
@@ -96,8 +98,10 @@ func (s *Symlink) Evaluate(ignore func(string) bool) error {
 	return err
 }
 
-// Linkname returns s's link name.
+// Linkname returns s's link name. It is safe to call Linkname concurrently.
 func (s *Symlink) Linkname() (string, error) {
+	s.linknameMutex.Lock()
+	defer s.linknameMutex.Unlock()
 	if s.evaluateLinkname != nil {
 		s.linkname, s.linknameErr = s.evaluateLinkname()
 		s.evaluateLinkname = nil
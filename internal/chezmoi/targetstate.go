@@ -11,7 +11,9 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"text/template"
+	"text/template/parse"
 
 	"github.com/bmatcuk/doublestar"
 	"github.com/coreos/go-semver/semver"
@@ -22,14 +24,18 @@ import (
 var DefaultTemplateOptions = []string{"missingkey=error"}
 
 const (
+	attributesName   = ".chezmoiattributes"
 	ignoreName       = ".chezmoiignore"
 	removeName       = ".chezmoiremove"
+	rootName         = ".chezmoiroot"
 	templatesDirName = ".chezmoitemplates"
+	triggersName     = ".chezmoitriggers"
 	versionName      = ".chezmoiversion"
 )
 
 // An AddOptions contains options for TargetState.Add.
 type AddOptions struct {
+	Block        bool
 	Empty        bool
 	Encrypt      bool
 	Exact        bool
@@ -52,23 +58,39 @@ type PopulateOptions struct {
 
 // A TargetState represents the root target state.
 type TargetState struct {
+	Concurrency     int
 	DestDir         string
 	Entries         map[string]Entry
 	GPG             *GPG
 	MinVersion      *semver.Version
+	Roots           map[string]*TargetState
 	SourceDir       string
+	SourceDirs      []string
 	TargetIgnore    *PatternSet
 	TargetRemove    *PatternSet
 	TemplateData    map[string]interface{}
 	TemplateFuncs   template.FuncMap
 	TemplateOptions []string
 	Templates       map[string]*template.Template
+	Timings         *Timings
+	Triggers        []*Trigger
 	Umask           os.FileMode
+
+	entrySourceDirs      map[string]string
+	templateOutputCache  PersistentState
+	templateOutputBucket []byte
 }
 
 // A TargetStateOption sets an option on a TargeState.
 type TargetStateOption func(*TargetState)
 
+// WithConcurrency sets the maximum number of entries evaluated concurrently.
+func WithConcurrency(concurrency int) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.Concurrency = concurrency
+	}
+}
+
 // WithDestDir sets DestDir.
 func WithDestDir(destDir string) TargetStateOption {
 	return func(ts *TargetState) {
@@ -97,6 +119,15 @@ func WithMinVersion(minVersion *semver.Version) TargetStateOption {
 	}
 }
 
+// WithRoots sets additional target states, each populated from the top-level
+// source directory with the same name and applied to its own destination
+// directory.
+func WithRoots(roots map[string]*TargetState) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.Roots = roots
+	}
+}
+
 // WithSourceDir sets the source directory.
 func WithSourceDir(sourceDir string) TargetStateOption {
 	return func(ts *TargetState) {
@@ -104,6 +135,14 @@ func WithSourceDir(sourceDir string) TargetStateOption {
 	}
 }
 
+// WithSourceDirs sets the source directories, in order from lowest to highest
+// precedence.
+func WithSourceDirs(sourceDirs []string) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.SourceDirs = sourceDirs
+	}
+}
+
 // WithTargetIgnore sets the target patterns to ignore.
 func WithTargetIgnore(targetIgnore *PatternSet) TargetStateOption {
 	return func(ts *TargetState) {
@@ -139,6 +178,15 @@ func WithTemplateOptions(templateOptions []string) TargetStateOption {
 	}
 }
 
+// WithTemplateOutputCache sets the persistent state and bucket used to cache
+// template outputs.
+func WithTemplateOutputCache(persistentState PersistentState, bucket []byte) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.templateOutputCache = persistentState
+		ts.templateOutputBucket = bucket
+	}
+}
+
 // WithTemplates sets the templates.
 func WithTemplates(templates map[string]*template.Template) TargetStateOption {
 	return func(ts *TargetState) {
@@ -146,6 +194,14 @@ func WithTemplates(templates map[string]*template.Template) TargetStateOption {
 	}
 }
 
+// WithTimings sets the Timings used to record the time spent populating the
+// target state, executing templates, and running gpg.
+func WithTimings(timings *Timings) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.Timings = timings
+	}
+}
+
 // WithUmask sets the umask.
 func WithUmask(umask os.FileMode) TargetStateOption {
 	return func(ts *TargetState) {
@@ -156,6 +212,7 @@ func WithUmask(umask os.FileMode) TargetStateOption {
 // NewTargetState creates a new TargetState with the given options.
 func NewTargetState(options ...TargetStateOption) *TargetState {
 	ts := &TargetState{
+		Concurrency:     1,
 		Entries:         make(map[string]Entry),
 		TargetIgnore:    NewPatternSet(),
 		TargetRemove:    NewPatternSet(),
@@ -164,17 +221,23 @@ func NewTargetState(options ...TargetStateOption) *TargetState {
 	for _, o := range options {
 		o(ts)
 	}
+	if ts.SourceDir == "" && len(ts.SourceDirs) != 0 {
+		ts.SourceDir = ts.SourceDirs[len(ts.SourceDirs)-1]
+	}
 	return ts
 }
 
 // Add adds a new target to ts.
 func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string, info os.FileInfo, follow bool, mutator Mutator) error {
-	contains, err := vfs.Contains(fs, targetPath, ts.DestDir)
+	root, err := ts.Root(fs, targetPath)
 	if err != nil {
 		return err
 	}
-	if !contains {
-		return fmt.Errorf("%s: outside target directory", targetPath)
+	if root != ts {
+		if err := vfs.MkdirAll(mutator, root.SourceDir, 0777&^root.Umask); err != nil {
+			return err
+		}
+		return root.Add(fs, addOptions, targetPath, info, follow, mutator)
 	}
 	targetName, err := filepath.Rel(ts.DestDir, targetPath)
 	if err != nil {
@@ -217,6 +280,9 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 			return fmt.Errorf("%s: not a directory", parentDirName)
 		}
 		parentDir := parentEntry.(*Dir)
+		if err := ts.copyDirToSourceDir(fs, parentDir, mutator); err != nil {
+			return err
+		}
 		parentDirSourceName = parentDir.sourceName
 		entries = parentDir.Entries
 	}
@@ -239,7 +305,27 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 		// recursively, add a .keep file so the directory is managed by git.
 		// chezmoi will ignore the .keep file as it begins with a dot.
 		createKeepFile := len(infos) == 0 || !addOptions.Recursive
-		return ts.addDir(targetName, entries, parentDirSourceName, addOptions.Exact, perm, createKeepFile, mutator)
+		return ts.addDir(fs, targetName, entries, parentDirSourceName, addOptions.Exact, perm, createKeepFile, mutator)
+	case info.Mode().IsRegular() && addOptions.Block:
+		if addOptions.Encrypt {
+			return fmt.Errorf("%s: blocks cannot be encrypted", targetName)
+		}
+		data, err := fs.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		contents, ok, err := FindBlock(data, targetName)
+		switch {
+		case err != nil:
+			return fmt.Errorf("%s: %w", targetPath, err)
+		case !ok:
+			beginMarker, endMarker := BlockMarkers(targetName)
+			return fmt.Errorf("%s: no block between %q and %q", targetName, beginMarker, endMarker)
+		}
+		if addOptions.Template && addOptions.AutoTemplate {
+			contents = autoTemplate(contents, ts.TemplateData)
+		}
+		return ts.addBlock(targetName, entries, parentDirSourceName, addOptions.Template, contents, mutator)
 	case info.Mode().IsRegular():
 		if info.Size() == 0 && !addOptions.Empty {
 			entry, err := ts.Get(fs, targetPath)
@@ -247,6 +333,9 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 			case os.IsNotExist(err):
 				return nil
 			case err == nil:
+				if ts.EntrySourceDir(targetName) != ts.SourceDir {
+					return nil
+				}
 				return mutator.RemoveAll(filepath.Join(ts.SourceDir, entry.SourceName()))
 			default:
 				return err
@@ -260,7 +349,9 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 			contents = autoTemplate(contents, ts.TemplateData)
 		}
 		if addOptions.Encrypt {
+			stopTiming := ts.Timings.Start("gpg", "encrypt "+targetPath)
 			contents, err = ts.GPG.Encrypt(targetPath, contents)
+			stopTiming()
 			if err != nil {
 				return err
 			}
@@ -275,6 +366,21 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 		}
 		return ts.addFile(targetName, entries, parentDirSourceName, info, perm, addOptions.Encrypt, addOptions.Template, contents, mutator)
 	case info.Mode()&os.ModeType == os.ModeSymlink:
+		// A symlink to an entry's file in the source directory, as created
+		// in symlink mode, is already in sync.
+		if file, ok := entries[filepath.Base(targetName)].(*File); ok {
+			sourcePath, err := filepath.Abs(filepath.Join(ts.EntrySourceDir(targetName), file.sourceName))
+			if err != nil {
+				return err
+			}
+			linksToSourcePath, err := isSymlinkTo(fs, targetPath, sourcePath)
+			if err != nil {
+				return err
+			}
+			if linksToSourcePath {
+				return nil
+			}
+		}
 		linkname, err := fs.Readlink(targetPath)
 		if err != nil {
 			return err
@@ -296,54 +402,38 @@ func (ts *TargetState) AllEntries() []Entry {
 
 // Apply ensures that ts.DestDir in fs matches ts.
 func (ts *TargetState) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
-	if applyOptions.Remove {
-		// Build a set of targets to remove.
-		targetsToRemove := make(map[string]struct{})
-		includes := make([]string, 0, len(ts.TargetRemove.includes))
-		for include := range ts.TargetRemove.includes {
-			includes = append(includes, include)
-		}
-		for _, include := range includes {
-			matches, err := doublestar.GlobOS(fs, filepath.Join(ts.DestDir, include))
-			if err != nil {
-				return err
-			}
-			for _, match := range matches {
-				relPath := strings.TrimPrefix(match, ts.DestDir+string(filepath.Separator))
-				// Don't remove targets that are ignored.
-				if ts.TargetIgnore.Match(relPath) {
-					continue
-				}
-				// Don't remove targets that are excluded from remove.
-				if !ts.TargetRemove.Match(relPath) {
-					continue
-				}
-				targetsToRemove[match] = struct{}{}
-			}
-		}
-
-		// FIXME check that the set of targets to remove does not intersect wth
-		// the list of all entries.
+	if ts.Concurrency > 1 {
+		// Evaluate all entries concurrently before making any changes. Errors
+		// are remembered by each entry and returned, in order, when the entry
+		// is applied below.
+		_ = ts.evaluate(applyOptions.Ignore)
+	}
 
-		// Remove targets in reverse order so we remove children before their
-		// parents.
-		sortedTargetsToRemove := make([]string, 0, len(targetsToRemove))
-		for target := range targetsToRemove {
-			sortedTargetsToRemove = append(sortedTargetsToRemove, target)
-		}
-		sort.Sort(sort.Reverse(sort.StringSlice(sortedTargetsToRemove)))
-		for _, target := range sortedTargetsToRemove {
-			if err := mutator.RemoveAll(target); err != nil {
-				return err
-			}
-		}
+	if err := ts.removeTargets(fs, mutator, applyOptions); err != nil {
+		return err
+	}
+	if err := ts.removeUnmanagedBlocks(fs, mutator, follow, applyOptions); err != nil {
+		return err
 	}
 
 	for _, entryName := range sortedEntryNames(ts.Entries) {
-		if err := ts.Entries[entryName].Apply(fs, mutator, follow, applyOptions); err != nil {
+		entry := ts.Entries[entryName]
+		if err := applyOptions.handleError(entry, entry.Apply(fs, mutator, follow, applyOptions), nil); err != nil {
 			return err
 		}
 	}
+	for _, applyError := range applyOptions.Errors {
+		if applyError.DestDir == "" {
+			applyError.DestDir = ts.DestDir
+			applyError.SourceDir = ts.EntrySourceDir(applyError.TargetName)
+		}
+	}
+	if err := ts.applyRoots(fs, mutator, follow, applyOptions); err != nil {
+		return err
+	}
+	if len(applyOptions.Errors) != 0 {
+		return applyOptions.Errors
+	}
 	return nil
 }
 
@@ -355,7 +445,7 @@ func (ts *TargetState) Archive(w *tar.Writer, umask os.FileMode) error {
 	}
 
 	for _, entryName := range sortedEntryNames(ts.Entries) {
-		if err := ts.Entries[entryName].archive(w, ts.TargetIgnore.Match, headerTemplate, umask); err != nil {
+		if err := ts.Entries[entryName].archive(w, ts.Ignore, headerTemplate, umask); err != nil {
 			return err
 		}
 	}
@@ -366,7 +456,7 @@ func (ts *TargetState) Archive(w *tar.Writer, umask os.FileMode) error {
 func (ts *TargetState) ConcreteValue(recursive bool) (interface{}, error) {
 	var entryConcreteValues []interface{}
 	for _, entryName := range sortedEntryNames(ts.Entries) {
-		entryConcreteValue, err := ts.Entries[entryName].ConcreteValue(ts.TargetIgnore.Match, ts.SourceDir, ts.Umask, recursive)
+		entryConcreteValue, err := ts.Entries[entryName].ConcreteValue(ts.Ignore, ts.EntrySourceDir, ts.Umask, recursive)
 		if err != nil {
 			return nil, err
 		}
@@ -377,53 +467,76 @@ func (ts *TargetState) ConcreteValue(recursive bool) (interface{}, error) {
 	return entryConcreteValues, nil
 }
 
-// Evaluate evaluates all of the entries in ts.
+// Evaluate evaluates all of the entries in ts, using up to ts.Concurrency
+// goroutines.
 func (ts *TargetState) Evaluate() error {
-	for _, entryName := range sortedEntryNames(ts.Entries) {
-		if err := ts.Entries[entryName].Evaluate(ts.TargetIgnore.Match); err != nil {
-			return err
-		}
-	}
-	return nil
+	return ts.evaluate(ts.Ignore)
 }
 
-// ExecuteTemplateData returns the result of executing template data.
-func (ts *TargetState) ExecuteTemplateData(name string, data []byte) ([]byte, error) {
+// ExecuteTemplateData returns the result of executing template data. Errors,
+// including panics in template functions, are returned as *TemplateErrors.
+func (ts *TargetState) ExecuteTemplateData(name string, data []byte) (result []byte, err error) {
+	defer ts.Timings.Start("template", name)()
+	defer func() {
+		if r := recover(); r != nil {
+			rErr, ok := r.(error)
+			if !ok {
+				rErr = fmt.Errorf("%v", r)
+			}
+			result = nil
+			err = &TemplateError{
+				Name:     name,
+				Message:  "panic: " + rErr.Error(),
+				DataKeys: templateDataKeys(ts.TemplateData),
+				Err:      rErr,
+			}
+		}
+	}()
 	tmpl, err := template.New(name).Option(ts.TemplateOptions...).Funcs(ts.TemplateFuncs).Parse(string(data))
 	if err != nil {
+		return nil, newTemplateError(name, data, nil, err)
+	}
+	if err := ts.addReferencedTemplates(tmpl); err != nil {
 		return nil, err
 	}
-	for name, t := range ts.Templates {
-		tmpl, err = tmpl.AddParseTree(name, t.Tree)
-		if err != nil {
-			return nil, err
-		}
+	cachedOutput, inputsSHA256 := ts.getCachedTemplateOutput(tmpl, name)
+	if cachedOutput != nil {
+		return cachedOutput, nil
 	}
 	output := &bytes.Buffer{}
 	if err = tmpl.ExecuteTemplate(output, name, ts.TemplateData); err != nil {
-		return nil, err
+		return nil, newTemplateError(name, data, templateDataKeys(ts.TemplateData), err)
 	}
+	ts.setCachedTemplateOutput(name, inputsSHA256, output.Bytes())
 	return output.Bytes(), nil
 }
 
 // Get returns the state of the given target, or nil if no such target is found.
 func (ts *TargetState) Get(fs vfs.Stater, target string) (Entry, error) {
-	contains, err := vfs.Contains(fs, target, ts.DestDir)
+	root, err := ts.Root(fs, target)
 	if err != nil {
 		return nil, err
 	}
-	if !contains {
-		return nil, fmt.Errorf("%s: outside target directory", target)
-	}
-	targetName, err := filepath.Rel(ts.DestDir, target)
+	targetName, err := filepath.Rel(root.DestDir, target)
 	if err != nil {
 		return nil, err
 	}
-	return ts.findEntry(targetName)
+	return root.findEntry(targetName)
+}
+
+// Ignore returns true if the target targetName is ignored. Patterns that only
+// match directories match targetName if it is a directory in ts.
+func (ts *TargetState) Ignore(targetName string) bool {
+	if entry, err := ts.findEntry(targetName); err == nil {
+		if _, ok := entry.(*Dir); ok {
+			return ts.TargetIgnore.MatchDir(targetName)
+		}
+	}
+	return ts.TargetIgnore.Match(targetName)
 }
 
 // ImportTAR imports a tar archive.
-func (ts *TargetState) ImportTAR(r *tar.Reader, importTAROptions ImportTAROptions, mutator Mutator) error {
+func (ts *TargetState) ImportTAR(fs vfs.FS, r *tar.Reader, importTAROptions ImportTAROptions, mutator Mutator) error {
 	for {
 		header, err := r.Next()
 		if err == io.EOF {
@@ -433,7 +546,7 @@ func (ts *TargetState) ImportTAR(r *tar.Reader, importTAROptions ImportTAROption
 		}
 		switch header.Typeflag {
 		case tar.TypeDir, tar.TypeReg, tar.TypeSymlink:
-			if err := ts.importHeader(r, importTAROptions, header, mutator); err != nil {
+			if err := ts.importHeader(fs, r, importTAROptions, header, mutator); err != nil {
 				return err
 			}
 		case tar.TypeXGlobalHeader:
@@ -444,16 +557,40 @@ func (ts *TargetState) ImportTAR(r *tar.Reader, importTAROptions ImportTAROption
 	return nil
 }
 
-// Populate walks fs from ts.SourceDir to populate ts.
+// Populate walks fs from each of ts's source directories in turn to populate
+// ts. Entries, templates, and patterns in later source directories override
+// those in earlier ones.
 func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
-	return vfs.Walk(fs, ts.SourceDir, func(path string, info os.FileInfo, _ error) error {
-		relPath, err := filepath.Rel(ts.SourceDir, path)
+	defer ts.Timings.Start("populate", "")()
+	for _, sourceDir := range ts.sourceDirs() {
+		if err := ts.populateSourceDir(fs, sourceDir, options); err != nil {
+			return err
+		}
+	}
+	if ts.MinVersion != nil && !ts.MinVersion.LessThan(OrderedPatternsMinVersion) {
+		ts.TargetIgnore.Compile()
+		ts.TargetRemove.Compile()
+	}
+	return ts.populateRoots(fs, options)
+}
+
+func (ts *TargetState) populateSourceDir(fs vfs.FS, sourceDir string, options *PopulateOptions) error {
+	manifest, err := ReadAttributeManifest(fs, sourceDir)
+	if err != nil {
+		return err
+	}
+	return vfs.Walk(fs, sourceDir, func(path string, info os.FileInfo, _ error) error {
+		relPath, err := filepath.Rel(sourceDir, path)
 		if err != nil {
 			return err
 		}
 		if relPath == "." {
 			return nil
 		}
+		// Roots are populated separately.
+		if _, ok := ts.Roots[relPath]; ok && info.IsDir() {
+			return filepath.SkipDir
+		}
 		// Treat all files and directories beginning with "." specially.
 		if _, name := filepath.Split(relPath); strings.HasPrefix(name, ".") {
 			switch {
@@ -468,6 +605,9 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 					return err
 				}
 				return filepath.SkipDir
+			case info.Name() == triggersName:
+				dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
+				return ts.addTriggers(fs, path, filepath.Join(dns...))
 			case info.Name() == versionName:
 				data, err := fs.ReadFile(path)
 				if err != nil {
@@ -498,17 +638,38 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 			if err != nil {
 				return err
 			}
-			da := das[len(das)-1]
-			entries[da.Name] = newDir(relPath, targetName, da.Exact, da.Perm)
+			da := manifest.applyDirAttributes(relPath, das[len(das)-1])
+			if dir, ok := entries[da.Name].(*Dir); ok {
+				// Keep the entries of the same directory in earlier source
+				// directories.
+				dir.sourceName = relPath
+				dir.Exact = da.Exact
+				dir.Perm = da.Perm
+				ts.setEntrySourceDir(targetName, sourceDir)
+			} else {
+				ts.setEntry(entries, da.Name, newDir(relPath, targetName, da.Exact, da.Perm), sourceDir)
+			}
 		case info.Mode().IsRegular():
 			psfp := parseSourceFilePath(relPath)
+			if psfp.fileAttributes != nil {
+				*psfp.fileAttributes = manifest.applyFileAttributes(relPath, *psfp.fileAttributes)
+			}
+			if psfp.scriptAttributes != nil {
+				*psfp.scriptAttributes = manifest.applyScriptAttributes(relPath, *psfp.scriptAttributes)
+			}
+			if psfp.blockAttributes != nil {
+				*psfp.blockAttributes = manifest.applyBlockAttributes(relPath, *psfp.blockAttributes)
+			}
+			if psfp.mergeAttributes != nil {
+				*psfp.mergeAttributes = manifest.applyMergeAttributes(relPath, *psfp.mergeAttributes)
+			}
 			dns := dirNames(psfp.dirAttributes)
 			entries, err := ts.findEntries(dns)
 			if err != nil {
 				return err
 			}
 			switch {
-			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == 0 || psfp.scriptAttributes != nil:
+			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == 0 || psfp.scriptAttributes != nil || psfp.blockAttributes != nil || psfp.mergeAttributes != nil:
 				readFile := func() ([]byte, error) {
 					return fs.ReadFile(path)
 				}
@@ -520,10 +681,11 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 						if err != nil {
 							return nil, err
 						}
+						defer ts.Timings.Start("gpg", "decrypt "+path)()
 						return ts.GPG.Decrypt(path, ciphertext)
 					}
 				}
-				if psfp.fileAttributes != nil && psfp.fileAttributes.Template || psfp.scriptAttributes != nil && psfp.scriptAttributes.Template {
+				if psfp.fileAttributes != nil && psfp.fileAttributes.Template || psfp.scriptAttributes != nil && psfp.scriptAttributes.Template || psfp.blockAttributes != nil && psfp.blockAttributes.Template || psfp.mergeAttributes != nil && psfp.mergeAttributes.Template {
 					if options == nil || options.ExecuteTemplates {
 						prevEvaluateContents := evaluateContents
 						evaluateContents = func() ([]byte, error) {
@@ -546,7 +708,7 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 						Template:         psfp.fileAttributes.Template,
 						evaluateContents: evaluateContents,
 					}
-					entries[psfp.fileAttributes.Name] = entry
+					ts.setEntry(entries, psfp.fileAttributes.Name, entry, sourceDir)
 				case psfp.scriptAttributes != nil:
 					entry := &Script{
 						sourceName:       relPath,
@@ -555,7 +717,23 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 						Template:         psfp.scriptAttributes.Template,
 						evaluateContents: evaluateContents,
 					}
-					entries[psfp.scriptAttributes.Name] = entry
+					ts.setEntry(entries, psfp.scriptAttributes.Name, entry, sourceDir)
+				case psfp.blockAttributes != nil:
+					entry := &Block{
+						sourceName:       relPath,
+						targetName:       filepath.Join(append(dns, psfp.blockAttributes.Name)...),
+						Template:         psfp.blockAttributes.Template,
+						evaluateContents: evaluateContents,
+					}
+					ts.setEntry(entries, psfp.blockAttributes.Name, entry, sourceDir)
+				case psfp.mergeAttributes != nil:
+					entry := &Merge{
+						sourceName:       relPath,
+						targetName:       filepath.Join(append(dns, psfp.mergeAttributes.Name)...),
+						Template:         psfp.mergeAttributes.Template,
+						evaluateContents: evaluateContents,
+					}
+					ts.setEntry(entries, psfp.mergeAttributes.Name, entry, sourceDir)
 				}
 			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == os.ModeSymlink:
 				evaluateLinkname := func() (string, error) {
@@ -574,7 +752,7 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 					Template:         psfp.fileAttributes.Template,
 					evaluateLinkname: evaluateLinkname,
 				}
-				entries[psfp.fileAttributes.Name] = entry
+				ts.setEntry(entries, psfp.fileAttributes.Name, entry, sourceDir)
 			default:
 				return fmt.Errorf("%s: unsupported file type", path)
 			}
@@ -585,13 +763,63 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 	})
 }
 
-func (ts *TargetState) addDir(targetName string, entries map[string]Entry, parentDirSourceName string, exact bool, perm os.FileMode, createKeepFile bool, mutator Mutator) error {
+func (ts *TargetState) addBlock(targetName string, entries map[string]Entry, parentDirSourceName string, template bool, contents []byte, mutator Mutator) error {
+	name := filepath.Base(targetName)
+	var existingBlock *Block
+	var existingContents []byte
+	if entry, ok := entries[name]; ok && ts.EntrySourceDir(targetName) == ts.SourceDir {
+		existingBlock, ok = entry.(*Block)
+		if !ok {
+			return fmt.Errorf("%s: already added and not a block", targetName)
+		}
+		var err error
+		existingContents, err = existingBlock.Contents()
+		if err != nil {
+			return err
+		}
+	}
+
+	sourceName := BlockAttributes{
+		Name:     name,
+		Template: template,
+	}.SourceName()
+	if parentDirSourceName != "" {
+		sourceName = filepath.Join(parentDirSourceName, sourceName)
+	}
+	block := &Block{
+		sourceName: sourceName,
+		targetName: targetName,
+		Template:   template,
+		contents:   contents,
+	}
+	if existingBlock != nil {
+		// Keep the name of an existing block with the same attributes, which
+		// may be assigned in .chezmoiattributes.
+		if existingBlock.Template == block.Template {
+			block.sourceName = existingBlock.sourceName
+		}
+		if bytes.Equal(existingBlock.contents, block.contents) {
+			if existingBlock.sourceName == block.sourceName {
+				return nil
+			}
+			return mutator.Rename(filepath.Join(ts.SourceDir, existingBlock.sourceName), filepath.Join(ts.SourceDir, block.sourceName))
+		}
+		if err := mutator.RemoveAll(filepath.Join(ts.SourceDir, existingBlock.sourceName)); err != nil {
+			return err
+		}
+	}
+	ts.setEntry(entries, name, block, ts.SourceDir)
+	return mutator.WriteFile(filepath.Join(ts.SourceDir, block.sourceName), contents, 0666&^ts.Umask, existingContents)
+}
+
+func (ts *TargetState) addDir(fs vfs.FS, targetName string, entries map[string]Entry, parentDirSourceName string, exact bool, perm os.FileMode, createKeepFile bool, mutator Mutator) error {
 	name := filepath.Base(targetName)
 	if entry, ok := entries[name]; ok {
-		if _, ok = entry.(*Dir); !ok {
+		dir, ok := entry.(*Dir)
+		if !ok {
 			return fmt.Errorf("%s: already added and not a directory", targetName)
 		}
-		return nil
+		return ts.copyDirToSourceDir(fs, dir, mutator)
 	}
 	sourceName := DirAttributes{
 		Name:  name,
@@ -618,7 +846,7 @@ func (ts *TargetState) addFile(targetName string, entries map[string]Entry, pare
 	name := filepath.Base(targetName)
 	var existingFile *File
 	var existingContents []byte
-	if entry, ok := entries[name]; ok {
+	if entry, ok := entries[name]; ok && ts.EntrySourceDir(targetName) == ts.SourceDir {
 		existingFile, ok = entry.(*File)
 		if !ok {
 			return fmt.Errorf("%s: already added and not a regular file", targetName)
@@ -651,6 +879,11 @@ func (ts *TargetState) addFile(targetName string, entries map[string]Entry, pare
 		contents:   contents,
 	}
 	if existingFile != nil {
+		// Keep the name of an existing file with the same attributes, which
+		// may be assigned in .chezmoiattributes.
+		if existingFile.Empty == file.Empty && existingFile.Encrypted == file.Encrypted && existingFile.Perm == file.Perm && existingFile.Template == file.Template {
+			file.sourceName = existingFile.sourceName
+		}
 		if bytes.Equal(existingFile.contents, file.contents) {
 			if existingFile.sourceName == file.sourceName {
 				return nil
@@ -661,8 +894,8 @@ func (ts *TargetState) addFile(targetName string, entries map[string]Entry, pare
 			return err
 		}
 	}
-	entries[name] = file
-	return mutator.WriteFile(filepath.Join(ts.SourceDir, sourceName), contents, 0666&^ts.Umask, existingContents)
+	ts.setEntry(entries, name, file, ts.SourceDir)
+	return mutator.WriteFile(filepath.Join(ts.SourceDir, file.sourceName), contents, 0666&^ts.Umask, existingContents)
 }
 
 func (ts *TargetState) addPatterns(fs vfs.FS, ps *PatternSet, path, relPath string) error {
@@ -686,8 +919,7 @@ func (ts *TargetState) addPatterns(fs vfs.FS, ps *PatternSet, path, relPath stri
 			include = false
 			text = strings.TrimPrefix(text, "!")
 		}
-		pattern := filepath.Join(dir, text)
-		if err := ps.Add(pattern, include); err != nil {
+		if err := ps.AddRelative(dir, text, include); err != nil {
 			return fmt.Errorf("%s: %w", path, err)
 		}
 	}
@@ -697,11 +929,42 @@ func (ts *TargetState) addPatterns(fs vfs.FS, ps *PatternSet, path, relPath stri
 	return nil
 }
 
+// addReferencedTemplates adds the shared templates that tmpl references,
+// directly or through other shared templates, to tmpl. Only referenced
+// templates are added so that the cost of executing a template does not grow
+// with the number of shared templates.
+func (ts *TargetState) addReferencedTemplates(tmpl *template.Template) error {
+	var names []string
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			names = appendTemplateNames(names, t.Tree.Root)
+		}
+	}
+	added := make(map[string]struct{})
+	for len(names) != 0 {
+		name := names[len(names)-1]
+		names = names[:len(names)-1]
+		if _, ok := added[name]; ok {
+			continue
+		}
+		t, ok := ts.Templates[name]
+		if !ok {
+			continue
+		}
+		if _, err := tmpl.AddParseTree(name, t.Tree); err != nil {
+			return err
+		}
+		added[name] = struct{}{}
+		names = appendTemplateNames(names, t.Tree.Root)
+	}
+	return nil
+}
+
 func (ts *TargetState) addSymlink(targetName string, entries map[string]Entry, parentDirSourceName string, linkname string, mutator Mutator) error {
 	name := filepath.Base(targetName)
 	var existingSymlink *Symlink
 	var existingLinkname string
-	if entry, ok := entries[name]; ok {
+	if entry, ok := entries[name]; ok && ts.EntrySourceDir(targetName) == ts.SourceDir {
 		existingSymlink, ok = entry.(*Symlink)
 		if !ok {
 			return fmt.Errorf("%s: already added and not a symlink", targetName)
@@ -735,7 +998,7 @@ func (ts *TargetState) addSymlink(targetName string, entries map[string]Entry, p
 			return err
 		}
 	}
-	entries[name] = symlink
+	ts.setEntry(entries, name, symlink, ts.SourceDir)
 	return mutator.WriteFile(filepath.Join(ts.SourceDir, symlink.sourceName), []byte(symlink.linkname), 0666&^ts.Umask, []byte(existingLinkname))
 }
 
@@ -769,6 +1032,48 @@ func (ts *TargetState) addTemplatesDir(fs vfs.FS, path string) error {
 	})
 }
 
+// evaluate evaluates all entries in ts that are not ignored with a pool of up
+// to ts.Concurrency workers. It returns the error of the first entry, in
+// order, that failed.
+func (ts *TargetState) evaluate(ignore func(string) bool) error {
+	var entries []Entry
+	for _, entryName := range sortedEntryNames(ts.Entries) {
+		entries = appendEvaluateEntries(entries, ts.Entries[entryName], ignore)
+	}
+
+	concurrency := ts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(entries) {
+		concurrency = len(entries)
+	}
+	errs := make([]error, len(entries))
+	indexCh := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for index := range indexCh {
+				errs[index] = entries[index].Evaluate(ignore)
+			}
+		}()
+	}
+	for index := range entries {
+		indexCh <- index
+	}
+	close(indexCh)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (ts *TargetState) executeTemplate(fs vfs.FS, path string) ([]byte, error) {
 	data, err := fs.ReadFile(path)
 	if err != nil {
@@ -791,6 +1096,62 @@ func (ts *TargetState) findEntries(dirNames []string) (map[string]Entry, error)
 	return entries, nil
 }
 
+// findRemoveTargets returns the paths of the targets matched by
+// .chezmoiremove, in reverse order so that children come before their parents.
+// It returns an error if any of them are, or contain, managedTargetNames.
+func (ts *TargetState) findRemoveTargets(fs vfs.FS, managedTargetNames map[string]struct{}) ([]string, error) {
+	// Build a set of targets to remove.
+	targetsToRemove := make(map[string]struct{})
+	for _, glob := range ts.TargetRemove.Globs() {
+		matches, err := doublestar.GlobOS(fs, filepath.Join(ts.DestDir, glob))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			relPath := strings.TrimPrefix(match, ts.DestDir+string(filepath.Separator))
+			// Don't remove targets that are ignored.
+			if ts.Ignore(relPath) {
+				continue
+			}
+			// Don't remove targets that are excluded from remove.
+			info, err := fs.Lstat(match)
+			if err != nil {
+				return nil, err
+			}
+			matchRemove := ts.TargetRemove.Match
+			if info.IsDir() {
+				matchRemove = ts.TargetRemove.MatchDir
+			}
+			if !matchRemove(relPath) {
+				continue
+			}
+			targetsToRemove[match] = struct{}{}
+		}
+	}
+
+	// Don't remove targets that are managed, or that contain targets that are
+	// managed.
+	sortedManagedTargetNames := make([]string, 0, len(managedTargetNames))
+	for targetName := range managedTargetNames {
+		sortedManagedTargetNames = append(sortedManagedTargetNames, targetName)
+	}
+	sort.Strings(sortedManagedTargetNames)
+	for _, targetName := range sortedManagedTargetNames {
+		for targetPath := filepath.Join(ts.DestDir, targetName); targetPath != ts.DestDir; targetPath = filepath.Dir(targetPath) {
+			if _, ok := targetsToRemove[targetPath]; ok {
+				return nil, fmt.Errorf("%s: matched by %s but managed by chezmoi", targetPath, removeName)
+			}
+		}
+	}
+
+	sortedTargetsToRemove := make([]string, 0, len(targetsToRemove))
+	for target := range targetsToRemove {
+		sortedTargetsToRemove = append(sortedTargetsToRemove, target)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sortedTargetsToRemove)))
+	return sortedTargetsToRemove, nil
+}
+
 func (ts *TargetState) findEntry(name string) (Entry, error) {
 	names := splitPathList(name)
 	entries, err := ts.findEntries(names[:len(names)-1])
@@ -804,7 +1165,7 @@ func (ts *TargetState) findEntry(name string) (Entry, error) {
 	return entry, nil
 }
 
-func (ts *TargetState) importHeader(r io.Reader, importTAROptions ImportTAROptions, header *tar.Header, mutator Mutator) error {
+func (ts *TargetState) importHeader(fs vfs.FS, r io.Reader, importTAROptions ImportTAROptions, header *tar.Header, mutator Mutator) error {
 	targetPath := header.Name
 	if importTAROptions.StripComponents > 0 {
 		targetPath = filepath.Join(strings.Split(targetPath, string(os.PathSeparator))[importTAROptions.StripComponents:]...)
@@ -829,6 +1190,9 @@ func (ts *TargetState) importHeader(r io.Reader, importTAROptions ImportTAROptio
 		if !ok {
 			return fmt.Errorf("%s: parent is not a directory", targetName)
 		}
+		if err := ts.copyDirToSourceDir(fs, parentDir, mutator); err != nil {
+			return err
+		}
 		parentDirSourceName = parentDir.sourceName
 		entries = parentDir.Entries
 	}
@@ -836,7 +1200,7 @@ func (ts *TargetState) importHeader(r io.Reader, importTAROptions ImportTAROptio
 	case tar.TypeDir:
 		perm := os.FileMode(header.Mode).Perm()
 		createKeepFile := false // FIXME don't assume that we don't need a keep file
-		return ts.addDir(targetName, entries, parentDirSourceName, importTAROptions.Exact, perm, createKeepFile, mutator)
+		return ts.addDir(fs, targetName, entries, parentDirSourceName, importTAROptions.Exact, perm, createKeepFile, mutator)
 	case tar.TypeReg:
 		info := header.FileInfo()
 		contents, err := ioutil.ReadAll(r)
@@ -851,3 +1215,45 @@ func (ts *TargetState) importHeader(r io.Reader, importTAROptions ImportTAROptio
 		return fmt.Errorf("%s: unspported typeflag '%c'", header.Name, header.Typeflag)
 	}
 }
+
+// appendTemplateNames appends the names of the templates invoked by node to
+// names.
+func appendTemplateNames(names []string, node parse.Node) []string {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return names
+		}
+		for _, n := range node.Nodes {
+			names = appendTemplateNames(names, n)
+		}
+	case *parse.IfNode:
+		names = appendTemplateNames(names, node.List)
+		names = appendTemplateNames(names, node.ElseList)
+	case *parse.RangeNode:
+		names = appendTemplateNames(names, node.List)
+		names = appendTemplateNames(names, node.ElseList)
+	case *parse.WithNode:
+		names = appendTemplateNames(names, node.List)
+		names = appendTemplateNames(names, node.ElseList)
+	case *parse.TemplateNode:
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+// appendEvaluateEntries appends entry, or if entry is a Dir all of its
+// descendants that need evaluating, to entries, skipping ignored entries.
+func appendEvaluateEntries(entries []Entry, entry Entry, ignore func(string) bool) []Entry {
+	if ignore(entry.TargetName()) {
+		return entries
+	}
+	dir, ok := entry.(*Dir)
+	if !ok {
+		return append(entries, entry)
+	}
+	for _, entryName := range sortedEntryNames(dir.Entries) {
+		entries = appendEvaluateEntries(entries, dir.Entries[entryName], ignore)
+	}
+	return entries
+}
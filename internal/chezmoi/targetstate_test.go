@@ -1,8 +1,12 @@
 package chezmoi
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"text/template"
 
@@ -372,6 +376,10 @@ func TestTargetStatePopulate(t *testing.T) {
 					excludes: map[string]struct{}{
 						"g": {},
 					},
+					patterns: []*patternSetEntry{
+						{dir: ".", pattern: "f*", include: true},
+						{dir: ".", pattern: "g", include: false},
+					},
 				}),
 			),
 		},
@@ -393,6 +401,10 @@ func TestTargetStatePopulate(t *testing.T) {
 					excludes: map[string]struct{}{
 						"g": {},
 					},
+					patterns: []*patternSetEntry{
+						{dir: ".", pattern: "f*", include: true},
+						{dir: ".", pattern: "g", include: false},
+					},
 				}),
 			),
 		},
@@ -422,6 +434,10 @@ func TestTargetStatePopulate(t *testing.T) {
 					excludes: map[string]struct{}{
 						filepath.Join("dir", "bar"): {},
 					},
+					patterns: []*patternSetEntry{
+						{dir: "dir", pattern: "foo", include: true},
+						{dir: "dir", pattern: "bar", include: false},
+					},
 				}),
 			),
 		},
@@ -488,3 +504,260 @@ func TestTargetStatePopulate(t *testing.T) {
 		})
 	}
 }
+
+func TestTargetStatePopulateOrderedPatterns(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		version       string
+		expectIgnored map[string]bool
+	}{
+		{
+			name:    "unordered",
+			version: "1.8.0",
+			expectIgnored: map[string]bool{
+				filepath.Join(".cache", "cache"):        false,
+				filepath.Join(".cache", "cache", "foo"): false,
+				filepath.Join(".config", "bar"):         true,
+				filepath.Join(".config", "foo"):         false,
+				filepath.Join(".config", "foo", "tmp"):  true,
+				filepath.Join(".config", "foo", "keep"): false,
+			},
+		},
+		{
+			name:    "ordered",
+			version: "1.9.0",
+			expectIgnored: map[string]bool{
+				filepath.Join(".cache", "cache"):        true,
+				filepath.Join(".cache", "cache", "foo"): true,
+				filepath.Join(".config", "bar"):         true,
+				filepath.Join(".config", "foo"):         false,
+				filepath.Join(".config", "foo", "tmp"):  true,
+				filepath.Join(".config", "foo", "keep"): false,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoiignore": "" +
+						"cache/\n" +
+						".config/*\n" +
+						"!.config/foo\n" +
+						".config/foo/tmp\n",
+					".chezmoiversion":     tc.version,
+					"dot_cache/cache/foo": "# contents of .cache/cache/foo\n",
+					"dot_config/foo/keep": "# contents of .config/foo/keep\n",
+				},
+			})
+			require.NoError(t, err)
+			defer cleanup()
+
+			ts := NewTargetState(
+				WithDestDir("/home/user"),
+				WithSourceDir("/home/user/.local/share/chezmoi"),
+			)
+			require.NoError(t, ts.Populate(fs, nil))
+			for targetName, expectIgnored := range tc.expectIgnored {
+				assert.Equal(t, expectIgnored, ts.Ignore(targetName), targetName)
+			}
+		})
+	}
+}
+
+// A failMkdirMutator is a Mutator whose Mkdir always fails.
+type failMkdirMutator struct {
+	Mutator
+}
+
+func (m failMkdirMutator) Mkdir(name string, perm os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrPermission}
+}
+
+func TestTargetStateApplyKeepGoing(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dir/file":     "file",
+			"dir/sub/file": "file",
+			"dot_bad.tmpl": "{{ .missing }}",
+			"dot_good":     "good",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+		WithTemplateData(map[string]interface{}{}),
+		WithTemplateOptions([]string{"missingkey=error"}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	applyOptions := &ApplyOptions{
+		DestDir:   ts.DestDir,
+		Ignore:    ts.TargetIgnore.Match,
+		KeepGoing: true,
+		Umask:     022,
+	}
+	err = ts.Apply(fs, failMkdirMutator{Mutator: NewFSMutator(fs)}, false, applyOptions)
+	require.Error(t, err)
+	var applyErrors ApplyErrors
+	require.True(t, errors.As(err, &applyErrors))
+	require.Len(t, applyErrors, 2)
+	assert.Equal(t, ".bad", applyErrors[0].TargetName)
+	assert.Equal(t, "dot_bad.tmpl", applyErrors[0].SourceName)
+	var te *TemplateError
+	assert.True(t, errors.As(applyErrors[0], &te))
+	assert.Equal(t, "dir", applyErrors[1].TargetName)
+	assert.Equal(t, []string{"dir/file", "dir/sub", "dir/sub/file"}, applyErrors[1].Skipped)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.good",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("good"),
+		),
+		vfst.TestPath("/home/user/dir",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
+func TestTargetStateEvaluateConcurrency(t *testing.T) {
+	root := map[string]interface{}{
+		"/home/user/.local/share/chezmoi/dir/ignored.tmpl": `{{ fail "ignored" }}`,
+		"/home/user/.local/share/chezmoi/.chezmoiignore":   "dir/ignored\n",
+	}
+	for i := 0; i < 16; i++ {
+		root[filepath.Join("/home/user/.local/share/chezmoi/dir", "file"+strconv.Itoa(i)+".tmpl")] = "{{ count }}"
+	}
+	root["/home/user/.local/share/chezmoi/dir/file3.tmpl"] = `{{ fail "file3" }}`
+	root["/home/user/.local/share/chezmoi/dir/file7.tmpl"] = `{{ fail "file7" }}`
+	fs, cleanup, err := vfst.NewTestFS(root)
+	require.NoError(t, err)
+	defer cleanup()
+	var calls int32
+	ts := NewTargetState(
+		WithConcurrency(4),
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+		WithTemplateFuncs(template.FuncMap{
+			"count": func() int32 {
+				return atomic.AddInt32(&calls, 1)
+			},
+			"fail": func(s string) string {
+				panic(errors.New(s))
+			},
+		}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	err = ts.Evaluate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "file3")
+	assert.Equal(t, int32(14), atomic.LoadInt32(&calls))
+}
+
+func TestTargetStateExecuteTemplateDataReferencedTemplates(t *testing.T) {
+	ts := NewTargetState(
+		WithTemplateData(map[string]interface{}{
+			"empty": []int{},
+			"name":  "value",
+		}),
+		WithTemplates(map[string]*template.Template{
+			"outer":  template.Must(template.New("outer").Parse(`{{ range .empty }}{{ else }}{{ template "inner" $ }}{{ end }}`)),
+			"inner":  template.Must(template.New("inner").Parse(`{{ .name }}`)),
+			"unused": template.Must(template.New("unused").Parse(`{{ .missing }}`)),
+		}),
+	)
+	output, err := ts.ExecuteTemplateData("file", []byte(`{{ define "local" }}local{{ end }}{{ if true }}{{ template "outer" . }} {{ template "local" }}{{ end }}`))
+	require.NoError(t, err)
+	assert.Equal(t, "value local", string(output))
+
+	_, err = ts.ExecuteTemplateData("file", []byte(`{{ template "missing" }}`))
+	assert.Error(t, err)
+}
+
+func BenchmarkTargetStateExecuteTemplateData(b *testing.B) {
+	for _, numPartials := range []int{10, 100, 1000} {
+		templates := make(map[string]*template.Template)
+		for i := 0; i < numPartials; i++ {
+			name := "partial" + strconv.Itoa(i)
+			templates[name] = template.Must(template.New(name).Parse(`{{ if true }}` + name + `{{ end }}`))
+		}
+		data := []byte(`{{ template "partial0" }} {{ .name }}`)
+		ts := NewTargetState(
+			WithTemplateData(map[string]interface{}{
+				"name": "value",
+			}),
+			WithTemplates(templates),
+		)
+		for _, bc := range []struct {
+			name                string
+			executeTemplateData func(*TargetState, string, []byte) ([]byte, error)
+		}{
+			{
+				name:                "all",
+				executeTemplateData: executeTemplateDataWithAllTemplates,
+			},
+			{
+				name:                "referenced",
+				executeTemplateData: (*TargetState).ExecuteTemplateData,
+			},
+		} {
+			executeTemplateData := bc.executeTemplateData
+			b.Run(strconv.Itoa(numPartials)+"_partials_"+bc.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := executeTemplateData(ts, "file", data); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// executeTemplateDataWithAllTemplates executes data with all of ts's shared
+// templates, as ExecuteTemplateData did before it added only the referenced
+// templates, for comparison.
+func executeTemplateDataWithAllTemplates(ts *TargetState, name string, data []byte) ([]byte, error) {
+	tmpl, err := template.New(name).Option(ts.TemplateOptions...).Funcs(ts.TemplateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	for name, t := range ts.Templates {
+		tmpl, err = tmpl.AddParseTree(name, t.Tree)
+		if err != nil {
+			return nil, err
+		}
+	}
+	output := &bytes.Buffer{}
+	if err := tmpl.ExecuteTemplate(output, name, ts.TemplateData); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+func BenchmarkTargetStateEvaluate(b *testing.B) {
+	root := map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		root["/home/user/.local/share/chezmoi/.chezmoitemplates/partial"+strconv.Itoa(i)] = `{{ if true }}partial{{ end }}`
+	}
+	for i := 0; i < 500; i++ {
+		root["/home/user/.local/share/chezmoi/dir/file"+strconv.Itoa(i)+".tmpl"] = `{{ template "partial` + strconv.Itoa(i%100) + `" }} {{ .name }}`
+	}
+	fs, cleanup, err := vfst.NewTestFS(root)
+	require.NoError(b, err)
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ts := NewTargetState(
+			WithDestDir("/home/user"),
+			WithSourceDir("/home/user/.local/share/chezmoi"),
+			WithTemplateData(map[string]interface{}{
+				"name": "value",
+			}),
+		)
+		if err := ts.Populate(fs, nil); err != nil {
+			b.Fatal(err)
+		}
+		if err := ts.Evaluate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
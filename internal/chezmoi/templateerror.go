@@ -0,0 +1,140 @@
+package chezmoi
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// templateErrorContextLines is the number of lines of context shown before and
+// after the line containing a template error.
+const templateErrorContextLines = 2
+
+var templateErrorRegexp = regexp.MustCompile(`(?s)\Atemplate: (.*?):(\d+):(?:(\d+):)? (.*)\z`)
+
+// A TemplateError is an error parsing or executing a template, annotated with
+// the location of the error in the template's source.
+type TemplateError struct {
+	Name     string
+	Line     int // Line is 1-based, or zero if unknown.
+	Column   int // Column is 1-based, or zero if unknown.
+	Message  string
+	Context  string
+	DataKeys []string
+	Err      error
+}
+
+// newTemplateError returns a new TemplateError for err, which was returned by
+// parsing or executing the template name with source data. If dataKeys is
+// non-nil then err is an execution error and dataKeys are the keys of the
+// template data in scope.
+func newTemplateError(name string, data []byte, dataKeys []string, err error) *TemplateError {
+	te := &TemplateError{
+		Name:     name,
+		Message:  err.Error(),
+		DataKeys: dataKeys,
+		Err:      err,
+	}
+	m := templateErrorRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return te
+	}
+	te.Message = m[4]
+	// Errors in templates included from .chezmoitemplates refer to those
+	// templates, for which we do not have the source.
+	if m[1] != name {
+		te.Name = m[1]
+		return te
+	}
+	te.Line, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		column, _ := strconv.Atoi(m[3])
+		te.Column = column + 1
+	}
+	te.Context = templateErrorContext(data, te.Line, te.Column)
+	return te
+}
+
+func (e *TemplateError) Error() string {
+	sb := &strings.Builder{}
+	sb.WriteString(e.Name)
+	if e.Line != 0 {
+		fmt.Fprintf(sb, ":%d", e.Line)
+		if e.Column != 0 {
+			fmt.Fprintf(sb, ":%d", e.Column)
+		}
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Message)
+	if e.Context != "" {
+		sb.WriteString("\n")
+		sb.WriteString(e.Context)
+	}
+	if e.DataKeys != nil {
+		sb.WriteString("\ndata keys: ")
+		sb.WriteString(strings.Join(e.DataKeys, ", "))
+	}
+	return sb.String()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// templateDataKeys returns the sorted keys of templateData.
+func templateDataKeys(templateData map[string]interface{}) []string {
+	dataKeys := []string{}
+	for key := range templateData {
+		dataKeys = append(dataKeys, key)
+	}
+	sort.Strings(dataKeys)
+	return dataKeys
+}
+
+// templateErrorContext returns the lines of data around line, with a caret
+// under column if it is non-zero.
+func templateErrorContext(data []byte, line, column int) string {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	first := line - templateErrorContextLines
+	if first < 1 {
+		first = 1
+	}
+	last := line + templateErrorContextLines
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(strconv.Itoa(last))
+	b := &bytes.Buffer{}
+	for i := first; i <= last; i++ {
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		fmt.Fprintf(b, "%s %*d | %s\n", marker, width, i, lines[i-1])
+		if i == line && column != 0 {
+			fmt.Fprintf(b, "  %*s | %s^\n", width, "", caretIndent(lines[i-1], column))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// caretIndent returns the whitespace needed to place a caret under the
+// 1-based byte column in line, preserving tabs so that the caret aligns.
+func caretIndent(line string, column int) string {
+	if column > len(line)+1 {
+		column = len(line) + 1
+	}
+	indent := []byte(line[:column-1])
+	for i, c := range indent {
+		if c != '\t' {
+			indent[i] = ' '
+		}
+	}
+	return string(indent)
+}
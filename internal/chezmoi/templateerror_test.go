@@ -0,0 +1,87 @@
+package chezmoi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateError(t *testing.T) {
+	errSecret := errors.New("secret: not found")
+	funcs := map[string]interface{}{
+		"secret": func(string) string {
+			panic(errSecret)
+		},
+	}
+	for _, tc := range []struct {
+		name          string
+		data          string
+		expectedLine  int
+		expectedError string
+	}{
+		{
+			name:         "parse_error",
+			data:         "line1\nline2\n{{ if }}\nline4\nline5\nline6\n",
+			expectedLine: 3,
+			expectedError: "/home/user/.local/share/chezmoi/dot_foo.tmpl:3: missing value for if\n" +
+				"  1 | line1\n" +
+				"  2 | line2\n" +
+				"> 3 | {{ if }}\n" +
+				"  4 | line4\n" +
+				"  5 | line5",
+		},
+		{
+			name:         "unknown_key",
+			data:         "line1\n\tkey = {{ .Unknown.Key }}\n",
+			expectedLine: 2,
+			expectedError: "/home/user/.local/share/chezmoi/dot_foo.tmpl:2:19: executing \"/home/user/.local/share/chezmoi/dot_foo.tmpl\" at <.Unknown.Key>: map has no entry for key \"Unknown\"\n" +
+				"  1 | line1\n" +
+				"> 2 | \tkey = {{ .Unknown.Key }}\n" +
+				"    | \t                 ^\n" +
+				"data keys: chezmoi, email",
+		},
+		{
+			name:         "func_panic",
+			data:         "{{ secret \"foo\" }}",
+			expectedLine: 1,
+			expectedError: "/home/user/.local/share/chezmoi/dot_foo.tmpl:1:4: executing \"/home/user/.local/share/chezmoi/dot_foo.tmpl\" at <secret \"foo\">: error calling secret: secret: not found\n" +
+				"> 1 | {{ secret \"foo\" }}\n" +
+				"    |    ^\n" +
+				"data keys: chezmoi, email",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := NewTargetState(
+				WithDestDir("/home/user"),
+				WithSourceDir("/home/user/.local/share/chezmoi"),
+				WithTemplateData(map[string]interface{}{
+					"chezmoi": map[string]interface{}{},
+					"email":   "user@example.com",
+				}),
+				WithTemplateFuncs(funcs),
+				WithTemplateOptions([]string{"missingkey=error"}),
+			)
+			_, err := ts.ExecuteTemplateData("/home/user/.local/share/chezmoi/dot_foo.tmpl", []byte(tc.data))
+			var te *TemplateError
+			require.True(t, errors.As(err, &te))
+			assert.Equal(t, "/home/user/.local/share/chezmoi/dot_foo.tmpl", te.Name)
+			assert.Equal(t, tc.expectedLine, te.Line)
+			assert.Equal(t, tc.expectedError, err.Error())
+		})
+	}
+}
+
+func TestTemplateErrorUnwrap(t *testing.T) {
+	errSecret := errors.New("secret: not found")
+	ts := NewTargetState(
+		WithTemplateFuncs(map[string]interface{}{
+			"secret": func() string {
+				panic(errSecret)
+			},
+		}),
+	)
+	_, err := ts.ExecuteTemplateData("name", []byte("{{ secret }}"))
+	assert.True(t, errors.Is(err, errSecret))
+}
@@ -0,0 +1,164 @@
+package chezmoi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// builtinTemplateFuncs are text/template's builtin functions. Their results
+// depend only on their arguments.
+var builtinTemplateFuncs = map[string]struct{}{
+	"and":      {},
+	"call":     {},
+	"eq":       {},
+	"ge":       {},
+	"gt":       {},
+	"html":     {},
+	"index":    {},
+	"js":       {},
+	"le":       {},
+	"len":      {},
+	"lt":       {},
+	"ne":       {},
+	"not":      {},
+	"or":       {},
+	"print":    {},
+	"printf":   {},
+	"println":  {},
+	"slice":    {},
+	"urlquery": {},
+}
+
+// A templateOutputCacheEntry is the cached output of a template.
+type templateOutputCacheEntry struct {
+	InputsSHA256 string `json:"inputsSHA256"`
+	Output       []byte `json:"output"`
+}
+
+// getCachedTemplateOutput returns the cached output of executing the template
+// name in tmpl, if any, and the hash of the template's inputs. The hash is
+// empty if the template's output cannot be cached because it calls functions
+// other than text/template's builtins, for example to look up secrets.
+func (ts *TargetState) getCachedTemplateOutput(tmpl *template.Template, name string) ([]byte, string) {
+	if ts.templateOutputCache == nil {
+		return nil, ""
+	}
+	h := sha256.New()
+	if !hashTemplate(h, tmpl, name, make(map[string]struct{})) {
+		return nil, ""
+	}
+	templateData, err := json.Marshal(ts.TemplateData)
+	if err != nil {
+		return nil, ""
+	}
+	h.Write(templateData)
+	h.Write([]byte(strings.Join(ts.TemplateOptions, "\x00")))
+	inputsSHA256 := hex.EncodeToString(h.Sum(nil))
+
+	data, err := ts.templateOutputCache.Get(ts.templateOutputBucket, []byte(name))
+	if err != nil || data == nil {
+		return nil, inputsSHA256
+	}
+	var entry templateOutputCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.InputsSHA256 != inputsSHA256 {
+		return nil, inputsSHA256
+	}
+	if entry.Output == nil {
+		entry.Output = []byte{}
+	}
+	return entry.Output, inputsSHA256
+}
+
+// setCachedTemplateOutput caches output as the output of the template name
+// with inputs that hash to inputsSHA256. The cache is best-effort: errors,
+// for example because the persistent state is read-only in dry run mode, are
+// ignored.
+func (ts *TargetState) setCachedTemplateOutput(name, inputsSHA256 string, output []byte) {
+	if ts.templateOutputCache == nil || inputsSHA256 == "" {
+		return
+	}
+	data, err := json.Marshal(&templateOutputCacheEntry{
+		InputsSHA256: inputsSHA256,
+		Output:       output,
+	})
+	if err != nil {
+		return
+	}
+	_ = ts.templateOutputCache.Set(ts.templateOutputBucket, []byte(name), data)
+}
+
+// hashTemplate writes the template name in tmpl, and all the templates that
+// it invokes, to h. It returns false if any of the templates calls a
+// non-builtin function or invokes a template that does not exist.
+func hashTemplate(h hash.Hash, tmpl *template.Template, name string, visited map[string]struct{}) bool {
+	if _, ok := visited[name]; ok {
+		return true
+	}
+	visited[name] = struct{}{}
+	t := tmpl.Lookup(name)
+	if t == nil || t.Tree == nil || t.Tree.Root == nil {
+		return false
+	}
+	h.Write([]byte(t.Tree.Root.String()))
+	h.Write([]byte{0})
+	return hashTemplateNode(h, tmpl, t.Tree.Root, visited)
+}
+
+// hashTemplateNode writes the templates invoked by node to h, returning false
+// if node calls a non-builtin function or invokes a template that does not
+// exist.
+func hashTemplateNode(h hash.Hash, tmpl *template.Template, node parse.Node, visited map[string]struct{}) bool {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return true
+		}
+		for _, n := range node.Nodes {
+			if !hashTemplateNode(h, tmpl, n, visited) {
+				return false
+			}
+		}
+	case *parse.ActionNode:
+		return hashTemplateNode(h, tmpl, node.Pipe, visited)
+	case *parse.IfNode:
+		return hashTemplateBranchNode(h, tmpl, &node.BranchNode, visited)
+	case *parse.RangeNode:
+		return hashTemplateBranchNode(h, tmpl, &node.BranchNode, visited)
+	case *parse.WithNode:
+		return hashTemplateBranchNode(h, tmpl, &node.BranchNode, visited)
+	case *parse.TemplateNode:
+		return hashTemplateNode(h, tmpl, node.Pipe, visited) && hashTemplate(h, tmpl, node.Name, visited)
+	case *parse.PipeNode:
+		if node == nil {
+			return true
+		}
+		for _, cmd := range node.Cmds {
+			if !hashTemplateNode(h, tmpl, cmd, visited) {
+				return false
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			if !hashTemplateNode(h, tmpl, arg, visited) {
+				return false
+			}
+		}
+	case *parse.ChainNode:
+		return hashTemplateNode(h, tmpl, node.Node, visited)
+	case *parse.IdentifierNode:
+		_, ok := builtinTemplateFuncs[node.Ident]
+		return ok
+	}
+	return true
+}
+
+func hashTemplateBranchNode(h hash.Hash, tmpl *template.Template, node *parse.BranchNode, visited map[string]struct{}) bool {
+	return hashTemplateNode(h, tmpl, node.Pipe, visited) &&
+		hashTemplateNode(h, tmpl, node.List, visited) &&
+		hashTemplateNode(h, tmpl, node.ElseList, visited)
+}
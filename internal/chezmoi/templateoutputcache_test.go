@@ -0,0 +1,93 @@
+package chezmoi
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestTemplateOutputCache(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		data           string
+		expectedCached bool
+	}{
+		{
+			name:           "data_only",
+			data:           `{{ if eq .os "linux" }}{{ .name | printf "%s" }}{{ end }}`,
+			expectedCached: true,
+		},
+		{
+			name:           "builtin_partial",
+			data:           `{{ template "builtin" . }}`,
+			expectedCached: true,
+		},
+		{
+			name: "func",
+			data: `{{ secret }}`,
+		},
+		{
+			name: "func_in_partial",
+			data: `{{ template "secret" . }}`,
+		},
+		{
+			name: "func_in_else",
+			data: `{{ if false }}{{ else }}{{ secret }}{{ end }}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.config/chezmoi": &vfst.Dir{Perm: 0755},
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			persistentState, err := NewBoltPersistentState(fs, "/home/user/.config/chezmoi/chezmoistate.boltdb", vfst.DefaultUmask, nil)
+			require.NoError(t, err)
+			defer persistentState.Close()
+
+			funcs := template.FuncMap{
+				"secret": func() string {
+					return "secret"
+				},
+			}
+			newTargetState := func(data map[string]interface{}) *TargetState {
+				return NewTargetState(
+					WithTemplateData(data),
+					WithTemplateFuncs(funcs),
+					WithTemplateOutputCache(persistentState, []byte("templateOutput")),
+					WithTemplates(map[string]*template.Template{
+						"builtin": template.Must(template.New("builtin").Parse(`{{ len .name }}`)),
+						"secret":  template.Must(template.New("secret").Funcs(funcs).Parse(`{{ secret }}`)),
+					}),
+				)
+			}
+
+			ts := newTargetState(map[string]interface{}{
+				"name": "alice",
+				"os":   "linux",
+			})
+			_, err = ts.ExecuteTemplateData("file", []byte(tc.data))
+			require.NoError(t, err)
+			cachedData, err := persistentState.Get([]byte("templateOutput"), []byte("file"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCached, cachedData != nil)
+
+			// Changing the template data invalidates the cache.
+			if tc.expectedCached {
+				ts := newTargetState(map[string]interface{}{
+					"name": "bob",
+					"os":   "linux",
+				})
+				tmpl, err := template.New("file").Funcs(ts.TemplateFuncs).Parse(tc.data)
+				require.NoError(t, err)
+				require.NoError(t, ts.addReferencedTemplates(tmpl))
+				output, inputsSHA256 := ts.getCachedTemplateOutput(tmpl, "file")
+				assert.Nil(t, output)
+				assert.NotEmpty(t, inputsSHA256)
+			}
+		})
+	}
+}
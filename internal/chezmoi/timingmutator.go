@@ -0,0 +1,76 @@
+package chezmoi
+
+import (
+	"os"
+	"os/exec"
+)
+
+// A TimingMutator wraps a Mutator and records the time spent in each of its
+// methods. Commands are recorded in the "command" phase and all other changes
+// in the "mutate" phase.
+type TimingMutator struct {
+	m       Mutator
+	timings *Timings
+}
+
+// NewTimingMutator returns a new TimingMutator that records timings in
+// timings.
+func NewTimingMutator(m Mutator, timings *Timings) *TimingMutator {
+	return &TimingMutator{
+		m:       m,
+		timings: timings,
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *TimingMutator) Chmod(name string, mode os.FileMode) error {
+	defer m.timings.Start("mutate", "chmod "+name)()
+	return m.m.Chmod(name, mode)
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *TimingMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	defer m.timings.Start("command", ShellQuoteArgs(append([]string{cmd.Path}, cmd.Args[1:]...)))()
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *TimingMutator) Mkdir(name string, perm os.FileMode) error {
+	defer m.timings.Start("mutate", "mkdir "+name)()
+	return m.m.Mkdir(name, perm)
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *TimingMutator) RemoveAll(name string) error {
+	defer m.timings.Start("mutate", "remove "+name)()
+	return m.m.RemoveAll(name)
+}
+
+// Rename implements Mutator.Rename.
+func (m *TimingMutator) Rename(oldpath, newpath string) error {
+	defer m.timings.Start("mutate", "rename "+oldpath)()
+	return m.m.Rename(oldpath, newpath)
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *TimingMutator) RunCmd(cmd *exec.Cmd) error {
+	defer m.timings.Start("command", ShellQuoteArgs(append([]string{cmd.Path}, cmd.Args[1:]...)))()
+	return m.m.RunCmd(cmd)
+}
+
+// Stat implements Mutator.Stat.
+func (m *TimingMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *TimingMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	defer m.timings.Start("mutate", "write "+name)()
+	return m.m.WriteFile(name, data, perm, currData)
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *TimingMutator) WriteSymlink(oldname, newname string) error {
+	defer m.timings.Start("mutate", "symlink "+newname)()
+	return m.m.WriteSymlink(oldname, newname)
+}
@@ -0,0 +1,105 @@
+package chezmoi
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// A Timings records the time spent in each phase of a command. All methods are
+// safe for concurrent use and may be called on a nil *Timings, in which case
+// they do nothing.
+type Timings struct {
+	start  time.Time
+	mutex  sync.Mutex
+	phases map[string]*phaseTimings
+	order  []string
+}
+
+// A phaseTimings records the time spent in a single phase.
+type phaseTimings struct {
+	count int
+	total time.Duration
+	items []timingItem
+}
+
+// A timingItem records the time spent on a single named item in a phase.
+type timingItem struct {
+	name     string
+	duration time.Duration
+}
+
+// NewTimings returns a new Timings.
+func NewTimings() *Timings {
+	return &Timings{
+		start:  time.Now(),
+		phases: make(map[string]*phaseTimings),
+	}
+}
+
+// Add records that name in phase took duration.
+func (t *Timings) Add(phase, name string, duration time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	pt, ok := t.phases[phase]
+	if !ok {
+		pt = &phaseTimings{}
+		t.phases[phase] = pt
+		t.order = append(t.order, phase)
+	}
+	pt.count++
+	pt.total += duration
+	if name != "" {
+		pt.items = append(pt.items, timingItem{
+			name:     name,
+			duration: duration,
+		})
+	}
+}
+
+// Start starts timing name in phase and returns a function that stops timing
+// it.
+func (t *Timings) Start(phase, name string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.Add(phase, name, time.Since(start))
+	}
+}
+
+// Report writes a report of the time spent in each phase to w, in the order in
+// which the phases were first recorded, including the maxItems slowest items
+// in each phase. Phases that run concurrently may report totals greater than
+// the elapsed time.
+func (t *Timings) Report(w io.Writer, maxItems int) error {
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, phase := range t.order {
+		pt := t.phases[phase]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t\n", phase, pt.count, pt.total)
+		items := append([]timingItem(nil), pt.items...)
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].duration > items[j].duration
+		})
+		if len(items) > maxItems {
+			items = items[:maxItems]
+		}
+		for _, item := range items {
+			fmt.Fprintf(tw, "\t\t%s\t%s\n", item.duration, item.name)
+		}
+	}
+	fmt.Fprintf(tw, "total\t\t%s\t\n", time.Since(t.start))
+	return tw.Flush()
+}
@@ -0,0 +1,42 @@
+package chezmoi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimings(t *testing.T) {
+	timings := NewTimings()
+	timings.Add("populate", "", 10*time.Millisecond)
+	timings.Add("template", "foo", 1*time.Millisecond)
+	timings.Add("template", "bar", 3*time.Millisecond)
+	timings.Add("template", "baz", 2*time.Millisecond)
+
+	b := &bytes.Buffer{}
+	require.NoError(t, timings.Report(b, 2))
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(line), " "))
+	}
+	require.Len(t, lines, 5)
+	assert.Equal(t, []string{
+		"populate 1 10ms",
+		"template 3 6ms",
+		"3ms bar",
+		"2ms baz",
+	}, lines[:4])
+	assert.True(t, strings.HasPrefix(lines[4], "total "))
+}
+
+func TestTimingsNil(t *testing.T) {
+	var timings *Timings
+	timings.Start("phase", "name")()
+	b := &bytes.Buffer{}
+	require.NoError(t, timings.Report(b, 10))
+	assert.Empty(t, b.String())
+}
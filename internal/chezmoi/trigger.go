@@ -0,0 +1,121 @@
+package chezmoi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/bmatcuk/doublestar"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// A Trigger is a shell command that is run when any target matching Pattern
+// is changed.
+type Trigger struct {
+	Pattern string
+	Command string
+}
+
+// Match returns true if t's pattern matches targetName or any of its parent
+// directories.
+func (t *Trigger) Match(targetName string) bool {
+	for name := targetName; name != "." && name != string(filepath.Separator); name = filepath.Dir(name) {
+		if ok, _ := doublestar.PathMatch(t.Pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// TriggeredCommands returns the commands of the triggers of ts and its roots
+// that match any of targetPaths, without duplicates and in the order in which
+// they are defined.
+func (ts *TargetState) TriggeredCommands(targetPaths []string) []string {
+	var commands []string
+	seen := make(map[string]struct{})
+	for _, targetState := range ts.AllTargetStates() {
+		for _, command := range targetState.triggeredCommands(targetPaths) {
+			if _, ok := seen[command]; !ok {
+				commands = append(commands, command)
+				seen[command] = struct{}{}
+			}
+		}
+	}
+	return commands
+}
+
+// triggeredCommands returns the commands of ts's triggers that match any of
+// targetPaths, without duplicates and in the order in which they are defined.
+// targetPaths outside ts.DestDir are ignored.
+func (ts *TargetState) triggeredCommands(targetPaths []string) []string {
+	var targetNames []string
+	for _, targetPath := range targetPaths {
+		targetName, err := filepath.Rel(ts.DestDir, targetPath)
+		if err != nil || targetName == "." || targetName == ".." || strings.HasPrefix(targetName, ".."+string(filepath.Separator)) {
+			continue
+		}
+		targetNames = append(targetNames, targetName)
+	}
+	var commands []string
+	seen := make(map[string]struct{})
+	for _, trigger := range ts.Triggers {
+		if _, ok := seen[trigger.Command]; ok {
+			continue
+		}
+		for _, targetName := range targetNames {
+			if trigger.Match(targetName) {
+				commands = append(commands, trigger.Command)
+				seen[trigger.Command] = struct{}{}
+				break
+			}
+		}
+	}
+	return commands
+}
+
+// parseTriggers parses the triggers in data, which was read from path. Each
+// non-empty line that does not start with a # contains a pattern relative to
+// the directory of relPath, followed by whitespace and a command.
+func parseTriggers(data []byte, path, relPath string) ([]*Trigger, error) {
+	dir := filepath.Dir(relPath)
+	var triggers []*Trigger
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		index := strings.IndexFunc(text, unicode.IsSpace)
+		if index == -1 {
+			return nil, fmt.Errorf("%s:%d: %s: missing command", path, line, text)
+		}
+		pattern := filepath.Join(dir, text[:index])
+		if _, err := doublestar.PathMatch(pattern, pattern); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, line, text[:index], err)
+		}
+		triggers = append(triggers, &Trigger{
+			Pattern: pattern,
+			Command: strings.TrimSpace(text[index:]),
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return triggers, nil
+}
+
+func (ts *TargetState) addTriggers(fs vfs.FS, path, relPath string) error {
+	data, err := ts.executeTemplate(fs, path)
+	if err != nil {
+		return err
+	}
+	triggers, err := parseTriggers(data, path, relPath)
+	if err != nil {
+		return err
+	}
+	ts.Triggers = append(ts.Triggers, triggers...)
+	return nil
+}
@@ -0,0 +1,75 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTriggers(t *testing.T) {
+	triggers, err := parseTriggers([]byte(""+
+		"# comment\n"+
+		"\n"+
+		".tmux.conf tmux source-file ~/.tmux.conf\n"+
+		"i3/*\ti3-msg reload # not a comment\n",
+	), ".config/.chezmoitriggers", ".config/.chezmoitriggers")
+	require.NoError(t, err)
+	assert.Equal(t, []*Trigger{
+		{Pattern: ".config/.tmux.conf", Command: "tmux source-file ~/.tmux.conf"},
+		{Pattern: ".config/i3/*", Command: "i3-msg reload # not a comment"},
+	}, triggers)
+
+	_, err = parseTriggers([]byte(".tmux.conf\n"), ".chezmoitriggers", ".chezmoitriggers")
+	assert.Error(t, err)
+}
+
+func TestTriggeredCommands(t *testing.T) {
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+	)
+	ts.Triggers = []*Trigger{
+		{Pattern: ".tmux.conf", Command: "tmux source-file ~/.tmux.conf"},
+		{Pattern: ".config/i3", Command: "i3-msg reload"},
+		{Pattern: ".config/systemd/user/*.service", Command: "systemctl --user daemon-reload"},
+		{Pattern: ".config/systemd/user/*.timer", Command: "systemctl --user daemon-reload"},
+	}
+	for _, tc := range []struct {
+		name        string
+		targetPaths []string
+		expected    []string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:        "unmatched",
+			targetPaths: []string{"/home/user/.bashrc"},
+		},
+		{
+			name:        "outside_dest_dir",
+			targetPaths: []string{"/etc/.tmux.conf", "/home/.tmux.conf"},
+		},
+		{
+			name:        "parent_dir",
+			targetPaths: []string{"/home/user/.config/i3/config"},
+			expected:    []string{"i3-msg reload"},
+		},
+		{
+			name: "deduplicated",
+			targetPaths: []string{
+				"/home/user/.config/systemd/user/foo.timer",
+				"/home/user/.config/systemd/user/foo.service",
+				"/home/user/.tmux.conf",
+			},
+			expected: []string{
+				"tmux source-file ~/.tmux.conf",
+				"systemctl --user daemon-reload",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ts.TriggeredCommands(tc.targetPaths))
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 // A ParseError is a parse error.
@@ -65,8 +66,19 @@ type IgnoredStatus struct {
 	Path string
 }
 
+// A BranchStatus is the status of the current branch, parsed from the
+// "# branch.*" header lines.
+type BranchStatus struct {
+	OID      string
+	Head     string
+	Upstream string
+	Ahead    int
+	Behind   int
+}
+
 // A Status is a status.
 type Status struct {
+	Branch          *BranchStatus
 	Ordinary        []OrdinaryStatus
 	RenamedOrCopied []RenamedOrCopiedStatus
 	Unmerged        []UnmergedStatus
@@ -76,7 +88,11 @@ type Status struct {
 
 //nolint:gochecknoglobals
 var (
-	statusPorcelainV2ZOrdinaryRegexp = regexp.MustCompile(`` +
+	statusPorcelainV2BranchOIDRegexp      = regexp.MustCompile(`^# branch\.oid (\(initial\)|[0-9a-f]+)$`)
+	statusPorcelainV2BranchHeadRegexp     = regexp.MustCompile(`^# branch\.head (.*)$`)
+	statusPorcelainV2BranchUpstreamRegexp = regexp.MustCompile(`^# branch\.upstream (.*)$`)
+	statusPorcelainV2BranchABRegexp       = regexp.MustCompile(`^# branch\.ab \+([0-9]+) -([0-9]+)$`)
+	statusPorcelainV2ZOrdinaryRegexp      = regexp.MustCompile(`` +
 		`^1 ` +
 		`([!\.\?ACDMRU])([!\.\?ACDMRU]) ` +
 		`(N\.\.\.|S[\.C][\.M][\.U]) ` +
@@ -131,8 +147,16 @@ func (e ParseError) Error() string {
 	return fmt.Sprintf("cannot parse %q", string(e))
 }
 
+// Empty returns true if s contains no changes, excluding ignored files.
+func (s *Status) Empty() bool {
+	return len(s.Ordinary) == 0 &&
+		len(s.RenamedOrCopied) == 0 &&
+		len(s.Unmerged) == 0 &&
+		len(s.Untracked) == 0
+}
+
 // ParseStatusPorcelainV2 parses the output of
-//   git status --ignored --porcelain=v2
+//   git status --branch --ignored --porcelain=v2
 // See https://git-scm.com/docs/git-status.
 func ParseStatusPorcelainV2(output []byte) (*Status, error) {
 	status := &Status{}
@@ -228,10 +252,53 @@ func ParseStatusPorcelainV2(output []byte) (*Status, error) {
 			}
 			status.Ignored = append(status.Ignored, us)
 		case '#':
-			continue
+			if err := parseBranchHeader(status, text); err != nil {
+				return nil, err
+			}
 		default:
 			return nil, ParseError(text)
 		}
 	}
 	return status, s.Err()
 }
+
+// parseBranchHeader parses a single "# branch.*" header line into status.
+// Other headers are ignored.
+func parseBranchHeader(status *Status, text string) error {
+	if !strings.HasPrefix(text, "# branch.") {
+		return nil
+	}
+	if status.Branch == nil {
+		status.Branch = &BranchStatus{}
+	}
+	switch {
+	case strings.HasPrefix(text, "# branch.oid "):
+		m := statusPorcelainV2BranchOIDRegexp.FindStringSubmatch(text)
+		if m == nil {
+			return ParseError(text)
+		}
+		status.Branch.OID = m[1]
+	case strings.HasPrefix(text, "# branch.head "):
+		m := statusPorcelainV2BranchHeadRegexp.FindStringSubmatch(text)
+		if m == nil {
+			return ParseError(text)
+		}
+		status.Branch.Head = m[1]
+	case strings.HasPrefix(text, "# branch.upstream "):
+		m := statusPorcelainV2BranchUpstreamRegexp.FindStringSubmatch(text)
+		if m == nil {
+			return ParseError(text)
+		}
+		status.Branch.Upstream = m[1]
+	case strings.HasPrefix(text, "# branch.ab "):
+		m := statusPorcelainV2BranchABRegexp.FindStringSubmatch(text)
+		if m == nil {
+			return ParseError(text)
+		}
+		ahead, _ := strconv.Atoi(m[1])
+		behind, _ := strconv.Atoi(m[2])
+		status.Branch.Ahead = ahead
+		status.Branch.Behind = behind
+	}
+	return nil
+}
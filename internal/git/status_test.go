@@ -133,6 +133,46 @@ func TestParseStatusPorcelainV2(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "branch",
+			outputStr: "" +
+				"# branch.oid 9d06c86ecba40e1c695e69b55a40843df6a79cef\n" +
+				"# branch.head master\n" +
+				"# branch.upstream origin/master\n" +
+				"# branch.ab +2 -1\n",
+			expectedStatus: &Status{
+				Branch: &BranchStatus{
+					OID:      "9d06c86ecba40e1c695e69b55a40843df6a79cef",
+					Head:     "master",
+					Upstream: "origin/master",
+					Ahead:    2,
+					Behind:   1,
+				},
+			},
+		},
+		{
+			name: "branch_initial",
+			outputStr: "" +
+				"# branch.oid (initial)\n" +
+				"# branch.head master\n" +
+				"? chezmoi.go\n",
+			expectedStatus: &Status{
+				Branch: &BranchStatus{
+					OID:  "(initial)",
+					Head: "master",
+				},
+				Untracked: []UntrackedStatus{
+					{
+						Path: "chezmoi.go",
+					},
+				},
+			},
+		},
+		{
+			name:           "stash",
+			outputStr:      "# stash 3\n",
+			expectedStatus: &Status{},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			actualStatus, err := ParseStatusPorcelainV2([]byte(tc.outputStr))
@@ -141,3 +181,50 @@ func TestParseStatusPorcelainV2(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStatusPorcelainV2Error(t *testing.T) {
+	for _, outputStr := range []string{
+		"# branch.oid xyz\n",
+		"# branch.ab 2 1\n",
+	} {
+		_, err := ParseStatusPorcelainV2([]byte(outputStr))
+		assert.Error(t, err)
+	}
+}
+
+func TestStatusEmpty(t *testing.T) {
+	for _, tc := range []struct {
+		status        *Status
+		expectedEmpty bool
+	}{
+		{
+			status:        &Status{},
+			expectedEmpty: true,
+		},
+		{
+			status: &Status{
+				Branch: &BranchStatus{
+					Ahead: 1,
+				},
+				Ignored: []IgnoredStatus{
+					{
+						Path: "chezmoi.go",
+					},
+				},
+			},
+			expectedEmpty: true,
+		},
+		{
+			status: &Status{
+				Untracked: []UntrackedStatus{
+					{
+						Path: "chezmoi.go",
+					},
+				},
+			},
+			expectedEmpty: false,
+		},
+	} {
+		assert.Equal(t, tc.expectedEmpty, tc.status.Empty())
+	}
+}